@@ -0,0 +1,147 @@
+package dispatcher
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+)
+
+// ApplyArrayOperations applies array operations of update to record, which is for sinks keeping the latest
+// state of records. Elements are removed by value before new elements are appended, and order of the rest
+// is kept. Invalid paths are reported together by UpdateError.
+func ApplyArrayOperations(r *record_type.Record, update *record_type.Record) error {
+	return newUpdateError(applyArrayOperations(r, update))
+}
+
+func applyArrayOperations(r *record_type.Record, update *record_type.Record) []*FieldPathError {
+
+	var errs []*FieldPathError
+	for _, op := range []string{rule_manager.ArrayRemoveElementField, rule_manager.ArrayAppendField} {
+
+		field := record_type.GetField(update.Payload.Map.Fields, op)
+		if field == nil || field.Value.Type != record_type.DataType_MAP {
+			continue
+		}
+
+		for _, f := range field.Value.Map.Fields {
+
+			if f.Value.Type != record_type.DataType_ARRAY {
+				continue
+			}
+
+			arr, err := lookupArray(r.Payload, f.Name, op == rule_manager.ArrayAppendField)
+			if err != nil {
+				errs = append(errs, &FieldPathError{Path: f.Name, Err: err})
+				continue
+			}
+
+			// Nothing to remove
+			if arr == nil {
+				continue
+			}
+
+			if op == rule_manager.ArrayAppendField {
+				arr.Elements = append(arr.Elements, f.Value.Array.Elements...)
+				continue
+			}
+
+			arr.Elements = removeElements(arr.Elements, f.Value.Array.Elements)
+		}
+	}
+
+	return errs
+}
+
+// lookupArray finds array by path, and empty array is created if it doesn't exist and create is set.
+func lookupArray(root *record_type.Value, path string, create bool) (*record_type.ArrayValue, error) {
+
+	tokens := record_type.ParsePath(path)
+	if len(tokens) == 0 {
+		return nil, record_type.ErrNotFoundKey
+	}
+
+	parent, err := lookupValue(root, tokens[:len(tokens)-1], create)
+	if err != nil {
+		if !create {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var v *record_type.Value
+	switch parent.Type {
+	case record_type.DataType_MAP:
+
+		field := record_type.GetField(parent.Map.Fields, tokens[len(tokens)-1].Value)
+		if field == nil {
+			if !create {
+				return nil, nil
+			}
+
+			field = &record_type.Field{
+				Name: tokens[len(tokens)-1].Value,
+				Value: &record_type.Value{
+					Type:  record_type.DataType_ARRAY,
+					Array: &record_type.ArrayValue{},
+				},
+			}
+
+			parent.Map.Fields = append(parent.Map.Fields, field)
+		}
+
+		v = field.Value
+
+	case record_type.DataType_ARRAY:
+
+		index, err := arrayIndex(parent, tokens[len(tokens)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		v = parent.Array.Elements[index]
+
+	default:
+		return nil, record_type.ErrNotFoundKey
+	}
+
+	if v.Type != record_type.DataType_ARRAY {
+		return nil, fmt.Errorf("expected array, but got %s", v.Type)
+	}
+
+	if v.Array == nil {
+		v.Array = &record_type.ArrayValue{}
+	}
+
+	return v.Array, nil
+}
+
+func removeElements(elements []*record_type.Value, removed []*record_type.Value) []*record_type.Value {
+
+	values := make([]interface{}, len(removed))
+	for i, ele := range removed {
+		values[i] = record_type.GetValueData(ele)
+	}
+
+	kept := elements[:0]
+	for _, ele := range elements {
+
+		data := record_type.GetValueData(ele)
+
+		found := false
+		for _, v := range values {
+			if reflect.DeepEqual(data, v) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			kept = append(kept, ele)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,80 @@
+package dispatcher
+
+import (
+	"testing"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_ArrayOperations(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor()
+	defer p.Close()
+
+	process := func(payload string) (*record_type.Record, error) {
+
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		})
+
+		msg := CreateTestMessage()
+		msg.Raw = raw
+
+		result, err := p.Process(msg)
+		if err != nil {
+			return nil, err
+		}
+
+		return result.ProductEvent.GetContent()
+	}
+
+	r, err := process(`{"id":101,"tags":["a","b","c","b"]}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	update, err := process(`{
+	"id":101,
+	"$append": { "tags": ["d", "e"] },
+	"$removeElement": { "tags": "b" }
+}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if !assert.Nil(t, ApplyArrayOperations(r, update)) {
+		return
+	}
+
+	v, _ := r.GetValueDataByPath("tags")
+	assert.Equal(t, []interface{}{"a", "c", "d", "e"}, v)
+
+	// Array is created if it doesn't exist
+	r, err = process(`{"id":102}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	update, err = process(`{"id":102,"$append":{"tags":"x"},"$removeElement":{"tags":"y"}}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if assert.Nil(t, ApplyArrayOperations(r, update)) {
+		v, _ = r.GetValueDataByPath("tags")
+		assert.Equal(t, []interface{}{"x"}, v)
+	}
+
+	// Operations are only available for array
+	_, err = process(`{"id":101,"$append":{"name":"x"}}`)
+	var validationErr *rule_manager.SchemaValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, "name", validationErr.Field)
+	}
+}
@@ -0,0 +1,54 @@
+package dispatcher
+
+import (
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// RawMetaKey is key of original payload in meta of emitted records.
+const RawMetaKey = "raw"
+
+// WithAttachRaw attaches original payload of message to meta of emitted records as a JSON string, which is
+// useful for debugging and audit. It's disabled by default. Masked fields of rule are masked in attached payload
+// as well, regardless of conditions of masks, so that it never reveals what records hide.
+func WithAttachRaw(enabled bool) func(*Processor) {
+	return func(p *Processor) {
+		p.attachRaw = enabled
+	}
+}
+
+func (p *Processor) attachRawPayload(msg *Message, r *record_type.Record) error {
+
+	raw := msg.Data.RawPayload
+	if len(raw) == 0 {
+		return nil
+	}
+
+	// Payload was changed by transforming, so masked copy is decoded from raw payload
+	if msg.Rule.HasMasks() {
+		var data map[string]interface{}
+		err := p.codec.Unmarshal(raw, &data)
+		if err != nil {
+			return err
+		}
+
+		msg.Rule.MaskRaw(data)
+
+		raw, err = p.codec.Marshal(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.Meta == nil {
+		r.Meta = &structpb.Struct{}
+	}
+
+	if r.Meta.Fields == nil {
+		r.Meta.Fields = make(map[string]*structpb.Value)
+	}
+
+	r.Meta.Fields[RawMetaKey] = structpb.NewStringValue(string(raw))
+
+	return nil
+}
@@ -1,8 +1,10 @@
 package dispatcher
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
 	product_sdk "github.com/BrobridgeOrg/gravity-sdk/v2/product"
 	"go.uber.org/zap"
 )
@@ -113,3 +115,94 @@ func BenchmarkProcessor_Normal(b *testing.B) {
 		<-results
 	}
 }
+
+func benchmarkProcessorWorkload(b *testing.B, payload string) {
+
+	logger = zap.NewNop()
+
+	const count = 1000
+
+	p := NewProcessor()
+	defer p.Close()
+
+	r := CreateTestRule()
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(r)
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(payload),
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < count; j++ {
+			msg := NewMessage()
+			msg.Rule = r
+			msg.Raw = raw
+
+			_, err := p.Process(msg)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			msg.Release()
+		}
+	}
+}
+
+func BenchmarkProcessor_CreateOnly(b *testing.B) {
+	benchmarkProcessorWorkload(b, `{"id":101,"name":"fred","gender":"male","nested":{"nested_id":"n1"},"tags":["a","b"]}`)
+}
+
+func BenchmarkProcessor_PartialUpdate(b *testing.B) {
+	benchmarkProcessorWorkload(b, `{"id":101,"name":"fred","nested.nested_id":"n1","$removedFields":["gender"]}`)
+}
+
+func benchmarkProcessorMultipleInputs(b *testing.B, codec JSONCodec) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor(WithJSONCodec(codec))
+	defer p.Close()
+
+	r := CreateTestRule()
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(r)
+
+	inputs := make([][]byte, 0, 100)
+	for i := 0; i < cap(inputs); i++ {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(fmt.Sprintf(`{"id":%d,"name":"name-%d","gender":"male","nested":{"nested_id":"n%d"},"tags":["a","b"]}`, i, i, i)),
+		})
+
+		inputs = append(inputs, raw)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, raw := range inputs {
+			msg := NewMessage()
+			msg.Rule = r
+			msg.Raw = raw
+
+			_, err := p.Process(msg)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			msg.Release()
+		}
+	}
+}
+
+func BenchmarkProcessor_MultipleInputsStdJSON(b *testing.B) {
+	benchmarkProcessorMultipleInputs(b, StdJSONCodec)
+}
+
+func BenchmarkProcessor_MultipleInputsDefaultJSON(b *testing.B) {
+	benchmarkProcessorMultipleInputs(b, DefaultJSONCodec)
+}
@@ -0,0 +1,55 @@
+package converter
+
+import (
+	"encoding/json"
+	"testing"
+
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"github.com/BrobridgeOrg/schemer"
+)
+
+func benchmarkConvert(b *testing.B, sample string) {
+
+	schemaSource := `{
+	"id": { "type": "int" },
+	"name": { "type": "string" },
+	"gender": { "type": "string" },
+	"nested": {
+		"type": "map",
+		"fields": {
+			"nested_id": { "type": "string" }
+		}
+	},
+	"tags": { "type": "array", "subtype": "string" }
+}`
+
+	var schemaMap map[string]interface{}
+	json.Unmarshal([]byte(schemaSource), &schemaMap)
+
+	schema := schemer.NewSchema()
+	schemer.Unmarshal(schemaMap, schema)
+
+	var data map[string]interface{}
+	json.Unmarshal([]byte(sample), &data)
+
+	fields := make([]*record_type.Field, 0, len(data))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := ConvertTo(fields[:0], schema, data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkConvert_CreateOnly converts plain keys only, which are looked up in schema directly.
+func BenchmarkConvert_CreateOnly(b *testing.B) {
+	benchmarkConvert(b, `{"id":101,"name":"fred","gender":"male","nested":{"nested_id":"n1"},"tags":["a","b"]}`)
+}
+
+// BenchmarkConvert_PartialUpdate has dotted key, which requires parsing path.
+func BenchmarkConvert_PartialUpdate(b *testing.B) {
+	benchmarkConvert(b, `{"id":101,"name":"fred","gender":"male","nested.nested_id":"n1","tags":["a","b"]}`)
+}
@@ -0,0 +1,34 @@
+package dispatcher
+
+import (
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// CorrelationIDLogField is name of log field which carries correlation ID of message.
+const CorrelationIDLogField = "correlation_id"
+
+// Logger returns logger with correlation ID of message, so that log entries for the same message can be
+// grouped across pipeline. Processor logger is returned before message is processed.
+func (m *Message) Logger() *zap.Logger {
+
+	if m.logger == nil {
+		return logger
+	}
+
+	return m.logger
+}
+
+// prepareLogger takes correlation ID from raw data, or generates one if it was not provided.
+func (m *Message) prepareLogger() {
+
+	if m.Data != nil && len(m.Data.CorrelationID) > 0 {
+		m.CorrelationID = m.Data.CorrelationID
+	}
+
+	if len(m.CorrelationID) == 0 {
+		m.CorrelationID = uuid.NewString()
+	}
+
+	m.logger = logger.With(zap.String(CorrelationIDLogField, m.CorrelationID))
+}
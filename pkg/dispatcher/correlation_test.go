@@ -0,0 +1,59 @@
+package dispatcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestProcessor_CorrelationID(t *testing.T) {
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger = zap.New(core)
+	defer func() {
+		logger = zap.NewNop()
+	}()
+
+	p := NewProcessor()
+	defer p.Close()
+
+	process := func(data MessageRawData) *Message {
+
+		raw, _ := json.Marshal(data)
+
+		msg := CreateTestMessage()
+		msg.Raw = raw
+
+		result, _ := p.Process(msg)
+
+		return result
+	}
+
+	// Primary key is missing
+	msg := process(MessageRawData{
+		Event:         "dataCreated",
+		RawPayload:    []byte(`{"name":"fred"}`),
+		CorrelationID: "req-42",
+	})
+	assert.Equal(t, "req-42", msg.CorrelationID)
+
+	entries := logs.FilterMessage("Failed to process payload").All()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "req-42", entries[0].ContextMap()[CorrelationIDLogField])
+	}
+
+	// Generated if not provided
+	msg = process(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"name":"stacy"}`),
+	})
+	assert.NotEmpty(t, msg.CorrelationID)
+
+	entries = logs.FilterMessage("Failed to process payload").All()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, msg.CorrelationID, entries[1].ContextMap()[CorrelationIDLogField])
+	}
+}
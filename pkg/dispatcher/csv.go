@@ -0,0 +1,217 @@
+package dispatcher
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+)
+
+// CSVNestedEncoding decides how maps and arrays are written to CSV.
+type CSVNestedEncoding int
+
+const (
+	// CSVNestedJSON writes maps and arrays as JSON strings in a single column.
+	CSVNestedJSON CSVNestedEncoding = iota
+
+	// CSVNestedFlatten expands maps with declared fields into columns such as "nested.nested_id". Arrays and
+	// maps with arbitrary keys have no fixed columns, so they are still written as JSON strings.
+	CSVNestedFlatten
+)
+
+// CSVEncoder encodes processed records as CSV rows. Columns come from schema of rule, with primary keys first in
+// order of keys and the rest sorted by name, so every row of the same rule has the same columns.
+type CSVEncoder struct {
+	header bool
+	nested CSVNestedEncoding
+
+	// Products which header row was emitted for
+	mutex   sync.Mutex
+	headers map[string]struct{}
+}
+
+func NewCSVEncoder(opts ...func(*CSVEncoder)) *CSVEncoder {
+
+	e := &CSVEncoder{
+		headers: make(map[string]struct{}),
+	}
+
+	for _, o := range opts {
+		o(e)
+	}
+
+	return e
+}
+
+// WithCSVHeader emits header row before the first row of each product.
+func WithCSVHeader(enabled bool) func(*CSVEncoder) {
+	return func(e *CSVEncoder) {
+		e.header = enabled
+	}
+}
+
+// WithCSVNestedEncoding sets how maps and arrays are written, which is CSVNestedJSON by default.
+func WithCSVNestedEncoding(encoding CSVNestedEncoding) func(*CSVEncoder) {
+	return func(e *CSVEncoder) {
+		e.nested = encoding
+	}
+}
+
+// Encode encodes processed record of message as a CSV row, which is preceded by header row if this is the first
+// message of product.
+func (e *CSVEncoder) Encode(m *Message) ([]byte, error) {
+
+	if m.Rule == nil {
+		return nil, ErrRuleNotFound
+	}
+
+	r, err := m.getRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := csvColumns(m.Rule, e.nested)
+	data := r.AsMap()
+
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		v, ok := lookupCSVValue(data, column)
+		if !ok {
+			continue
+		}
+
+		row[i], err = formatCSVValue(rule_manager.LookupFieldSchema(m.Rule.Fields, column), v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode field \"%s\": %w", column, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if e.header && e.markHeader(m.Rule.Product) {
+		w.Write(columns)
+	}
+
+	w.Write(row)
+	w.Flush()
+
+	err = w.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// markHeader returns true only for the first call of product.
+func (e *CSVEncoder) markHeader(product string) bool {
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, ok := e.headers[product]; ok {
+		return false
+	}
+
+	e.headers[product] = struct{}{}
+
+	return true
+}
+
+func csvColumns(rule *rule_manager.Rule, nested CSVNestedEncoding) []string {
+
+	columns := make([]string, 0, len(rule.Fields))
+
+	// Primary keys come first, and nested keys take place of their parents
+	for _, key := range rule.PrimaryKey {
+		name := key
+		if nested != CSVNestedFlatten {
+			name, _, _ = strings.Cut(key, ".")
+		}
+
+		if !slices.Contains(columns, name) {
+			columns = append(columns, name)
+		}
+	}
+
+	rest := make([]string, 0, len(rule.Fields))
+	appendCSVColumns(&rest, rule.Fields, "", nested)
+	sort.Strings(rest)
+
+	for _, column := range rest {
+		if !slices.Contains(columns, column) {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
+
+func appendCSVColumns(columns *[]string, fields map[string]*rule_manager.FieldSchema, prefix string, nested CSVNestedEncoding) {
+
+	for name, fs := range fields {
+
+		if nested == CSVNestedFlatten && fs.Type == "map" && fs.ValueType == nil && len(fs.Fields) > 0 {
+			appendCSVColumns(columns, fs.Fields, prefix+name+".", nested)
+			continue
+		}
+
+		*columns = append(*columns, prefix+name)
+	}
+}
+
+func lookupCSVValue(data map[string]interface{}, path string) (interface{}, bool) {
+
+	var cur interface{} = data
+	for _, name := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		cur, ok = m[name]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return cur, true
+}
+
+func formatCSVValue(fs *rule_manager.FieldSchema, v interface{}) (string, error) {
+
+	switch value := v.(type) {
+	case int8:
+
+		// Booleans are decoded from record as integers
+		if fs != nil && fs.BaseType() == "bool" {
+			return strconv.FormatBool(value != 0), nil
+		}
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case time.Time:
+		return value.UTC().Format(time.RFC3339Nano), nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(value), nil
+	case map[string]interface{}, []interface{}:
+		data, err := jsonPayload.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+
+		return string(data), nil
+	}
+
+	return fmt.Sprint(v), nil
+}
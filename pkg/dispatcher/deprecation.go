@@ -0,0 +1,30 @@
+package dispatcher
+
+import (
+	"go.uber.org/zap"
+)
+
+// WithDeprecationHandler sets handler which is called for every deprecated field present in payload.
+// Messages are processed as usual, warnings are logged if no handler is set.
+func WithDeprecationHandler(fn func(msg *Message, field string)) func(*Processor) {
+	return func(p *Processor) {
+		p.deprecationHandler = fn
+	}
+}
+
+func (p *Processor) checkDeprecatedFields(msg *Message) {
+
+	for _, field := range msg.Rule.DeprecatedFields(msg.Data.Payload) {
+
+		if p.deprecationHandler != nil {
+			p.deprecationHandler(msg, field)
+			continue
+		}
+
+		msg.Logger().Warn("Deprecated field is present in payload",
+			zap.String("product", msg.Rule.Product),
+			zap.String("rule", msg.Rule.ID),
+			zap.String("field", field),
+		)
+	}
+}
@@ -17,7 +17,16 @@ import (
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
-var logger *zap.Logger
+// jsonPayload decodes numbers as json.Number, so integers are never converted to float64
+var jsonPayload = jsoniter.Config{
+	EscapeHTML:             true,
+	SortMapKeys:            true,
+	ValidateJsonRawMessage: true,
+	UseNumber:              true,
+}.Froze()
+
+// Logger is replaced by New, so processors created without dispatcher log nothing
+var logger = zap.NewNop()
 
 type Dispatcher struct {
 	publisher          *core.Client
@@ -79,8 +88,18 @@ func (d *Dispatcher) productSettingsUpdated(entry *config_store.ConfigEntry) {
 		return
 	}
 
+	prefix, err := storedSubjectPrefix(entry.Value)
+	if err != nil {
+		logger.Error("Failed to sync:",
+			zap.Error(err),
+			zap.String("product", entry.Key),
+		)
+
+		return
+	}
+
 	// Create or update data product
-	err = d.productManager.ApplySettings(entry.Key, &setting)
+	err = d.productManager.ApplySettings(entry.Key, &setting, WithProductSubjectPrefix(prefix))
 	if err != nil {
 		logger.Error("Failed to load data product settings",
 			zap.String("product", entry.Key),
@@ -0,0 +1,53 @@
+// Package dispatchertest provides fixtures for tests of dispatcher, such as rules and messages which are ready to
+// be pushed to processor.
+package dispatchertest
+
+import (
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher"
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	product_sdk "github.com/BrobridgeOrg/gravity-sdk/v2/product"
+)
+
+// NewRule creates rule of product for event with schema config, and prepares it with a rule manager, so that it's
+// ready to transform messages.
+func NewRule(product string, event string, schema map[string]interface{}, primaryKey ...string) (*rule_manager.Rule, error) {
+
+	r := rule_manager.NewRule(product_sdk.NewRule())
+	r.Event = event
+	r.Product = product
+	r.PrimaryKey = primaryKey
+	r.SchemaConfig = schema
+
+	rm := rule_manager.NewRuleManager()
+	err := rm.AddRule(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// NewMessage creates message of event with payload as if it was received from data source, which is bound with
+// rule if rule is not nil. It panics if payload is not able to be encoded as JSON.
+func NewMessage(rule *rule_manager.Rule, event string, payload map[string]interface{}) *dispatcher.Message {
+
+	rawPayload, err := dispatcher.DefaultJSONCodec.Marshal(payload)
+	if err != nil {
+		panic(err)
+	}
+
+	raw, err := dispatcher.DefaultJSONCodec.Marshal(dispatcher.MessageRawData{
+		Event:      event,
+		RawPayload: rawPayload,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	msg := dispatcher.NewMessage()
+	msg.Event = event
+	msg.Rule = rule
+	msg.Raw = raw
+
+	return msg
+}
@@ -0,0 +1,54 @@
+package dispatchertest
+
+import (
+	"testing"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMessage(t *testing.T) {
+
+	r, err := NewRule("TestDataProduct", "dataCreated", map[string]interface{}{
+		"id":   map[string]interface{}{"type": "int"},
+		"name": map[string]interface{}{"type": "string"},
+	}, "id")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	done := make(chan *dispatcher.Message, 1)
+	p := dispatcher.NewProcessor(
+		dispatcher.WithOutputHandler(func(msg *dispatcher.Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	p.Push(NewMessage(r, "dataCreated", map[string]interface{}{
+		"id":   101,
+		"name": "fred",
+	}))
+
+	msg := <-done
+	if !assert.Nil(t, msg.Error) {
+		return
+	}
+
+	assert.Equal(t, "TestDataProduct", msg.ProductEvent.Table)
+	assert.Equal(t, "dataCreated", msg.ProductEvent.EventName)
+
+	record, err := msg.ProductEvent.GetContent()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	id, ok := dispatcher.Typed(record_type.GetField(record.Payload.Map.Fields, "id")).AsInt64()
+	assert.True(t, ok)
+	assert.Equal(t, int64(101), id)
+
+	name, ok := dispatcher.Typed(record_type.GetField(record.Payload.Map.Fields, "name")).AsString()
+	assert.True(t, ok)
+	assert.Equal(t, "fred", name)
+}
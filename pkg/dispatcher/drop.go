@@ -0,0 +1,21 @@
+package dispatcher
+
+// DropReason describes why message was dropped by processor on purpose.
+type DropReason string
+
+const (
+	DropReasonSampled     DropReason = "sampled"
+	DropReasonRateLimited DropReason = "rate_limited"
+	DropReasonPaused      DropReason = "paused"
+	DropReasonUnchanged   DropReason = "unchanged"
+	DropReasonDuplicate   DropReason = "duplicate"
+)
+
+// WithDropHandler sets handler for accounting messages which were dropped by sampling or rate limit
+// of rules, because product was disabled, because record was unchanged, or because message was duplicate of one
+// emitted with the same sequence. These messages are marked as ignored and still passed to output handler afterward.
+func WithDropHandler(fn func(*Message, DropReason)) func(*Processor) {
+	return func(p *Processor) {
+		p.dropHandler = fn
+	}
+}
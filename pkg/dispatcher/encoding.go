@@ -0,0 +1,57 @@
+package dispatcher
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Format is encoding of processed record for output.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatAvro Format = "avro"
+	FormatCSV  Format = "csv"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// Rows of CSV are encoded without header, which needs state of products
+var defaultCSVEncoder = NewCSVEncoder()
+
+// Encode encodes processed record of message in specific format. Avro payload is in single-object encoding,
+// which carries fingerprint of schema generated from rule, so consumers are able to resolve the schema. CSV payload
+// is a single row without header, and CSVEncoder is for header and encoding of nested fields.
+func (m *Message) Encode(format Format) ([]byte, error) {
+
+	r, err := m.getRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatJSON:
+		return jsonPayload.Marshal(r.AsMap())
+	case FormatAvro:
+
+		if m.Rule == nil {
+			return nil, ErrRuleNotFound
+		}
+
+		codec, err := m.Rule.AvroCodec()
+		if err != nil {
+			return nil, err
+		}
+
+		native, err := m.Rule.AvroNative(r.AsMap())
+		if err != nil {
+			return nil, err
+		}
+
+		return codec.SingleFromNative(nil, native)
+	case FormatCSV:
+		return defaultCSVEncoder.Encode(m)
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+}
@@ -0,0 +1,153 @@
+package dispatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func CreateEncodedTestMessage(t *testing.T, payload string) *Message {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor()
+	defer p.Close()
+
+	r := CreateTestRule()
+	r.SchemaConfig["enabled"] = map[string]interface{}{"type": "bool"}
+	r.SchemaConfig["level"] = map[string]interface{}{"type": "int8"}
+	r.SchemaConfig["score"] = map[string]interface{}{"type": "float"}
+	r.SchemaConfig["createdAt"] = map[string]interface{}{"type": "time"}
+
+	rm := rule_manager.NewRuleManager()
+	if err := rm.AddRule(r); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(payload),
+	})
+
+	msg := NewMessage()
+	msg.Rule = r
+	msg.Raw = raw
+
+	result, err := p.Process(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestMessage_EncodeAvro(t *testing.T) {
+
+	msg := CreateEncodedTestMessage(t, `{
+	"id": 101,
+	"name": "fred",
+	"enabled": true,
+	"level": -3,
+	"score": 9.5,
+	"createdAt": "2024-03-01T08:00:00Z",
+	"nested": { "nested_id": "n1" },
+	"tags": ["a", "b"]
+}`)
+
+	data, err := msg.Encode(FormatAvro)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	schema, err := msg.Rule.AvroSchema()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	codec, err := goavro.NewCodec(schema)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// Fingerprint of schema is carried by payload
+	fingerprint, _, err := goavro.FingerprintFromSOE(data)
+	if assert.Nil(t, err) {
+		assert.Equal(t, codec.Rabin, fingerprint)
+	}
+
+	native, _, err := codec.NativeFromSingle(data)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	record := native.(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"long": int64(101)}, record["id"])
+	assert.Equal(t, map[string]interface{}{"string": "fred"}, record["name"])
+	assert.Equal(t, map[string]interface{}{"boolean": true}, record["enabled"])
+	assert.Equal(t, map[string]interface{}{"int": int32(-3)}, record["level"])
+	assert.Equal(t, map[string]interface{}{"double": 9.5}, record["score"])
+	assert.Equal(t, map[string]interface{}{"array": []interface{}{"a", "b"}}, record["tags"])
+	assert.Nil(t, record["gender"])
+
+	createdAt := record["createdAt"].(map[string]interface{})["long.timestamp-micros"].(time.Time)
+	assert.True(t, createdAt.Equal(time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)))
+
+	nested := record["nested"].(map[string]interface{})["gravity.TestDataProduct_nested"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"string": "n1"}, nested["nested_id"])
+}
+
+func TestMessage_EncodeJSON(t *testing.T) {
+
+	msg := CreateEncodedTestMessage(t, `{"id":101,"name":"fred"}`)
+
+	data, err := msg.Encode(FormatJSON)
+	if assert.Nil(t, err) {
+		assert.JSONEq(t, `{"id":101,"name":"fred"}`, string(data))
+	}
+
+	_, err = msg.Encode(Format("xml"))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestMessage_EncodeCSV(t *testing.T) {
+
+	msg := CreateEncodedTestMessage(t, `{"id":101,"name":"fred, jr.","enabled":true,"score":9.5}`)
+
+	data, err := msg.Encode(FormatCSV)
+	if assert.Nil(t, err) {
+		// id,createdAt,enabled,gender,level,name,nested,score,tags
+		assert.Equal(t, "101,,true,,,\"fred, jr.\",,9.5,\n", string(data))
+	}
+}
+
+func TestCSVEncoder(t *testing.T) {
+
+	payload := `{"id":101,"name":"fred","nested":{"nested_id":"n1"},"tags":["a","b"]}`
+
+	e := NewCSVEncoder(WithCSVHeader(true))
+
+	// Header is emitted only for the first message of product
+	data, err := e.Encode(CreateEncodedTestMessage(t, payload))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "id,createdAt,enabled,gender,level,name,nested,score,tags\n"+
+			"101,,,,,fred,\"{\"\"nested_id\"\":\"\"n1\"\"}\",,\"[\"\"a\"\",\"\"b\"\"]\"\n", string(data))
+	}
+
+	data, err = e.Encode(CreateEncodedTestMessage(t, `{"id":102}`))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "102,,,,,,,,\n", string(data))
+	}
+
+	// Fields of nested map become columns
+	e = NewCSVEncoder(WithCSVHeader(true), WithCSVNestedEncoding(CSVNestedFlatten))
+
+	data, err = e.Encode(CreateEncodedTestMessage(t, payload))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "id,createdAt,enabled,gender,level,name,nested.nested_id,score,tags\n"+
+			"101,,,,,fred,n1,,\"[\"\"a\"\",\"\"b\"\"]\"\n", string(data))
+	}
+}
@@ -0,0 +1,29 @@
+package dispatcher
+
+// explode splits message into messages for elements of array field named by Explode of rule, and transforms
+// each of them. Message carries the first record, and the rest are emitted right after it.
+func (p *Processor) explode(msg *Message) *Message {
+
+	payloads := msg.Rule.ExplodePayload(msg.Data.Payload)
+	if len(payloads) == 0 {
+		// Nothing to emit
+		msg.Ignore = true
+		return msg
+	}
+
+	for _, payload := range payloads[1:] {
+		c := msg.Clone()
+		c.Data.Payload = payload
+		msg.exploded = append(msg.exploded, p.transform(c))
+	}
+
+	msg.Data.Payload = payloads[0]
+
+	return p.transform(msg)
+}
+
+// Exploded returns messages which were split from message by Explode of rule, except for message itself.
+// It is only available to Processor.Process, as the others are passed to output handler one by one.
+func (m *Message) Exploded() []*Message {
+	return m.exploded
+}
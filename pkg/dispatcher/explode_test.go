@@ -0,0 +1,83 @@
+package dispatcher
+
+import (
+	"testing"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	product_sdk "github.com/BrobridgeOrg/gravity-sdk/v2/product"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_Explode(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	r := rule_manager.NewRule(product_sdk.NewRule())
+	r.Event = "orderCreated"
+	r.Product = "OrderItems"
+	r.PrimaryKey = []string{"id", "sku"}
+	r.Explode = "items"
+	r.SchemaConfig = map[string]interface{}{
+		"id":       map[string]interface{}{"type": "int"},
+		"customer": map[string]interface{}{"type": "string"},
+		"sku":      map[string]interface{}{"type": "string"},
+		"qty":      map[string]interface{}{"type": "int"},
+	}
+
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(r)
+
+	done := make(chan *Message, 4)
+	p := NewProcessor(
+		WithRuleManager(rm),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event: "orderCreated",
+		RawPayload: []byte(`{
+			"id": 101,
+			"customer": "fred",
+			"items": [
+				{ "sku": "A-1", "qty": 2 },
+				{ "sku": "B-2", "qty": 1 }
+			]
+		}`),
+	})
+
+	msg := NewMessage()
+	msg.Event = "orderCreated"
+	msg.Raw = raw
+	p.Push(msg)
+
+	for _, sku := range []string{"A-1", "B-2"} {
+		result := <-done
+		if !assert.Nil(t, result.Error) || !assert.False(t, result.Ignore) {
+			return
+		}
+
+		content, err := result.ProductEvent.GetContent()
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		if v, err := GetFieldValue(content, "id"); assert.Nil(t, err) {
+			assert.Equal(t, int64(101), v)
+		}
+
+		if v, err := GetFieldValue(content, "customer"); assert.Nil(t, err) {
+			assert.Equal(t, "fred", v)
+		}
+
+		if v, err := GetFieldValue(content, "sku"); assert.Nil(t, err) {
+			assert.Equal(t, sku, v)
+		}
+
+		_, err = GetFieldValue(content, "items")
+		assert.NotNil(t, err)
+	}
+}
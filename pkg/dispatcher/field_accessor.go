@@ -0,0 +1,198 @@
+package dispatcher
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	gravity_sdk_types_product_event "github.com/BrobridgeOrg/gravity-sdk/v2/types/product_event"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+)
+
+var (
+	ErrRecordNotAvailable = errors.New("record is not available")
+	ErrInvalidFieldPath   = errors.New("invalid field path")
+)
+
+func (m *Message) getRecord() (*record_type.Record, error) {
+
+	if m.ProductEvent == nil {
+		return nil, ErrRecordNotAvailable
+	}
+
+	if m.record == nil {
+		r, err := m.ProductEvent.GetContent()
+		if err != nil {
+			return nil, err
+		}
+
+		m.record = r
+	}
+
+	return m.record, nil
+}
+
+// GetField returns value of field in processed record by path, such as "nested.nested_id", "tags[1]" or "tags.1".
+func (m *Message) GetField(path string) (interface{}, bool) {
+
+	r, err := m.getRecord()
+	if err != nil {
+		return nil, false
+	}
+
+	v, err := lookupValue(r.Payload, record_type.ParsePath(path), false)
+	if err != nil {
+		return nil, false
+	}
+
+	return record_type.GetValueData(v), true
+}
+
+// SetField updates value of field in processed record by path, maps on the path are created if they don't exist.
+// Product event and output message are updated as well, but primary key and partition remain unchanged.
+func (m *Message) SetField(path string, value interface{}) error {
+
+	r, err := m.getRecord()
+	if err != nil {
+		return err
+	}
+
+	tokens := record_type.ParsePath(path)
+	if len(tokens) == 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidFieldPath, path)
+	}
+
+	parent, err := lookupValue(r.Payload, tokens[:len(tokens)-1], true)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidFieldPath, path, err)
+	}
+
+	err = setValue(parent, tokens[len(tokens)-1], value)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidFieldPath, path, err)
+	}
+
+	return m.writeRecord(r)
+}
+
+func (m *Message) writeRecord(r *record_type.Record) error {
+
+	err := m.ProductEvent.SetContent(r)
+	if err != nil {
+		return err
+	}
+
+	raw, err := gravity_sdk_types_product_event.Marshal(m.ProductEvent)
+	if err != nil {
+		return err
+	}
+
+	m.RawProductEvent = raw
+
+	if m.OutputMsg != nil {
+		m.OutputMsg.Data = raw
+	}
+
+	return nil
+}
+
+func lookupValue(v *record_type.Value, tokens []record_type.PathToken, create bool) (*record_type.Value, error) {
+
+	for _, token := range tokens {
+
+		switch v.Type {
+		case record_type.DataType_MAP:
+
+			field := record_type.GetField(v.Map.Fields, token.Value)
+			if field == nil {
+				if !create {
+					return nil, record_type.ErrNotFoundKey
+				}
+
+				field = &record_type.Field{
+					Name: token.Value,
+					Value: &record_type.Value{
+						Type: record_type.DataType_MAP,
+						Map:  &record_type.MapValue{},
+					},
+				}
+
+				v.Map.Fields = append(v.Map.Fields, field)
+			}
+
+			v = field.Value
+
+		case record_type.DataType_ARRAY:
+
+			index, err := arrayIndex(v, token)
+			if err != nil {
+				return nil, err
+			}
+
+			v = v.Array.Elements[index]
+
+		default:
+			return nil, record_type.ErrNotFoundKey
+		}
+	}
+
+	return v, nil
+}
+
+func setValue(parent *record_type.Value, token record_type.PathToken, value interface{}) error {
+
+	newValue, err := record_type.GetValueFromInterface(value)
+	if err != nil {
+		return err
+	}
+
+	return putValue(parent, token, newValue)
+}
+
+func putValue(parent *record_type.Value, token record_type.PathToken, newValue *record_type.Value) error {
+
+	switch parent.Type {
+	case record_type.DataType_MAP:
+
+		field := record_type.GetField(parent.Map.Fields, token.Value)
+		if field == nil {
+			parent.Map.Fields = append(parent.Map.Fields, &record_type.Field{
+				Name:  token.Value,
+				Value: newValue,
+			})
+
+			return nil
+		}
+
+		field.Value = newValue
+
+	case record_type.DataType_ARRAY:
+
+		index, err := arrayIndex(parent, token)
+		if err != nil {
+			return err
+		}
+
+		parent.Array.Elements[index] = newValue
+
+	default:
+		return record_type.ErrNotFoundKey
+	}
+
+	return nil
+}
+
+// arrayIndex accepts both of "tags[1]" and "tags.1" for elements of array.
+func arrayIndex(v *record_type.Value, token record_type.PathToken) (int, error) {
+
+	index, err := strconv.Atoi(token.Value)
+	if err != nil {
+		return 0, fmt.Errorf("expected array index, but got %s", token.Value)
+	}
+
+	if index < 0 || index >= len(v.Array.Elements) {
+		return 0, fmt.Errorf("index out of bounds %s", token.Value)
+	}
+
+	return index, nil
+}
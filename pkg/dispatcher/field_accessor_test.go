@@ -0,0 +1,99 @@
+package dispatcher
+
+import (
+	"testing"
+
+	gravity_sdk_types_product_event "github.com/BrobridgeOrg/gravity-sdk/v2/types/product_event"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func CreateProcessedTestMessage(t *testing.T, payload string) *Message {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor()
+	defer p.Close()
+
+	testData := MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(payload),
+	}
+
+	msg := CreateTestMessage()
+	msg.Raw, _ = json.Marshal(testData)
+
+	result, err := p.Process(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestMessage_GetField(t *testing.T) {
+
+	msg := CreateProcessedTestMessage(t, `{"id":101,"nested":{"nested_id":"x"},"tags":["a","b"]}`)
+
+	v, ok := msg.GetField("nested.nested_id")
+	assert.True(t, ok)
+	assert.Equal(t, "x", v)
+
+	v, ok = msg.GetField("tags[1]")
+	assert.True(t, ok)
+	assert.Equal(t, "b", v)
+
+	v, ok = msg.GetField("tags.0")
+	assert.True(t, ok)
+	assert.Equal(t, "a", v)
+
+	_, ok = msg.GetField("tags[2]")
+	assert.False(t, ok)
+
+	_, ok = msg.GetField("nested.notExist")
+	assert.False(t, ok)
+}
+
+func TestMessage_SetField(t *testing.T) {
+
+	msg := CreateProcessedTestMessage(t, `{"id":101,"nested":{"nested_id":"x"},"tags":["a","b"]}`)
+
+	assert.Nil(t, msg.SetField("nested.nested_id", "y"))
+	assert.Nil(t, msg.SetField("tags[1]", "c"))
+	assert.Nil(t, msg.SetField("extra.value", "z"))
+	assert.ErrorIs(t, msg.SetField("tags[5]", "d"), ErrInvalidFieldPath)
+	assert.ErrorIs(t, msg.SetField("id.value", "d"), ErrInvalidFieldPath)
+
+	v, _ := msg.GetField("nested.nested_id")
+	assert.Equal(t, "y", v)
+
+	// Changes should be written to output message
+	var pe gravity_sdk_types_product_event.ProductEvent
+	err := gravity_sdk_types_product_event.Unmarshal(msg.OutputMsg.Data, &pe)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	r, err := pe.GetContent()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	v, _ = r.GetValueDataByPath("nested.nested_id")
+	assert.Equal(t, "y", v)
+
+	v, _ = r.GetValueDataByPath("tags[1]")
+	assert.Equal(t, "c", v)
+
+	v, _ = r.GetValueDataByPath("extra.value")
+	assert.Equal(t, "z", v)
+}
+
+func TestMessage_SetFieldWithoutRecord(t *testing.T) {
+
+	msg := NewMessage()
+
+	_, ok := msg.GetField("id")
+	assert.False(t, ok)
+	assert.Equal(t, ErrRecordNotAvailable, msg.SetField("id", 1))
+}
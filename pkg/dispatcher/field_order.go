@@ -0,0 +1,78 @@
+package dispatcher
+
+import (
+	"sort"
+	"strings"
+
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+)
+
+// orderFields sorts fields of record deterministically, since they come from map in no particular order.
+// Primary key fields are placed first in order of keys, and the rest are sorted by name with internal fields
+// such as "$removedFields" at the end. Fields of maps in arrays are sorted by name as well, so every element
+// has the same order.
+func orderFields(r *record_type.Record, primaryKeys []string) {
+
+	fields := r.Payload.Map.Fields
+
+	rank := func(name string) int {
+		for i, key := range primaryKeys {
+			if key == name {
+				return i
+			}
+		}
+
+		if strings.HasPrefix(name, "$") {
+			return len(primaryKeys) + 1
+		}
+
+		return len(primaryKeys)
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+
+		ri, rj := rank(fields[i].Name), rank(fields[j].Name)
+		if ri != rj {
+			return ri < rj
+		}
+
+		return fields[i].Name < fields[j].Name
+	})
+
+	for _, field := range fields {
+		orderElements(field.Value)
+	}
+}
+
+// orderElements sorts fields of maps in array by name, including arrays nested in elements.
+func orderElements(v *record_type.Value) {
+
+	if v == nil || v.Type != record_type.DataType_ARRAY || v.Array == nil {
+		return
+	}
+
+	for _, ele := range v.Array.Elements {
+
+		if ele == nil {
+			continue
+		}
+
+		switch ele.Type {
+		case record_type.DataType_ARRAY:
+			orderElements(ele)
+		case record_type.DataType_MAP:
+			if ele.Map == nil {
+				continue
+			}
+
+			fields := ele.Map.Fields
+			sort.Slice(fields, func(i, j int) bool {
+				return fields[i].Name < fields[j].Name
+			})
+
+			for _, field := range fields {
+				orderElements(field.Value)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package dispatcher
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// heartbeat reports liveness while processor is idle, so consumers are able to tell no data from stalled
+// pipeline. Processing only marks activity, and checking runs in its own goroutine.
+type heartbeat struct {
+	fn       func()
+	interval time.Duration
+	active   atomic.Bool
+	done     chan struct{}
+}
+
+// WithHeartbeat calls fn every interval in which no message was processed and no message is pending. It never
+// fires while processor is busy.
+func WithHeartbeat(interval time.Duration, fn func()) func(*Processor) {
+	return func(p *Processor) {
+		p.heartbeat.interval = interval
+		p.heartbeat.fn = fn
+	}
+}
+
+func (hb *heartbeat) touch() {
+	if hb.fn != nil {
+		hb.active.Store(true)
+	}
+}
+
+func (hb *heartbeat) start(depth *atomic.Int64) {
+
+	if hb.fn == nil || hb.interval <= 0 {
+		return
+	}
+
+	// Goroutine keeps its own reference, since stop clears the field
+	done := make(chan struct{})
+	hb.done = done
+
+	go func() {
+
+		ticker := time.NewTicker(hb.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+
+				// Messages were processed since the last tick
+				if hb.active.Swap(false) || depth.Load() > 0 {
+					continue
+				}
+
+				hb.fn()
+			}
+		}
+	}()
+}
+
+func (hb *heartbeat) stop() {
+
+	if hb.done == nil {
+		return
+	}
+
+	close(hb.done)
+	hb.done = nil
+}
@@ -0,0 +1,50 @@
+package dispatcher
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_Heartbeat(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	var beats atomic.Int64
+	outputs := make(chan struct{}, 40)
+
+	p := NewProcessor(
+		WithHeartbeat(10*time.Millisecond, func() {
+			beats.Add(1)
+		}),
+		WithOutputHandler(func(msg *Message) {
+			// Slow consumer keeps processor busy
+			time.Sleep(5 * time.Millisecond)
+			outputs <- struct{}{}
+		}),
+	)
+	defer p.Close()
+
+	// Idle
+	assert.Eventually(t, func() bool {
+		return beats.Load() >= 2
+	}, time.Second, time.Millisecond)
+
+	// Busy
+	pushTestMessages(p, 40)
+	before := beats.Load()
+
+	for i := 0; i < 40; i++ {
+		<-outputs
+	}
+
+	assert.Equal(t, before, beats.Load())
+
+	// Idle again
+	assert.Eventually(t, func() bool {
+		return beats.Load() > before
+	}, time.Second, time.Millisecond)
+}
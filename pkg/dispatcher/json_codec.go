@@ -0,0 +1,38 @@
+package dispatcher
+
+import (
+	"bytes"
+	encoding_json "encoding/json"
+)
+
+// JSONCodec encodes and decodes JSON for processor. Numbers of payload are expected to be decoded as
+// json.Number, or float64 if codec doesn't support that, which loses precision of large integers.
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// DefaultJSONCodec is compatible with encoding/json but faster.
+var DefaultJSONCodec JSONCodec = jsonPayload
+
+// StdJSONCodec uses encoding/json of standard library.
+var StdJSONCodec JSONCodec = stdJSONCodec{}
+
+type stdJSONCodec struct{}
+
+func (stdJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return encoding_json.Marshal(v)
+}
+
+func (stdJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := encoding_json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// WithJSONCodec sets codec for decoding incoming messages.
+func WithJSONCodec(codec JSONCodec) func(*Processor) {
+	return func(p *Processor) {
+		p.codec = codec
+	}
+}
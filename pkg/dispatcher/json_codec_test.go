@@ -0,0 +1,55 @@
+package dispatcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type testJSONCodec struct {
+	JSONCodec
+	unmarshalCount int
+}
+
+func (c *testJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshalCount++
+	return c.JSONCodec.Unmarshal(data, v)
+}
+
+func TestProcessor_JSONCodec(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	codec := &testJSONCodec{
+		JSONCodec: StdJSONCodec,
+	}
+
+	p := NewProcessor(WithJSONCodec(codec))
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":9007199254740993,"name":"fred"}`),
+	})
+
+	msg := CreateTestMessage()
+	msg.Raw = raw
+
+	result, err := p.Process(msg)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// Both of message and payload were decoded by codec
+	assert.Equal(t, 2, codec.unmarshalCount)
+
+	r, err := result.ProductEvent.GetContent()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if v, err := GetFieldValue(r, "id"); assert.Nil(t, err) {
+		assert.Equal(t, int64(9007199254740993), v)
+	}
+}
@@ -1,17 +1,25 @@
 package dispatcher
 
 import (
+	"context"
+	encoding_json "encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
 	gravity_sdk_types_product_event "github.com/BrobridgeOrg/gravity-sdk/v2/types/product_event"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
 	"github.com/BrobridgeOrg/schemer"
 
 	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
 )
 
 type Message struct {
+	Context         context.Context
 	ID              string
 	Publisher       nats.JetStreamContext
 	Msg             *nats.Msg
@@ -26,12 +34,25 @@ type Message struct {
 	RawProductEvent []byte
 	TargetSchema    *schemer.Schema
 	OutputMsg       *nats.Msg
+	OutputSubject   string
+	EventTime       time.Time
+	Late            bool
+	Sequence        uint64
+	CorrelationID   string
 	Ignore          bool
+	Dropped         DropReason
+	Error           error
+
+	metadata *Metadata
+	record   *record_type.Record
+	logger   *zap.Logger
+	exploded []*Message
 }
 
 type MessageRawData struct {
-	Event      string `json:"event"`
-	RawPayload []byte `json:"payload"`
+	Event         string `json:"event"`
+	RawPayload    []byte `json:"payload"`
+	CorrelationID string `json:"correlationId,omitempty"`
 	//	PrimaryKey []byte
 	Payload map[string]interface{}
 }
@@ -51,9 +72,13 @@ func NewMessage() *Message {
 }
 
 func (m *Message) ParseRawData() error {
+	return m.parseRawData(DefaultJSONCodec, 0, DefaultMaxNestingDepth)
+}
+
+func (m *Message) parseRawData(codec JSONCodec, maxPayloadSize int, maxNestingDepth int) error {
 
 	// Parsing raw data
-	err := json.Unmarshal(m.Raw, &m.Data)
+	err := codec.Unmarshal(m.Raw, &m.Data)
 	if err != nil {
 		return err
 	}
@@ -62,15 +87,59 @@ func (m *Message) ParseRawData() error {
 		return errors.New("Empty payload")
 	}
 
+	// Reject before payload is expanded in memory
+	if maxPayloadSize > 0 && len(m.Data.RawPayload) > maxPayloadSize {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d bytes", ErrPayloadTooLarge, len(m.Data.RawPayload), maxPayloadSize)
+	}
+
+	if maxNestingDepth > 0 {
+		err = checkNestingDepth(m.Data.RawPayload, maxNestingDepth)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Parsing payload
-	err = json.Unmarshal(m.Data.RawPayload, &m.Data.Payload)
+	err = codec.Unmarshal(m.Data.RawPayload, &m.Data.Payload)
 	if err != nil {
 		return err
 	}
 
+	normalizeNumbers(m.Data.Payload)
+
 	return nil
 }
 
+// normalizeNumbers replaces json.Number with int64 or uint64 if possible to keep precision of
+// large integers, and float64 otherwise.
+func normalizeNumbers(v interface{}) interface{} {
+
+	switch d := v.(type) {
+	case encoding_json.Number:
+		if i, err := strconv.ParseInt(string(d), 10, 64); err == nil {
+			return i
+		}
+
+		if u, err := strconv.ParseUint(string(d), 10, 64); err == nil {
+			return u
+		}
+
+		f, _ := strconv.ParseFloat(string(d), 64)
+
+		return f
+	case map[string]interface{}:
+		for k, ele := range d {
+			d[k] = normalizeNumbers(ele)
+		}
+	case []interface{}:
+		for i, ele := range d {
+			d[i] = normalizeNumbers(ele)
+		}
+	}
+
+	return v
+}
+
 func (m *Message) Release() {
 	m.Reset()
 	MessagePool.Put(m)
@@ -88,6 +157,7 @@ func (m *Message) Reset() {
 		m.OutputMsg = nil
 	}
 
+	m.Context = nil
 	m.Msg = nil
 	m.AckFuture = nil
 	m.Rule = nil
@@ -95,13 +165,140 @@ func (m *Message) Reset() {
 	m.ProductEvent = nil
 	m.OutputMsg = nil
 	m.TargetSchema = nil
+	m.OutputSubject = ""
+	m.EventTime = time.Time{}
+	m.Late = false
+	m.Sequence = 0
+	m.CorrelationID = ""
 	m.Event = ""
 	m.Raw = []byte("")
 	m.RawProductEvent = []byte("")
 	m.Ignore = false
-	m.Data = &MessageRawData{
-		Payload: make(map[string]interface{}),
+	m.Dropped = ""
+	m.Error = nil
+	m.metadata = nil
+	m.record = nil
+	m.logger = nil
+	m.exploded = nil
+
+	// Reuse payload map rather than allocating a new one
+	if m.Data == nil || m.Data.Payload == nil {
+		m.Data = &MessageRawData{
+			Payload: make(map[string]interface{}),
+		}
+		return
+	}
+
+	m.Data.Event = ""
+	m.Data.RawPayload = nil
+	m.Data.CorrelationID = ""
+	clear(m.Data.Payload)
+}
+
+// Clone returns a deep copy of message, so that handlers are able to modify it without affecting the original.
+// NATS message and publisher are shared as they refer to the same incoming event.
+func (m *Message) Clone() *Message {
+
+	c := NewMessage()
+	c.Context = m.Context
+	c.ID = m.ID
+	c.Publisher = m.Publisher
+	c.Msg = m.Msg
+	c.Event = m.Event
+	c.Product = m.Product
+	c.Rule = m.Rule
+	c.Raw = cloneBytes(m.Raw)
+	c.Partition = m.Partition
+	c.RawProductEvent = cloneBytes(m.RawProductEvent)
+	c.TargetSchema = m.TargetSchema
+	c.OutputSubject = m.OutputSubject
+	c.EventTime = m.EventTime
+	c.Late = m.Late
+	c.Sequence = m.Sequence
+	c.CorrelationID = m.CorrelationID
+	c.logger = m.logger
+	c.Ignore = m.Ignore
+	c.Dropped = m.Dropped
+	c.Error = m.Error
+
+	if m.metadata != nil {
+		md := *m.metadata
+		c.metadata = &md
 	}
+
+	if m.Data != nil {
+		c.Data = &MessageRawData{
+			Event:      m.Data.Event,
+			RawPayload: cloneBytes(m.Data.RawPayload),
+		}
+
+		if m.Data.Payload != nil {
+			c.Data.Payload = cloneValue(m.Data.Payload).(map[string]interface{})
+		}
+	}
+
+	if m.ProductEvent != nil {
+		pe := productEventPool.Get().(*gravity_sdk_types_product_event.ProductEvent)
+		pe.Reset()
+		pe.EventName = m.ProductEvent.EventName
+		pe.Table = m.ProductEvent.Table
+		pe.Method = m.ProductEvent.Method
+		pe.PrimaryKeys = append([]string(nil), m.ProductEvent.PrimaryKeys...)
+		pe.PrimaryKey = cloneBytes(m.ProductEvent.PrimaryKey)
+		pe.Data = cloneBytes(m.ProductEvent.Data)
+		c.ProductEvent = pe
+	}
+
+	if m.OutputMsg != nil {
+		c.OutputMsg = natsMsgPool.Get().(*nats.Msg)
+		c.OutputMsg.Subject = m.OutputMsg.Subject
+		c.OutputMsg.Data = cloneBytes(m.OutputMsg.Data)
+		c.OutputMsg.Header = nil
+		if m.OutputMsg.Header != nil {
+			c.OutputMsg.Header = make(nats.Header, len(m.OutputMsg.Header))
+			for k, v := range m.OutputMsg.Header {
+				c.OutputMsg.Header[k] = append([]string(nil), v...)
+			}
+		}
+	}
+
+	return c
+}
+
+func cloneBytes(b []byte) []byte {
+
+	if b == nil {
+		return nil
+	}
+
+	c := make([]byte, len(b))
+	copy(c, b)
+
+	return c
+}
+
+func cloneValue(v interface{}) interface{} {
+
+	switch d := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(d))
+		for k, v := range d {
+			m[k] = cloneValue(v)
+		}
+
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(d))
+		for i, v := range d {
+			a[i] = cloneValue(v)
+		}
+
+		return a
+	case []byte:
+		return cloneBytes(d)
+	}
+
+	return v
 }
 
 func (m *Message) Ack() error {
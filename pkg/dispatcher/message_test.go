@@ -0,0 +1,75 @@
+package dispatcher
+
+import (
+	"testing"
+
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestMessage_Clone(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	done := make(chan *Message)
+
+	p := NewProcessor(
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	testData := MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"name":"fred","tags":["a","b"]}`),
+	}
+
+	msg := CreateTestMessage()
+	raw, _ := json.Marshal(testData)
+	msg.Raw = raw
+
+	p.Push(msg)
+
+	orig := <-done
+	c := orig.Clone()
+
+	// Mutate record of clone
+	r, err := c.ProductEvent.GetContent()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, field := range r.Payload.Map.Fields {
+		if field.Name == "name" {
+			field.Value, _ = record_type.CreateValue(record_type.DataType_STRING, "stacy")
+		}
+	}
+	c.ProductEvent.SetContent(r)
+	c.ProductEvent.PrimaryKey[0] = 'X'
+
+	// Mutate parsed payload and raw data of clone
+	c.Data.Payload["name"] = "stacy"
+	c.Data.Payload["tags"].([]interface{})[0] = "z"
+	c.Raw[0] = 'X'
+
+	// Original should be unchanged
+	origRecord, err := orig.ProductEvent.GetContent()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if v, err := GetFieldValue(origRecord, "name"); assert.Nil(t, err) {
+		assert.Equal(t, "fred", v)
+	}
+
+	if v, err := GetFieldValue(r, "name"); assert.Nil(t, err) {
+		assert.Equal(t, "stacy", v)
+	}
+
+	assert.Equal(t, "fred", orig.Data.Payload["name"])
+	assert.Equal(t, "a", orig.Data.Payload["tags"].([]interface{})[0])
+	assert.Equal(t, raw, orig.Raw)
+	assert.NotEqual(t, orig.ProductEvent.PrimaryKey, c.ProductEvent.PrimaryKey)
+}
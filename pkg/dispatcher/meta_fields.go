@@ -0,0 +1,109 @@
+package dispatcher
+
+import (
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+)
+
+// RemovedFieldsField lists paths of fields which were removed by partial update.
+const RemovedFieldsField = "$removedFields"
+
+// MetaFieldNames are names of meta fields in emitted partial updates, for consumers which reserve names
+// starting with "$". Empty names stay as default.
+type MetaFieldNames struct {
+	RemovedFields string
+	Append        string
+	RemoveElement string
+}
+
+var DefaultMetaFieldNames = MetaFieldNames{
+	RemovedFields: RemovedFieldsField,
+	Append:        rule_manager.ArrayAppendField,
+	RemoveElement: rule_manager.ArrayRemoveElementField,
+}
+
+// WithMetaFieldNames renames meta fields of emitted records, such as "__deleted__" for "$removedFields". Payload
+// of incoming messages still uses default names. Projections always keep fields starting with "$" only, so
+// renamed fields have to be allowed explicitly.
+func WithMetaFieldNames(names MetaFieldNames) func(*Processor) {
+	return func(p *Processor) {
+		names = names.withDefaults()
+		if names == DefaultMetaFieldNames {
+			p.metaFieldNames = nil
+			return
+		}
+
+		p.metaFieldNames = &names
+	}
+}
+
+func (names MetaFieldNames) withDefaults() MetaFieldNames {
+
+	if len(names.RemovedFields) == 0 {
+		names.RemovedFields = DefaultMetaFieldNames.RemovedFields
+	}
+
+	if len(names.Append) == 0 {
+		names.Append = DefaultMetaFieldNames.Append
+	}
+
+	if len(names.RemoveElement) == 0 {
+		names.RemoveElement = DefaultMetaFieldNames.RemoveElement
+	}
+
+	return names
+}
+
+func (names MetaFieldNames) pairs() [][2]string {
+	return [][2]string{
+		{DefaultMetaFieldNames.RemovedFields, names.RemovedFields},
+		{DefaultMetaFieldNames.Append, names.Append},
+		{DefaultMetaFieldNames.RemoveElement, names.RemoveElement},
+	}
+}
+
+// rename turns default names of meta fields at top level of record into configured ones.
+func (names MetaFieldNames) rename(r *record_type.Record) {
+
+	for _, field := range r.Payload.Map.Fields {
+		for _, pair := range names.pairs() {
+			if field.Name == pair[0] {
+				field.Name = pair[1]
+				break
+			}
+		}
+	}
+}
+
+// ApplyUpdate applies update whose meta fields are named by names like ApplyUpdate. Update is not modified.
+func (names MetaFieldNames) ApplyUpdate(r *record_type.Record, update *record_type.Record) error {
+
+	names = names.withDefaults()
+
+	fields := make([]*record_type.Field, len(update.Payload.Map.Fields))
+	for i, field := range update.Payload.Map.Fields {
+
+		fields[i] = field
+		for _, pair := range names.pairs() {
+			if field.Name == pair[1] {
+				fields[i] = &record_type.Field{
+					Name:  pair[0],
+					Value: field.Value,
+				}
+				break
+			}
+		}
+	}
+
+	restored := &record_type.Record{
+		Meta: update.Meta,
+		Payload: &record_type.Value{
+			Type: record_type.DataType_MAP,
+			Map: &record_type.MapValue{
+				Fields: fields,
+			},
+		},
+	}
+
+	return ApplyUpdate(r, restored)
+}
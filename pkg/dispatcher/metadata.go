@@ -0,0 +1,40 @@
+package dispatcher
+
+import "time"
+
+// Metadata describes where record came from, which is kept apart from data payload.
+type Metadata struct {
+	RuleID        string
+	Product       string
+	Event         string
+	SourceSubject string
+	ProcessedAt   time.Time
+}
+
+// WithMetadata enables attaching lineage metadata to processed messages.
+func WithMetadata(enabled bool) func(*Processor) {
+	return func(p *Processor) {
+		p.metadata = enabled
+	}
+}
+
+// Metadata returns lineage of message. It returns nil if metadata was not enabled on processor.
+func (m *Message) Metadata() *Metadata {
+	return m.metadata
+}
+
+func (p *Processor) attachMetadata(msg *Message) {
+
+	md := &Metadata{
+		RuleID:      msg.Rule.ID,
+		Product:     msg.Rule.Product,
+		Event:       msg.Data.Event,
+		ProcessedAt: time.Now(),
+	}
+
+	if msg.Msg != nil {
+		md.SourceSubject = msg.Msg.Subject
+	}
+
+	msg.metadata = md
+}
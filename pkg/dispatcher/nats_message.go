@@ -0,0 +1,56 @@
+package dispatcher
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// EventHeader carries event name, which takes precedence over the last token of subject.
+	EventHeader = "Gravity-Event"
+
+	// CorrelationIDHeader carries correlation ID of event.
+	CorrelationIDHeader = "Gravity-Correlation-Id"
+)
+
+var ErrEventNotFound = errors.New("event not found")
+
+// NewMessageFromNats builds message from NATS message which carries payload of event. Event name is taken from
+// EventHeader, or the last token of subject such as "$GVT.default.EVENT.dataCreated". Payload is decompressed if
+// it was encoded with s2.
+func NewMessageFromNats(m *nats.Msg, rule *rule_manager.Rule) (*Message, error) {
+
+	eventName := m.Header.Get(EventHeader)
+	if len(eventName) == 0 {
+		eventName = m.Subject[strings.LastIndexByte(m.Subject, '.')+1:]
+	}
+
+	if len(eventName) == 0 {
+		return nil, ErrEventNotFound
+	}
+
+	payload, err := decodeMessageData(m)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := DefaultJSONCodec.Marshal(MessageRawData{
+		Event:         eventName,
+		RawPayload:    payload,
+		CorrelationID: m.Header.Get(CorrelationIDHeader),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	msg := NewMessage()
+	msg.Event = eventName
+	msg.Msg = m
+	msg.Rule = rule
+	msg.Raw = raw
+
+	return msg, nil
+}
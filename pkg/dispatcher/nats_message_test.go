@@ -0,0 +1,74 @@
+package dispatcher
+
+import (
+	"testing"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/klauspost/compress/s2"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestNewMessageFromNats(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	r := CreateTestRule()
+
+	// Event from subject
+	msg, err := NewMessageFromNats(&nats.Msg{
+		Subject: "$GVT.default.EVENT.dataCreated",
+		Data:    []byte(`{"id":101,"name":"fred"}`),
+	}, r)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, "dataCreated", msg.Event)
+	assert.Equal(t, r, msg.Rule)
+
+	if assert.Nil(t, msg.ParseRawData()) {
+		assert.Equal(t, "dataCreated", msg.Data.Event)
+		assert.Equal(t, "fred", msg.Data.Payload["name"])
+		assert.Equal(t, int64(101), msg.Data.Payload["id"])
+	}
+
+	// Message which doesn't come from JetStream is able to be processed as well
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(r)
+
+	msg, err = NewProcessor().Process(msg)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "TestDataProduct", msg.ProductEvent.Table)
+	}
+
+	// Event and correlation ID from headers with compressed payload
+	header := nats.Header{}
+	header.Set(EventHeader, "dataUpdated")
+	header.Set(CorrelationIDHeader, "req-1")
+	header.Set("Content-Encoding", "s2")
+
+	msg, err = NewMessageFromNats(&nats.Msg{
+		Subject: "events",
+		Header:  header,
+		Data:    s2.Encode(nil, []byte(`{"id":102}`)),
+	}, r)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, "dataUpdated", msg.Event)
+
+	if assert.Nil(t, msg.ParseRawData()) {
+		assert.Equal(t, "req-1", msg.Data.CorrelationID)
+		assert.Equal(t, int64(102), msg.Data.Payload["id"])
+	}
+
+	// No event
+	_, err = NewMessageFromNats(&nats.Msg{
+		Subject: "events.",
+		Data:    []byte(`{}`),
+	}, r)
+	assert.ErrorIs(t, err, ErrEventNotFound)
+}
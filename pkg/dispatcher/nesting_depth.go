@@ -0,0 +1,57 @@
+package dispatcher
+
+import (
+	"fmt"
+)
+
+// DefaultMaxNestingDepth is far beyond structures of real payloads, while it keeps decoding and schema
+// from recursing without bound.
+const DefaultMaxNestingDepth = 128
+
+// WithMaxNestingDepth sets how deep maps and arrays of payload are able to be nested, which is
+// DefaultMaxNestingDepth by default. Messages with deeper payload are passed to error handler without being
+// parsed, and zero disables the limit.
+func WithMaxNestingDepth(depth int) func(*Processor) {
+	return func(p *Processor) {
+		p.maxNestingDepth = depth
+	}
+}
+
+// checkNestingDepth scans raw JSON for depth of maps and arrays without decoding it, so pathological payload
+// is rejected before anything recurses into it.
+func checkNestingDepth(data []byte, maxDepth int) error {
+
+	depth := 0
+	inString := false
+	escaped := false
+
+	for _, c := range data {
+
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return fmt.Errorf("%w: exceeds limit of %d levels", ErrPayloadTooDeep, maxDepth)
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+
+	return nil
+}
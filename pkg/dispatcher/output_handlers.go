@@ -0,0 +1,84 @@
+package dispatcher
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+var ErrOutputHandlerPanic = errors.New("output handler panicked")
+
+// WithOutputHandlers sets handlers which are called one by one in given order for every message, such as
+// a sink along with an audit log. Message must not be released by any handler but the last one.
+func WithOutputHandlers(handlers ...func(*Message)) func(*Processor) {
+	return func(p *Processor) {
+		p.outputHandler = func(msg *Message) {
+			for _, fn := range handlers {
+				p.callOutputHandler(fn, msg)
+			}
+		}
+	}
+}
+
+// WithOutputHandlerFor sets handler for messages of product, such as a sink dedicated to the product. Messages
+// of products without their own handler are passed to output handler.
+func WithOutputHandlerFor(product string, fn func(*Message)) func(*Processor) {
+	return func(p *Processor) {
+		if p.productOutputHandlers == nil {
+			p.productOutputHandlers = make(map[string]func(*Message))
+		}
+
+		p.productOutputHandlers[product] = func(msg *Message) {
+			p.callOutputHandler(fn, msg)
+		}
+	}
+}
+
+// WithIsolatedOutputHandlers keeps calling the rest of output handlers if one of them panics. The panic
+// is reported to error handler instead of being propagated.
+func WithIsolatedOutputHandlers(enabled bool) func(*Processor) {
+	return func(p *Processor) {
+		p.isolateOutputHandlers = enabled
+	}
+}
+
+func (p *Processor) callOutputHandler(fn func(*Message), msg *Message) {
+
+	if p.isolateOutputHandlers {
+		defer func() {
+			if r := recover(); r != nil {
+				err := fmt.Errorf("%w: %v", ErrOutputHandlerPanic, r)
+				msg.Logger().Error("Output handler failed",
+					zap.String("event", msg.Event),
+					zap.Error(err),
+				)
+
+				p.errorHandler(msg, err)
+			}
+		}()
+	}
+
+	fn(msg)
+}
+
+// outputHandlerOf returns handler for product of message, which is output handler if product has none.
+func (p *Processor) outputHandlerOf(msg *Message) func(*Message) {
+
+	if len(p.productOutputHandlers) == 0 {
+		return p.outputHandler
+	}
+
+	product := ""
+	if msg.Rule != nil {
+		product = msg.Rule.Product
+	} else if msg.Product != nil {
+		product = msg.Product.Name
+	}
+
+	if fn, ok := p.productOutputHandlers[product]; ok {
+		return fn
+	}
+
+	return p.outputHandler
+}
@@ -0,0 +1,134 @@
+package dispatcher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func pushTestMessages(p *Processor, count int) {
+
+	for i := 0; i < count; i++ {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(fmt.Sprintf(`{"id":%d,"name":"fred"}`, i)),
+		})
+
+		msg := CreateTestMessage()
+		msg.Raw = raw
+
+		p.Push(msg)
+	}
+}
+
+func TestProcessor_OutputHandlers(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	calls := make(chan string, 20)
+
+	p := NewProcessor(
+		WithOutputHandlers(
+			func(msg *Message) {
+				calls <- fmt.Sprintf("sink:%v", msg.Data.Payload["id"])
+			},
+			func(msg *Message) {
+				calls <- fmt.Sprintf("audit:%v", msg.Data.Payload["id"])
+			},
+		),
+	)
+	defer p.Close()
+
+	pushTestMessages(p, 5)
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, fmt.Sprintf("sink:%d", i), <-calls)
+		assert.Equal(t, fmt.Sprintf("audit:%d", i), <-calls)
+	}
+}
+
+func TestProcessor_IsolatedOutputHandlers(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	errs := make(chan error, 2)
+	done := make(chan *Message, 2)
+
+	p := NewProcessor(
+		WithIsolatedOutputHandlers(true),
+		WithErrorHandler(func(msg *Message, err error) {
+			errs <- err
+		}),
+		WithOutputHandlers(
+			func(msg *Message) {
+				panic("sink is gone")
+			},
+			func(msg *Message) {
+				done <- msg
+			},
+		),
+	)
+	defer p.Close()
+
+	pushTestMessages(p, 2)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-done:
+			assert.Nil(t, msg.Error)
+		case <-time.After(time.Second):
+			t.Fatal("message was not passed to the rest of handlers")
+		}
+
+		assert.ErrorIs(t, <-errs, ErrOutputHandlerPanic)
+	}
+}
+
+func TestProcessor_OutputHandlerFor(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	rm := rule_manager.NewRuleManager()
+	for _, product := range []string{"Accounts", "Orders", "Others"} {
+		r := CreateTestRule()
+		r.Event = "created" + product
+		r.Product = product
+		rm.AddRule(r)
+	}
+
+	calls := make(chan string, 3)
+	p := NewProcessor(
+		WithRuleManager(rm),
+		WithOutputHandler(func(msg *Message) {
+			calls <- "default:" + msg.Event
+		}),
+		WithOutputHandlerFor("Accounts", func(msg *Message) {
+			calls <- "accounts:" + msg.Event
+		}),
+		WithOutputHandlerFor("Orders", func(msg *Message) {
+			calls <- "orders:" + msg.Event
+		}),
+	)
+	defer p.Close()
+
+	for _, event := range []string{"createdAccounts", "createdOrders", "createdOthers"} {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      event,
+			RawPayload: []byte(`{"id":101,"name":"fred"}`),
+		})
+
+		msg := NewMessage()
+		msg.Event = event
+		msg.Raw = raw
+
+		p.Push(msg)
+	}
+
+	assert.Equal(t, "accounts:createdAccounts", <-calls)
+	assert.Equal(t, "orders:createdOrders", <-calls)
+	assert.Equal(t, "default:createdOthers", <-calls)
+}
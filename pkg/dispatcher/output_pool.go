@@ -0,0 +1,99 @@
+package dispatcher
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const DefaultOutputPoolBufferSize = 256
+
+// outputPool delivers processed messages on its own workers, so that a slow output never holds transform
+// workers. Messages are routed to workers by partition, so messages with the same primary key keep their order.
+type outputPool struct {
+	size       int
+	bufferSize int
+	queues     []chan []*Message
+	depth      *atomic.Int64
+	wg         sync.WaitGroup
+	mutex      sync.RWMutex
+	closed     bool
+}
+
+// WithOutputPool runs stages, sinks and handlers of output on size workers of their own, which are connected
+// with transform workers by queues of bufferSize messages each. Transforms keep running while outputs are in
+// flight until queues are full. Order is kept only among messages of the same partition.
+func WithOutputPool(size int, bufferSize int) func(*Processor) {
+	return func(p *Processor) {
+		p.outputPool.size = size
+		p.outputPool.bufferSize = bufferSize
+	}
+}
+
+func (op *outputPool) start(deliver func(*Message), depth *atomic.Int64) {
+
+	if op.size <= 0 {
+		return
+	}
+
+	if op.bufferSize <= 0 {
+		op.bufferSize = DefaultOutputPoolBufferSize
+	}
+
+	op.depth = depth
+	op.queues = make([]chan []*Message, op.size)
+	for i := range op.queues {
+
+		queue := make(chan []*Message, op.bufferSize)
+		op.queues[i] = queue
+
+		op.wg.Add(1)
+		go func() {
+			defer op.wg.Done()
+
+			for msgs := range queue {
+				for _, msg := range msgs {
+					deliver(msg)
+				}
+
+				op.depth.Add(-1)
+			}
+		}()
+	}
+}
+
+// dispatch queues message along with records exploded from it, which are delivered by the same worker.
+func (op *outputPool) dispatch(msgs []*Message) {
+
+	op.mutex.RLock()
+	defer op.mutex.RUnlock()
+
+	// Processor was closed, so nothing is delivered anymore, and message is no longer counted as queued
+	if op.closed {
+		op.depth.Add(-1)
+		return
+	}
+
+	op.queues[int(msgs[0].Partition)%op.size] <- msgs
+}
+
+// stop waits for queued messages to be delivered.
+func (op *outputPool) stop() {
+
+	if op.size <= 0 {
+		return
+	}
+
+	op.mutex.Lock()
+	if op.closed {
+		op.mutex.Unlock()
+		return
+	}
+
+	op.closed = true
+	for _, queue := range op.queues {
+		close(queue)
+	}
+	op.mutex.Unlock()
+
+	op.wg.Wait()
+}
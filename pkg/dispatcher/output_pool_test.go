@@ -0,0 +1,141 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_OutputPool(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	const (
+		keys    = 10
+		updates = 10
+	)
+
+	var mutex sync.Mutex
+	received := make(map[string][]string)
+
+	p := NewProcessor(
+		WithOutputPool(4, 8),
+		WithOutputHandler(func(msg *Message) {
+
+			r, err := msg.ProductEvent.GetContent()
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			id, _ := GetFieldValue(r, "id")
+			name, _ := GetFieldValue(r, "name")
+
+			mutex.Lock()
+			key := fmt.Sprint(id)
+			received[key] = append(received[key], name.(string))
+			mutex.Unlock()
+		}),
+	)
+	defer p.Close()
+
+	for i := 0; i < updates; i++ {
+		for id := 0; id < keys; id++ {
+			raw, _ := json.Marshal(MessageRawData{
+				Event:      "dataCreated",
+				RawPayload: []byte(fmt.Sprintf(`{"id":%d,"name":"v%d"}`, id, i)),
+			})
+
+			msg := CreateTestMessage()
+			msg.Raw = raw
+
+			p.Push(msg)
+		}
+	}
+
+	// Flush waits for output pool as well
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, p.Flush(ctx))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// Every message is delivered, and updates of the same key keep their order
+	assert.Len(t, received, keys)
+	for key, names := range received {
+		expected := make([]string, updates)
+		for i := range expected {
+			expected[i] = fmt.Sprintf("v%d", i)
+		}
+
+		assert.Equal(t, expected, names, key)
+	}
+}
+
+func TestOutputPool_DispatchAfterStop(t *testing.T) {
+
+	var depth atomic.Int64
+
+	op := outputPool{
+		size: 2,
+	}
+	op.start(func(*Message) {}, &depth)
+	op.stop()
+
+	// Batch which is dropped isn't left in depth, so flushing never waits for it
+	depth.Add(1)
+
+	msg := NewMessage()
+	op.dispatch([]*Message{msg})
+
+	assert.Equal(t, int64(0), depth.Load())
+}
+
+func benchmarkProcessorSlowSink(b *testing.B, opts ...func(*Processor)) {
+
+	logger = zap.NewNop()
+
+	opts = append(opts, WithOutputHandler(func(msg *Message) {
+		time.Sleep(50 * time.Microsecond)
+	}))
+
+	p := NewProcessor(opts...)
+	defer p.Close()
+
+	rule := CreateTestMessage().Rule
+
+	inputs := make([][]byte, 0, 100)
+	for i := 0; i < cap(inputs); i++ {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(fmt.Sprintf(`{"id":%d,"name":"name-%d"}`, i, i)),
+		})
+
+		inputs = append(inputs, raw)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		msg := NewMessage()
+		msg.Rule = rule
+		msg.Raw = inputs[i%len(inputs)]
+
+		p.Push(msg)
+	}
+
+	p.Flush(context.Background())
+}
+
+func BenchmarkProcessor_SlowSink(b *testing.B) {
+	benchmarkProcessorSlowSink(b)
+}
+
+func BenchmarkProcessor_SlowSinkWithOutputPool(b *testing.B) {
+	benchmarkProcessorSlowSink(b, WithOutputPool(8, DefaultOutputPoolBufferSize))
+}
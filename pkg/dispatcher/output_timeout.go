@@ -0,0 +1,109 @@
+package dispatcher
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultMaxAbandonedOutputs is number of timed out output handler calls which may keep running at the same time.
+const DefaultMaxAbandonedOutputs = 64
+
+var ErrOutputTimeout = errors.New("output handler timed out")
+
+// WithOutputTimeout limits time of output handler for each message. On timeout, message is passed to error
+// handler and the next message is handled without waiting, while the call keeps running in background. So
+// output of later messages may happen before the abandoned call is done, and order of output for the same key
+// is not kept.
+func WithOutputTimeout(timeout time.Duration) func(*Processor) {
+	return func(p *Processor) {
+		p.outputTimeout = timeout
+	}
+}
+
+// WithMaxAbandonedOutputs limits number of timed out output handler calls which keep running. Once the limit
+// is reached, output waits for timed out call to be done as if there was no timeout.
+func WithMaxAbandonedOutputs(max int) func(*Processor) {
+	return func(p *Processor) {
+		p.maxAbandonedOutputs = max
+	}
+}
+
+func (p *Processor) output(msg *Message) {
+
+	handler := p.outputHandlerOf(msg)
+	if p.outputTimeout <= 0 {
+		handler(msg)
+		return
+	}
+
+	// Handler owns message once it was called, so keep what is needed to report it without copying payloads
+	header := *msg
+
+	done := make(chan struct{})
+	go func() {
+		handler(msg)
+		close(done)
+	}()
+
+	timer := time.NewTimer(p.outputTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	snapshot := p.timedOutMessage(&header)
+	snapshot.Logger().Error("Output handler timed out",
+		zap.String("event", snapshot.Event),
+		zap.Duration("timeout", p.outputTimeout),
+	)
+
+	snapshot.Error = ErrOutputTimeout
+	snapshot.Ignore = true
+	p.errorHandler(snapshot, ErrOutputTimeout)
+
+	if p.abandonedOutputs.Add(1) > int64(p.maxAbandonedOutputs) {
+		p.abandonedOutputs.Add(-1)
+
+		snapshot.Logger().Warn("Too many output handler calls timed out, so waiting for output handler",
+			zap.Int("max", p.maxAbandonedOutputs),
+		)
+
+		<-done
+		return
+	}
+
+	go func() {
+		<-done
+		p.abandonedOutputs.Add(-1)
+	}()
+}
+
+// timedOutMessage builds message for error handler from header of message whose output timed out. Payload
+// is parsed again from raw data, which is never written in place, so it shares nothing with the running call.
+func (p *Processor) timedOutMessage(header *Message) *Message {
+
+	m := NewMessage()
+	m.Context = header.Context
+	m.ID = header.ID
+	m.Msg = header.Msg
+	m.Event = header.Event
+	m.Product = header.Product
+	m.Rule = header.Rule
+	m.Raw = cloneBytes(header.Raw)
+	m.Partition = header.Partition
+	m.EventTime = header.EventTime
+	m.Sequence = header.Sequence
+	m.CorrelationID = header.CorrelationID
+	m.logger = header.logger
+
+	if len(m.Raw) > 0 {
+		m.parseRawData(p.codec, p.maxPayloadSize, p.maxNestingDepth)
+	}
+
+	return m
+}
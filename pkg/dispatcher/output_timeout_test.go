@@ -0,0 +1,133 @@
+package dispatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_OutputTimeout(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	errs := make(chan *Message, 1)
+	done := make(chan *Message, 1)
+
+	p := NewProcessor(
+		WithOutputTimeout(20*time.Millisecond),
+		WithErrorHandler(func(msg *Message, err error) {
+			errs <- msg
+		}),
+		WithOutputHandler(func(msg *Message) {
+
+			// Stalled for the first message
+			if msg.Data.Payload["name"] == "stalled" {
+				<-release
+				return
+			}
+
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	for _, name := range []string{"stalled", "fred"} {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(`{"id":101,"name":"` + name + `"}`),
+		})
+
+		msg := CreateTestMessage()
+		msg.Raw = raw
+
+		p.Push(msg)
+	}
+
+	select {
+	case msg := <-errs:
+		assert.ErrorIs(t, msg.Error, ErrOutputTimeout)
+		assert.True(t, msg.Ignore)
+		assert.Equal(t, "stalled", msg.Data.Payload["name"])
+	case <-time.After(time.Second):
+		t.Fatal("message was not timed out")
+	}
+
+	// Next message is not blocked by stalled handler
+	select {
+	case msg := <-done:
+		assert.Nil(t, msg.Error)
+		assert.Equal(t, "fred", msg.Data.Payload["name"])
+	case <-time.After(time.Second):
+		t.Fatal("next message was blocked")
+	}
+}
+
+func TestProcessor_MaxAbandonedOutputs(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	release := make(chan struct{})
+
+	errs := make(chan *Message, 2)
+	done := make(chan *Message, 1)
+
+	p := NewProcessor(
+		WithOutputTimeout(20*time.Millisecond),
+		WithMaxAbandonedOutputs(1),
+		WithErrorHandler(func(msg *Message, err error) {
+			errs <- msg
+		}),
+		WithOutputHandler(func(msg *Message) {
+
+			if msg.Data.Payload["name"] == "stalled" {
+				<-release
+				return
+			}
+
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	for _, name := range []string{"stalled", "stalled", "fred"} {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(`{"id":101,"name":"` + name + `"}`),
+		})
+
+		msg := CreateTestMessage()
+		msg.Raw = raw
+
+		p.Push(msg)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-errs:
+			assert.ErrorIs(t, msg.Error, ErrOutputTimeout)
+		case <-time.After(time.Second):
+			t.Fatal("message was not timed out")
+		}
+	}
+
+	// Limit was reached, so output waits for the second call
+	select {
+	case <-done:
+		t.Fatal("next message was not blocked")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case msg := <-done:
+		assert.Equal(t, "fred", msg.Data.Payload["name"])
+	case <-time.After(time.Second):
+		t.Fatal("next message was blocked")
+	}
+}
@@ -1,14 +1,17 @@
 package dispatcher
 
 import (
+	"errors"
 	"fmt"
-	"hash"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/converter"
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
 	gravity_sdk_types_product_event "github.com/BrobridgeOrg/gravity-sdk/v2/types/product_event"
 	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
 	sequential_task_runner "github.com/BrobridgeOrg/sequential-task-runner"
@@ -23,12 +26,30 @@ const (
 	DefaultProcessorMaxPendingCount = 2048
 )
 
+var (
+	ErrRuleNotFound    = errors.New("rule not found")
+	ErrPayloadTooLarge = errors.New("payload is too large")
+	ErrPayloadTooDeep  = errors.New("payload is nested too deeply")
+)
+
 var productEventPool = sync.Pool{
 	New: func() interface{} {
 		return &gravity_sdk_types_product_event.ProductEvent{}
 	},
 }
 
+var recordPool = sync.Pool{
+	New: func() interface{} {
+		return record_type.NewRecord()
+	},
+}
+
+var hashPool = sync.Pool{
+	New: func() interface{} {
+		return jump.NewCRC64()
+	},
+}
+
 var natsMsgPool = sync.Pool{
 	New: func() interface{} {
 		return &nats.Msg{}
@@ -38,15 +59,55 @@ var natsMsgPool = sync.Pool{
 type Processor struct {
 	runner        *sequential_task_runner.Runner
 	outputHandler func(*Message)
+	errorHandler  func(*Message, error)
+	dropHandler   func(*Message, DropReason)
+	tracer        Tracer
+	stateProvider StateProvider
+	codec         JSONCodec
+	watermark     *watermarkTracker
+	ruleStats     ruleStats
+	rules         atomic.Pointer[rule_manager.RuleManager]
+	metadata      bool
 	domain        string
-	hash          hash.Hash64
+
+	maxPayloadSize        int
+	maxNestingDepth       int
+	outputTimeout         time.Duration
+	maxAbandonedOutputs   int
+	abandonedOutputs      atomic.Int64
+	deprecationHandler    func(*Message, string)
+	isolateOutputHandlers bool
+	subjectPrefix         string
+	sequenceSource        SequenceSource
+	queueDepth            queueDepthGauge
+	errorCollection       rule_manager.ErrorCollectionMode
+	transformTimeout      time.Duration
+	suppressUnchanged     bool
+	sinks                 []Sink
+	productOutputHandlers map[string]func(*Message)
+	selfDescribing        SelfDescribingMode
+	stages                []Stage
+	heartbeat             heartbeat
+	metaFieldNames        *MetaFieldNames
+	outputPool            outputPool
+	attachRaw             bool
+	reorder               reorderBuffer
+
+	// Results are released one at a time, including ones flushed by ResetState
+	releaseMutex sync.Mutex
 }
 
 func NewProcessor(opts ...func(*Processor)) *Processor {
 
 	p := &Processor{
 		outputHandler: func(*Message) {},
-		hash:          jump.NewCRC64(),
+		errorHandler:  func(*Message, error) {},
+		dropHandler:   func(*Message, DropReason) {},
+		codec:         DefaultJSONCodec,
+		watermark:     newWatermarkTracker(),
+
+		maxNestingDepth:     DefaultMaxNestingDepth,
+		maxAbandonedOutputs: DefaultMaxAbandonedOutputs,
 	}
 
 	// Apply options
@@ -85,12 +146,87 @@ func NewProcessor(opts ...func(*Processor)) *Processor {
 
 	// Configure output handler
 	p.runner.Subscribe(func(result interface{}) {
-		p.outputHandler(result.(*Message))
+		msg := result.(*Message)
+
+		// Records exploded from message follow it
+		msgs := append([]*Message{msg}, msg.exploded...)
+		msg.exploded = nil
+
+		p.releaseMutex.Lock()
+		defer p.releaseMutex.Unlock()
+
+		if p.reorder.enabled() {
+			msgs = p.reorder.arrange(msgs)
+		}
+
+		p.release(msgs)
 	})
 
+	p.outputPool.start(p.deliver, &p.queueDepth.depth)
+	p.queueDepth.start()
+	p.heartbeat.start(&p.queueDepth.depth)
+
 	return p
 }
 
+// release emits messages which came from the same task, and then the task is no longer counted by queue depth.
+func (p *Processor) release(msgs []*Message) {
+
+	if p.outputPool.size > 0 && len(msgs) > 0 {
+		for _, m := range msgs {
+			p.prepareOutput(m)
+		}
+
+		p.outputPool.dispatch(msgs)
+		return
+	}
+
+	for _, m := range msgs {
+		p.emit(m)
+	}
+
+	p.queueDepth.depth.Add(-1)
+}
+
+func (p *Processor) emit(msg *Message) {
+	p.prepareOutput(msg)
+	p.deliver(msg)
+}
+
+// prepareOutput does bookkeeping which follows order of output, so it never runs on output pool.
+func (p *Processor) prepareOutput(msg *Message) {
+
+	p.heartbeat.touch()
+
+	if !msg.EventTime.IsZero() {
+		p.watermark.done(msg)
+	}
+
+	// Sequence follows order of output
+	if p.sequenceSource != nil {
+		p.sequence(msg)
+	}
+}
+
+func (p *Processor) deliver(msg *Message) {
+
+	if len(p.stages) > 0 {
+		msg = p.runStages(msg)
+	}
+
+	if len(p.sinks) > 0 {
+		p.writeSink(msg)
+	}
+
+	if msg.Error != nil {
+		p.errorHandler(msg, msg.Error)
+	} else if len(msg.Dropped) > 0 {
+		p.dropHandler(msg, msg.Dropped)
+	}
+
+	p.output(msg)
+}
+
 func WithDomain(domain string) func(*Processor) {
 	return func(p *Processor) {
 		p.domain = domain
@@ -103,16 +239,120 @@ func WithOutputHandler(fn func(*Message)) func(*Processor) {
 	}
 }
 
+// WithErrorHandler sets handler for messages which were failed to be processed. These messages are
+// marked as ignored and still passed to output handler afterward.
+func WithErrorHandler(fn func(*Message, error)) func(*Processor) {
+	return func(p *Processor) {
+		p.errorHandler = fn
+	}
+}
+
+// WithMaxPayloadSize sets limit of raw payload in bytes, messages with larger payload are passed to
+// error handler without being parsed. It's unlimited by default.
+func WithMaxPayloadSize(size int) func(*Processor) {
+	return func(p *Processor) {
+		p.maxPayloadSize = size
+	}
+}
+
+// WithErrorCollection decides whether errors of fields in a payload are reported one at a time, which is
+// rule_manager.FailFast by default, or all at once with rule_manager.CollectAll.
+func WithErrorCollection(mode rule_manager.ErrorCollectionMode) func(*Processor) {
+	return func(p *Processor) {
+		p.errorCollection = mode
+	}
+}
+
+// WithRuleManager sets rules for messages which have no rule specified. Rules of product
+// are used instead if it wasn't set.
+func WithRuleManager(rm *rule_manager.RuleManager) func(*Processor) {
+	return func(p *Processor) {
+		p.rules.Store(rm)
+	}
+}
+
+// ReloadRules swaps rule manager atomically. Messages which have been bound with rules
+// finish with the original ones, and subsequent messages use the new ones.
+func (p *Processor) ReloadRules(rm *rule_manager.RuleManager) {
+	p.rules.Store(rm)
+}
+
+// Process runs the entire pipeline on message synchronously and returns the processed message. Handlers of
+// processor are not involved, so it's suitable for tooling and tests.
+func (p *Processor) Process(msg *Message) (*Message, error) {
+
+	msg = p.process(msg)
+	for _, m := range append([]*Message{msg}, msg.exploded...) {
+		if !m.EventTime.IsZero() {
+			p.watermark.done(m)
+		}
+
+		if p.sequenceSource != nil {
+			p.sequence(m)
+		}
+	}
+
+	if msg.Error != nil {
+		return msg, msg.Error
+	}
+
+	if msg.Rule == nil {
+		return msg, ErrRuleNotFound
+	}
+
+	return msg, nil
+}
+
+// Push queues message for processing. If multiple rules of event apply to message with AllMatch, message is
+// cloned for each of them and clones are queued right after it in order of priority.
 func (p *Processor) Push(msg *Message) {
+
+	var rules []*rule_manager.Rule
+	if msg.Rule == nil && !msg.Ignore {
+		if rm := p.ruleManager(msg); rm != nil {
+			rules = rm.MatchRules(msg.Event)
+		}
+	}
+
+	if len(rules) <= 1 {
+		p.pushTask(msg)
+		return
+	}
+
+	// Clones are taken before message is queued, since workers start changing it right away
+	clones := make([]*Message, 0, len(rules)-1)
+	for _, rule := range rules[1:] {
+		c := msg.Clone()
+		c.Rule = rule
+		clones = append(clones, c)
+	}
+
+	msg.Rule = rules[0]
+	p.pushTask(msg)
+
+	for _, c := range clones {
+		p.pushTask(c)
+	}
+}
+
+func (p *Processor) pushTask(msg *Message) {
+	p.queueDepth.depth.Add(1)
 	p.runner.AddTask(msg)
 }
 
 func (p *Processor) Close() {
 	p.runner.Close()
+	p.outputPool.stop()
+	p.queueDepth.stop()
+	p.heartbeat.stop()
 }
 
 func (p *Processor) process(msg *Message) *Message {
 
+	if p.tracer != nil {
+		defer p.trace(msg)()
+	}
+
 	if msg.Ignore {
 		return msg
 	}
@@ -125,31 +365,109 @@ func (p *Processor) process(msg *Message) *Message {
 		}
 	}
 
+	defer p.ruleStats.count(msg)
+
+	// Product was disabled, which takes effect without restarting
+	if msg.Product != nil && msg.Product.Paused() {
+		msg.Dropped = DropReasonPaused
+		msg.Ignore = true
+		return msg
+	}
+
+	// Throttling high-volume events
+	if !msg.Rule.AllowRate() {
+		msg.Dropped = DropReasonRateLimited
+		msg.Ignore = true
+		return msg
+	}
+
 	// Parsing raw data
-	err := msg.ParseRawData()
+	err := msg.parseRawData(p.codec, p.maxPayloadSize, p.maxNestingDepth)
+	msg.prepareLogger()
 	if err != nil {
-		logger.Error("Failed to parse message",
+		msg.Logger().Error("Failed to parse message",
 			zap.Error(err),
 		)
+		msg.Error = err
 		msg.Ignore = true
 		return msg
 	}
 
+	if len(msg.Rule.Explode) > 0 {
+		return p.explode(msg)
+	}
+
+	return p.transform(msg)
+}
+
+// transform turns payload of message into product event.
+func (p *Processor) transform(msg *Message) *Message {
+
+	p.checkDeprecatedFields(msg)
+
 	//	p.calculatePrimaryKey(msg)
 
 	// Mapping and convert raw data to product_event object
 	product_event, err := p.convert(msg)
 	if err != nil {
 		// Failed to process payload
-		logger.Error("Failed to process payload",
+		msg.Logger().Error("Failed to process payload",
 			zap.Error(err),
 		)
+		msg.Error = err
 		msg.Ignore = true
 		return msg
 	}
 
 	msg.ProductEvent = product_event
 
+	if !msg.EventTime.IsZero() {
+		msg.Late = p.watermark.track(msg, msg.EventTime)
+	}
+
+	// Classify operation by previous state of record
+	if product_event != nil && p.stateProvider != nil {
+		err = p.classify(product_event)
+		if err != nil {
+			msg.Logger().Error("Failed to check previous state",
+				zap.Error(err),
+			)
+			msg.Error = err
+			msg.Ignore = true
+			return msg
+		}
+
+		// Suppressing no-op events
+		if p.suppressUnchanged {
+			unchanged, err := p.unchanged(product_event)
+			if err != nil {
+				msg.Logger().Error("Failed to compare with previous state",
+					zap.Error(err),
+				)
+				msg.Error = err
+				msg.Ignore = true
+				return msg
+			}
+
+			if unchanged {
+				msg.Dropped = DropReasonUnchanged
+				msg.Ignore = true
+				return msg
+			}
+		}
+	}
+
+	if p.metadata {
+		p.attachMetadata(msg)
+	}
+
+	// Sampling by primary key
+	if product_event != nil && !msg.Rule.Sample(product_event.PrimaryKey) {
+		msg.Dropped = DropReasonSampled
+		msg.Ignore = true
+		return msg
+	}
+
 	// Convert product_event to bytes
 	rawProductEvent, _ := gravity_sdk_types_product_event.Marshal(product_event)
 	msg.RawProductEvent = rawProductEvent
@@ -157,23 +475,37 @@ func (p *Processor) process(msg *Message) *Message {
 	// Only avaialble if NATS message object exists
 	var header nats.Header
 	if msg.Msg != nil {
-		// Unique message ID
-		meta, _ := msg.Msg.Metadata()
-		//		msg.ID = fmt.Sprintf("%d", meta.Sequence.Stream)
-		msg.ID = strconv.FormatUint(meta.Sequence.Stream, 16)
+		// Unique message ID, which is only available for messages of JetStream
+		meta, err := msg.Msg.Metadata()
+		if err == nil {
+			//		msg.ID = fmt.Sprintf("%d", meta.Sequence.Stream)
+			msg.ID = strconv.FormatUint(meta.Sequence.Stream, 16)
+		}
+
 		header = msg.Msg.Header
 	}
 
 	// Calculate partion based on primary key
 	p.calculatePartition(msg)
 
-	// Output subject
-	subject := fmt.Sprintf("$GVT.%s.DP.%s.%d.EVENT.%s",
-		p.domain,
-		msg.ProductEvent.Table,
-		msg.Partition,
-		msg.ProductEvent.EventName,
-	)
+	// Output subject, which is rendered by template of rule if there is one
+	subject := msg.OutputSubject
+	if len(subject) == 0 {
+		subject = fmt.Sprintf("$GVT.%s.DP.%s.%d.EVENT.%s",
+			p.domain,
+			msg.ProductEvent.Table,
+			msg.Partition,
+			msg.ProductEvent.EventName,
+		)
+	}
+
+	if len(msg.Rule.SubjectPrefix) > 0 {
+		subject = msg.Rule.SubjectPrefix + "." + subject
+	}
+
+	if len(p.subjectPrefix) > 0 {
+		subject = p.subjectPrefix + "." + subject
+	}
 
 	// Prepare result object
 	msg.OutputMsg = natsMsgPool.Get().(*nats.Msg)
@@ -191,13 +523,30 @@ func (p *Processor) process(msg *Message) *Message {
 	return msg
 }
 
+// ruleManager returns rules for message, which are rules of product unless processor has its own.
+func (p *Processor) ruleManager(msg *Message) *rule_manager.RuleManager {
+
+	// Taking snapshot of rules so message never sees a mix of old and new rules
+	rm := p.rules.Load()
+	if rm == nil {
+		if msg.Product == nil {
+			return nil
+		}
+
+		rm = msg.Product.Rules
+	}
+
+	return rm
+}
+
 func (p *Processor) checkRule(msg *Message) bool {
 
-	if msg.Product == nil {
+	rm := p.ruleManager(msg)
+	if rm == nil {
 		return false
 	}
 
-	rule := msg.Product.Rules.GetRuleByEvent(msg.Event)
+	rule := rm.GetRuleByEvent(msg.Event)
 	if rule == nil {
 		return false
 	}
@@ -226,7 +575,11 @@ func (p *Processor) calculatePrimaryKey(msg *Message) {
 	}
 */
 func (p *Processor) calculatePartition(msg *Message) {
-	msg.Partition = jump.HashString(BytesToString(msg.ProductEvent.PrimaryKey), 256, p.hash)
+
+	// Hasher keeps state, so it's never shared by workers
+	h := hashPool.Get().(jump.KeyHasher)
+	msg.Partition = jump.HashString(BytesToString(msg.ProductEvent.PrimaryKey), 256, h)
+	hashPool.Put(h)
 }
 
 func (p *Processor) convert(msg *Message) (*gravity_sdk_types_product_event.ProductEvent, error) {
@@ -240,9 +593,12 @@ func (p *Processor) convert(msg *Message) (*gravity_sdk_types_product_event.Prod
 	pe.PrimaryKeys = msg.Rule.PrimaryKey
 
 	// Transforming
-	results, err := msg.Rule.Transform(nil, msg.Data.Payload)
+	ctx, cancel := p.transformContext(msg)
+	defer cancel()
+
+	results, err := msg.Rule.TransformContext(ctx, nil, msg.Data.Payload, p.errorCollection)
 	if err != nil {
-		return nil, err
+		return nil, p.transformError(ctx, err)
 	}
 
 	//fmt.Println(results)
@@ -252,19 +608,34 @@ func (p *Processor) convert(msg *Message) (*gravity_sdk_types_product_event.Prod
 		return nil, nil
 	}
 
+	// Record is only needed until it was written to product event
+	r := recordPool.Get().(*record_type.Record)
+	defer releaseRecord(r)
+
 	// Fill product_event
 	result := results[0]
-	fields, err := converter.Convert(msg.Rule.Handler.GetDestinationSchema(), result)
+	fields, err := converter.ConvertTo(r.Payload.Map.Fields, msg.Rule.Handler.GetDestinationSchema(), result)
 	if err != nil {
 		return nil, err
 	}
 
-	r := record_type.NewRecord()
 	r.Payload.Map.Fields = fields
 
+	if t, ok := msg.Rule.EventTime(r); ok {
+		msg.EventTime = t
+	}
+
 	// Calcuate primary key
-	pk, err := r.CalculateKey(pe.PrimaryKeys)
-	if err != nil && err != record_type.ErrNotFoundKeyPath {
+	pk, err := msg.Rule.CalculateKey(r)
+	if err == record_type.ErrNotFoundKeyPath {
+
+		// Partial update is allowed to come without primary key
+		if !rule_manager.IsPartialUpdate(result) {
+			return nil, &rule_manager.MissingPrimaryKeyError{
+				Keys: findMissingKeys(r, pe.PrimaryKeys),
+			}
+		}
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -272,10 +643,68 @@ func (p *Processor) convert(msg *Message) (*gravity_sdk_types_product_event.Prod
 		pe.PrimaryKey = pk
 	}
 
+	// Render output subject for rule
+	subject, err := msg.Rule.RenderOutputSubject(r)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.OutputSubject = subject
+
+	if msg.Rule.RemovedFieldsAsNull {
+		nullRemovedFields(r)
+	}
+
+	orderFields(r, pe.PrimaryKeys)
+
+	if p.metaFieldNames != nil {
+		p.metaFieldNames.rename(r)
+	}
+
+	if p.selfDescribing != SelfDescribingOff {
+		err := describeRecord(msg.Rule, p.selfDescribing, r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.attachRaw {
+		err := p.attachRawPayload(msg, r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Write data back to product event
 	pe.SetContent(r)
 
-	//TODO: reuse record object
-
 	return pe, nil
 }
+
+// releaseRecord resets record entirely and puts it back to pool for reuse.
+func releaseRecord(r *record_type.Record) {
+
+	r.Meta = nil
+	r.Payload.Type = record_type.DataType_MAP
+	r.Payload.Value = nil
+	r.Payload.Array = nil
+	r.Payload.Timestamp = nil
+
+	// Keep capacity but drop references to fields
+	clear(r.Payload.Map.Fields)
+	r.Payload.Map.Fields = r.Payload.Map.Fields[:0]
+
+	recordPool.Put(r)
+}
+
+func findMissingKeys(r *record_type.Record, keys []string) []string {
+
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, err := r.GetValueByPath(key); err != nil {
+			missing = append(missing, key)
+		}
+	}
+
+	return missing
+}
@@ -2,11 +2,15 @@ package dispatcher
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
 	product_sdk "github.com/BrobridgeOrg/gravity-sdk/v2/product"
+	gravity_sdk_types_product_event "github.com/BrobridgeOrg/gravity-sdk/v2/types/product_event"
 	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
@@ -316,3 +320,1702 @@ func TestProcessorOutputsWithVariousInputs(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestProcessor_IntegerOverflow(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	done := make(chan error, 1)
+
+	p := NewProcessor(
+		WithErrorHandler(func(msg *Message, err error) {
+			done <- err
+		}),
+		WithOutputHandler(func(msg *Message) {
+			assert.True(t, msg.Ignore)
+		}),
+	)
+
+	r := CreateTestRule()
+	r.SchemaConfig["level"] = map[string]interface{}{
+		"type": "int8",
+	}
+
+	testRuleManager := rule_manager.NewRuleManager()
+	testRuleManager.AddRule(r)
+
+	testData := MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"level":300}`),
+	}
+
+	msg := NewMessage()
+	msg.Rule = r
+	raw, _ := json.Marshal(testData)
+	msg.Raw = raw
+
+	p.Push(msg)
+
+	assert.NotNil(t, <-done)
+}
+
+func TestProcessor_Bytes(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	done := make(chan struct{})
+
+	p := NewProcessor(
+		WithOutputHandler(func(msg *Message) {
+			defer close(done)
+
+			if !assert.False(t, msg.Ignore) {
+				return
+			}
+
+			r, err := msg.ProductEvent.GetContent()
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			if v, err := GetFieldValue(r, "thumbnail"); assert.Nil(t, err) {
+				assert.Equal(t, []byte("hello"), v)
+			}
+
+			// Encoded as base64 string in JSON
+			data, err := record_type.MarshalJSON(r)
+			if assert.Nil(t, err) {
+				assert.Contains(t, string(data), `"thumbnail":"aGVsbG8="`)
+			}
+		}),
+	)
+
+	r := CreateTestRule()
+	r.SchemaConfig["thumbnail"] = map[string]interface{}{
+		"type": "bytes",
+	}
+
+	testRuleManager := rule_manager.NewRuleManager()
+	testRuleManager.AddRule(r)
+
+	testData := MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"thumbnail":"aGVsbG8="}`),
+	}
+
+	msg := NewMessage()
+	msg.Rule = r
+	raw, _ := json.Marshal(testData)
+	msg.Raw = raw
+
+	p.Push(msg)
+
+	<-done
+}
+
+func TestProcessor_StructuredErrors(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	errs := make(chan error, 2)
+
+	p := NewProcessor(
+		WithErrorHandler(func(msg *Message, err error) {
+			errs <- err
+		}),
+	)
+
+	r := CreateTestRule()
+	r.SchemaConfig["level"] = map[string]interface{}{
+		"type": "int8",
+	}
+
+	testRuleManager := rule_manager.NewRuleManager()
+	testRuleManager.AddRule(r)
+
+	payloads := []string{
+		`{"id":101,"level":300}`,
+		`{"name":"fred"}`,
+	}
+
+	for _, payload := range payloads {
+
+		testData := MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		}
+
+		msg := NewMessage()
+		msg.Rule = r
+		raw, _ := json.Marshal(testData)
+		msg.Raw = raw
+
+		p.Push(msg)
+	}
+
+	// Coercion failure
+	var coercionErr *rule_manager.CoercionError
+	if assert.ErrorAs(t, <-errs, &coercionErr) {
+		assert.Equal(t, "level", coercionErr.Field)
+		assert.Equal(t, "int8", coercionErr.To)
+	}
+
+	// Missing primary key
+	var pkErr *rule_manager.MissingPrimaryKeyError
+	if assert.ErrorAs(t, <-errs, &pkErr) {
+		assert.Equal(t, []string{"id"}, pkErr.Keys)
+	}
+}
+
+func TestProcessor_OutputSubjectTemplate(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	subjects := make(chan string, 1)
+	errs := make(chan error, 1)
+
+	p := NewProcessor(
+		WithErrorHandler(func(msg *Message, err error) {
+			errs <- err
+		}),
+		WithOutputHandler(func(msg *Message) {
+			if !msg.Ignore {
+				subjects <- msg.OutputSubject
+			}
+		}),
+	)
+	defer p.Close()
+
+	r := CreateTestRule()
+	r.SchemaConfig["country"] = map[string]interface{}{
+		"type": "string",
+	}
+	r.OutputSubjectTemplate = "events.{country}.{name}"
+
+	testRuleManager := rule_manager.NewRuleManager()
+	if !assert.Nil(t, testRuleManager.AddRule(r)) {
+		return
+	}
+
+	payloads := []string{
+		`{"id":101,"name":"fred","country":"TW"}`,
+		`{"id":102,"name":"stacy"}`,
+	}
+
+	for _, payload := range payloads {
+
+		testData := MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		}
+
+		msg := NewMessage()
+		msg.Rule = r
+		raw, _ := json.Marshal(testData)
+		msg.Raw = raw
+
+		p.Push(msg)
+	}
+
+	assert.Equal(t, "events.TW.fred", <-subjects)
+
+	// Country is missing
+	assert.ErrorIs(t, <-errs, rule_manager.ErrSubjectFieldNotFound)
+}
+
+func TestProcessor_ReloadRules(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	createRuleManager := func(generation int) *rule_manager.RuleManager {
+
+		field := fmt.Sprintf("v%d", generation)
+
+		r := rule_manager.NewRule(product_sdk.NewRule())
+		r.Event = "dataCreated"
+		r.Product = field
+		r.PrimaryKey = []string{
+			"id",
+		}
+		r.SchemaConfig = map[string]interface{}{
+			"id":  map[string]interface{}{"type": "int"},
+			field: map[string]interface{}{"type": "int"},
+		}
+
+		rm := rule_manager.NewRuleManager()
+		rm.AddRule(r)
+
+		return rm
+	}
+
+	const count = 1000
+	const generations = 10
+
+	var wg sync.WaitGroup
+	wg.Add(count)
+
+	p := NewProcessor(
+		WithRuleManager(createRuleManager(0)),
+		WithOutputHandler(func(msg *Message) {
+			defer wg.Done()
+
+			if !assert.False(t, msg.Ignore) {
+				return
+			}
+
+			// Only fields of the same generation should be seen
+			r, err := msg.ProductEvent.GetContent()
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			if !assert.Len(t, r.Payload.Map.Fields, 2) {
+				return
+			}
+
+			_, err = GetFieldValue(r, msg.ProductEvent.Table)
+			assert.Nil(t, err)
+		}),
+	)
+
+	// Payload carries fields for all generations
+	payload := map[string]interface{}{
+		"id": 1,
+	}
+	for i := 0; i <= generations; i++ {
+		payload[fmt.Sprintf("v%d", i)] = i
+	}
+
+	rawPayload, _ := json.Marshal(payload)
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: rawPayload,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= generations; i++ {
+			p.ReloadRules(createRuleManager(i))
+		}
+	}()
+
+	for i := 0; i < count; i++ {
+		msg := NewMessage()
+		msg.Event = "dataCreated"
+		msg.Raw = raw
+		p.Push(msg)
+	}
+
+	<-done
+	wg.Wait()
+}
+
+func TestProcessor_Sampling(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	const count = 1000
+
+	run := func() map[string]bool {
+
+		var wg sync.WaitGroup
+		wg.Add(count)
+
+		kept := make(map[string]bool)
+		dropped := 0
+
+		r := CreateTestRule()
+		r.SampleRate = 0.5
+		rm := rule_manager.NewRuleManager()
+		rm.AddRule(r)
+
+		p := NewProcessor(
+			WithRuleManager(rm),
+			WithDropHandler(func(msg *Message, reason DropReason) {
+				assert.Equal(t, DropReasonSampled, reason)
+				dropped++
+			}),
+			WithOutputHandler(func(msg *Message) {
+				defer wg.Done()
+
+				if !msg.Ignore {
+					kept[string(msg.ProductEvent.PrimaryKey)] = true
+				}
+			}),
+		)
+		defer p.Close()
+
+		for i := 0; i < count; i++ {
+			raw, _ := json.Marshal(MessageRawData{
+				Event:      "dataCreated",
+				RawPayload: []byte(fmt.Sprintf(`{"id":%d}`, i)),
+			})
+
+			msg := NewMessage()
+			msg.Event = "dataCreated"
+			msg.Raw = raw
+			p.Push(msg)
+		}
+
+		wg.Wait()
+
+		assert.Equal(t, count, len(kept)+dropped)
+
+		return kept
+	}
+
+	kept := run()
+	assert.InDelta(t, count/2, len(kept), count*0.1)
+
+	// The same keys are kept
+	assert.Equal(t, kept, run())
+}
+
+func TestProcessor_RateLimit(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	const count = 200
+
+	var wg sync.WaitGroup
+	wg.Add(count)
+
+	kept := 0
+	dropped := 0
+
+	r := CreateTestRule()
+	r.MaxPerSecond = 20
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(r)
+
+	p := NewProcessor(
+		WithRuleManager(rm),
+		WithDropHandler(func(msg *Message, reason DropReason) {
+			assert.Equal(t, DropReasonRateLimited, reason)
+			dropped++
+		}),
+		WithOutputHandler(func(msg *Message) {
+			defer wg.Done()
+
+			if !msg.Ignore {
+				kept++
+			}
+		}),
+	)
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":1}`),
+	})
+
+	for i := 0; i < count; i++ {
+		msg := NewMessage()
+		msg.Event = "dataCreated"
+		msg.Raw = raw
+		p.Push(msg)
+	}
+
+	wg.Wait()
+
+	// Burst is allowed up to limit, and a few more might be refilled meanwhile
+	assert.GreaterOrEqual(t, kept, 20)
+	assert.Less(t, kept, 40)
+	assert.Equal(t, count, kept+dropped)
+}
+
+func TestProcessor_Process(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor()
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"name":"fred"}`),
+	})
+
+	msg := CreateTestMessage()
+	msg.Raw = raw
+
+	result, err := p.Process(msg)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.False(t, result.Ignore)
+	assert.Equal(t, "dataCreated", result.ProductEvent.EventName)
+	assert.Equal(t, "TestDataProduct", result.ProductEvent.Table)
+
+	r, err := result.ProductEvent.GetContent()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if v, err := GetFieldValue(r, "id"); assert.Nil(t, err) {
+		assert.Equal(t, int64(101), v)
+	}
+
+	if v, err := GetFieldValue(r, "name"); assert.Nil(t, err) {
+		assert.Equal(t, "fred", v)
+	}
+
+	// No rule for event
+	msg = NewMessage()
+	msg.Event = "unknown"
+	msg.Raw = raw
+
+	_, err = p.Process(msg)
+	assert.Equal(t, ErrRuleNotFound, err)
+
+	// Invalid payload
+	msg = CreateTestMessage()
+	msg.Raw = []byte(`{"event":"dataCreated","payload":"invalid"}`)
+
+	_, err = p.Process(msg)
+	assert.NotNil(t, err)
+}
+
+func TestProcessor_GeneratedPrimaryKey(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	r := rule_manager.NewRule(product_sdk.NewRule())
+	r.Event = "logCreated"
+	r.Product = "TestLogs"
+	r.PrimaryKeyStrategy = rule_manager.PrimaryKeyStrategyUUID
+	r.PrimaryKeyField = "key"
+	r.SchemaConfig = map[string]interface{}{
+		"key":     map[string]interface{}{"type": "string"},
+		"message": map[string]interface{}{"type": "string"},
+	}
+
+	err := rule_manager.NewRuleManager().AddRule(r)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	p := NewProcessor()
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "logCreated",
+		RawPayload: []byte(`{"message":"hello"}`),
+	})
+
+	msg := NewMessage()
+	msg.Rule = r
+	msg.Raw = raw
+
+	msg, err = p.Process(msg)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	content, err := msg.ProductEvent.GetContent()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	key, err := GetFieldValue(content, "key")
+	if assert.Nil(t, err) {
+		assert.Len(t, key, 36)
+		assert.NotEmpty(t, msg.ProductEvent.PrimaryKey)
+	}
+}
+
+func TestProcessor_Metadata(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"name":"fred"}`),
+	})
+
+	// Disabled by default
+	p := NewProcessor()
+	defer p.Close()
+
+	msg := CreateTestMessage()
+	msg.Raw = raw
+
+	msg, err := p.Process(msg)
+	if assert.Nil(t, err) {
+		assert.Nil(t, msg.Metadata())
+	}
+
+	p = NewProcessor(WithMetadata(true))
+	defer p.Close()
+
+	msg = CreateTestMessage()
+	msg.Raw = raw
+
+	before := time.Now()
+	msg, err = p.Process(msg)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	md := msg.Metadata()
+	if !assert.NotNil(t, md) {
+		return
+	}
+
+	assert.Equal(t, msg.Rule.ID, md.RuleID)
+	assert.NotEmpty(t, md.RuleID)
+	assert.Equal(t, "TestDataProduct", md.Product)
+	assert.Equal(t, "dataCreated", md.Event)
+	assert.False(t, md.ProcessedAt.Before(before))
+
+	// Payload is not affected
+	r, err := msg.ProductEvent.GetContent()
+	if assert.Nil(t, err) {
+		assert.Len(t, r.Payload.Map.Fields, 2)
+	}
+}
+
+func TestProcessor_UnknownFields(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor()
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"name":"fred","extra":"value"}`),
+	})
+
+	for _, policy := range []string{
+		rule_manager.UnknownFieldsIgnore,
+		rule_manager.UnknownFieldsError,
+		rule_manager.UnknownFieldsPassthrough,
+	} {
+		msg := CreateTestMessage()
+		msg.Rule.UnknownFields = policy
+		msg.Raw = raw
+
+		msg, err := p.Process(msg)
+
+		switch policy {
+		case rule_manager.UnknownFieldsError:
+			var validationErr *rule_manager.SchemaValidationError
+			assert.ErrorAs(t, err, &validationErr)
+			continue
+		}
+
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		r, err := msg.ProductEvent.GetContent()
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		v, err := GetFieldValue(r, "extra")
+		if policy == rule_manager.UnknownFieldsIgnore {
+			assert.NotNil(t, err)
+			continue
+		}
+
+		assert.Equal(t, "value", v)
+	}
+}
+
+func TestProcessor_PooledReuse(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor()
+	defer p.Close()
+
+	payloads := []string{
+		`{"id":101,"name":"fred","gender":"male","tags":["a","b"]}`,
+		`{"id":102}`,
+		`{"id":103,"nested":{"nested_id":"n1"}}`,
+	}
+
+	expected := [][]string{
+		{"gender", "id", "name", "tags"},
+		{"id"},
+		{"id", "nested"},
+	}
+
+	// Run several rounds to make sure pooled objects were reused
+	for round := 0; round < 10; round++ {
+		for i, payload := range payloads {
+
+			raw, _ := json.Marshal(MessageRawData{
+				Event:      "dataCreated",
+				RawPayload: []byte(payload),
+			})
+
+			msg := NewMessage()
+			msg.Rule = CreateTestMessage().Rule
+			msg.Raw = raw
+
+			msg, err := p.Process(msg)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			assert.Len(t, msg.Data.Payload, len(expected[i]))
+
+			r, err := msg.ProductEvent.GetContent()
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			names := make([]string, 0)
+			for _, field := range r.Payload.Map.Fields {
+				names = append(names, field.Name)
+			}
+
+			assert.ElementsMatch(t, expected[i], names)
+
+			msg.Release()
+		}
+	}
+}
+
+func TestProcessor_LargeIntegers(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	r := rule_manager.NewRule(product_sdk.NewRule())
+	r.Event = "dataCreated"
+	r.Product = "TestDataProduct"
+	r.PrimaryKey = []string{
+		"id",
+	}
+	r.SchemaConfig = map[string]interface{}{
+		"id":    map[string]interface{}{"type": "int"},
+		"count": map[string]interface{}{"type": "uint"},
+		"score": map[string]interface{}{"type": "float"},
+	}
+
+	err := rule_manager.NewRuleManager().AddRule(r)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	p := NewProcessor()
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":9007199254740993,"count":18446744073709551615,"score":1.5}`),
+	})
+
+	msg := NewMessage()
+	msg.Rule = r
+	msg.Raw = raw
+
+	msg, err = p.Process(msg)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	content, err := msg.ProductEvent.GetContent()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if v, err := GetFieldValue(content, "id"); assert.Nil(t, err) {
+		assert.Equal(t, int64(9007199254740993), v)
+	}
+
+	if v, err := GetFieldValue(content, "count"); assert.Nil(t, err) {
+		assert.Equal(t, uint64(18446744073709551615), v)
+	}
+
+	if v, err := GetFieldValue(content, "score"); assert.Nil(t, err) {
+		assert.Equal(t, float64(1.5), v)
+	}
+}
+
+// testStateProvider remembers every primary key it was asked about
+type testStateProvider struct {
+	keys map[string]bool
+	err  error
+}
+
+func (sp *testStateProvider) Exists(product string, primaryKey []byte) (bool, error) {
+
+	if sp.err != nil {
+		return false, sp.err
+	}
+
+	key := product + "/" + string(primaryKey)
+	exists := sp.keys[key]
+	sp.keys[key] = true
+
+	return exists, nil
+}
+
+func TestProcessor_StateProvider(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	process := func(p *Processor, payload string) (*Message, error) {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		})
+
+		msg := CreateTestMessage()
+		msg.Raw = raw
+
+		return p.Process(msg)
+	}
+
+	// Without provider, method of rule is kept
+	p := NewProcessor()
+	defer p.Close()
+
+	result, err := process(p, `{"id":101,"name":"fred"}`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, gravity_sdk_types_product_event.Method_INSERT, result.ProductEvent.Method)
+	}
+
+	sp := &testStateProvider{
+		keys: make(map[string]bool),
+	}
+
+	p = NewProcessor(WithStateProvider(sp))
+	defer p.Close()
+
+	// Key is absent
+	result, err = process(p, `{"id":101,"name":"fred"}`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, gravity_sdk_types_product_event.Method_INSERT, result.ProductEvent.Method)
+	}
+
+	// Key exists
+	result, err = process(p, `{"id":101,"name":"stacy"}`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, gravity_sdk_types_product_event.Method_UPDATE, result.ProductEvent.Method)
+	}
+
+	// Failed to check state
+	sp.err = fmt.Errorf("unavailable")
+	result, err = process(p, `{"id":101,"name":"fred"}`)
+	assert.Equal(t, sp.err, err)
+	assert.True(t, result.Ignore)
+}
+
+// testRecordStateProvider keeps records which were stored as previous state
+type testRecordStateProvider struct {
+	records map[string]*record_type.Record
+}
+
+func (sp *testRecordStateProvider) Exists(product string, primaryKey []byte) (bool, error) {
+	_, ok := sp.records[product+"/"+string(primaryKey)]
+	return ok, nil
+}
+
+func (sp *testRecordStateProvider) Get(product string, primaryKey []byte) (*record_type.Record, error) {
+	return sp.records[product+"/"+string(primaryKey)], nil
+}
+
+func (sp *testRecordStateProvider) Reset() error {
+	clear(sp.records)
+	return nil
+}
+
+func (sp *testRecordStateProvider) store(pe *gravity_sdk_types_product_event.ProductEvent) {
+	r, _ := pe.GetContent()
+	sp.records[pe.Table+"/"+string(pe.PrimaryKey)] = r
+}
+
+func TestProcessor_SuppressUnchanged(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	sp := &testRecordStateProvider{
+		records: make(map[string]*record_type.Record),
+	}
+
+	p := NewProcessor(
+		WithStateProvider(sp),
+		WithSuppressUnchanged(true),
+	)
+	defer p.Close()
+
+	process := func(payload string) *Message {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		})
+
+		msg := CreateTestMessage()
+		msg.Raw = raw
+
+		result, err := p.Process(msg)
+		assert.Nil(t, err)
+
+		if !result.Ignore {
+			sp.store(result.ProductEvent)
+		}
+
+		return result
+	}
+
+	result := process(`{"id":101,"name":"fred"}`)
+	assert.False(t, result.Ignore)
+	assert.Equal(t, gravity_sdk_types_product_event.Method_INSERT, result.ProductEvent.Method)
+
+	// Identical record
+	result = process(`{"id":101,"name":"fred"}`)
+	assert.True(t, result.Ignore)
+	assert.Equal(t, DropReasonUnchanged, result.Dropped)
+
+	// Changed record
+	result = process(`{"id":101,"name":"stacy"}`)
+	assert.False(t, result.Ignore)
+	assert.Equal(t, gravity_sdk_types_product_event.Method_UPDATE, result.ProductEvent.Method)
+
+	// Another record is never compared with others
+	result = process(`{"id":102,"name":"stacy"}`)
+	assert.False(t, result.Ignore)
+
+	// Previous state is forgotten
+	p.ResetState()
+	result = process(`{"id":102,"name":"stacy"}`)
+	assert.False(t, result.Ignore)
+	assert.Equal(t, gravity_sdk_types_product_event.Method_INSERT, result.ProductEvent.Method)
+}
+
+func TestProcessor_MaxPayloadSize(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	errs := make(chan error, 2)
+	done := make(chan *Message, 2)
+
+	p := NewProcessor(
+		WithMaxPayloadSize(64),
+		WithErrorHandler(func(msg *Message, err error) {
+			errs <- err
+		}),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	payloads := []string{
+		`{"id":101,"name":"fred"}`,
+		fmt.Sprintf(`{"id":102,"name":"%s"}`, strings.Repeat("x", 64)),
+	}
+
+	for _, payload := range payloads {
+
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		})
+
+		msg := CreateTestMessage()
+		msg.Raw = raw
+
+		p.Push(msg)
+	}
+
+	// Below the limit
+	msg := <-done
+	assert.False(t, msg.Ignore)
+	assert.Nil(t, msg.Error)
+
+	// Above the limit
+	msg = <-done
+	assert.True(t, msg.Ignore)
+	assert.ErrorIs(t, msg.Error, ErrPayloadTooLarge)
+	assert.Nil(t, msg.Data.Payload["id"])
+	assert.ErrorIs(t, <-errs, ErrPayloadTooLarge)
+}
+
+func TestProcessor_MaxNestingDepth(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor(WithMaxNestingDepth(4))
+	defer p.Close()
+
+	process := func(payload string) *Message {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		})
+
+		msg := CreateTestMessage()
+		msg.Raw = raw
+
+		result, _ := p.Process(msg)
+
+		return result
+	}
+
+	// Brackets in strings are not counted
+	msg := process(`{"id":101,"name":"[[[[{{{{","nested":{"nested_id":"n1"},"tags":["a"]}`)
+	assert.False(t, msg.Ignore)
+	assert.Nil(t, msg.Error)
+
+	msg = process(`{"id":102,"extra":{"a":[{"b":[1]}]}}`)
+	assert.True(t, msg.Ignore)
+	assert.ErrorIs(t, msg.Error, ErrPayloadTooDeep)
+	assert.Nil(t, msg.Data.Payload["id"])
+
+	// Default limit is finite
+	deep := strings.Repeat("[", DefaultMaxNestingDepth+1) + strings.Repeat("]", DefaultMaxNestingDepth+1)
+	msg = NewMessage()
+	msg.Raw, _ = json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":103,"extra":` + deep + `}`),
+	})
+	assert.ErrorIs(t, msg.ParseRawData(), ErrPayloadTooDeep)
+}
+
+func TestProcessor_NestedPrimaryKey(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor()
+	defer p.Close()
+
+	process := func(primaryKey []string, payload string) (*Message, error) {
+
+		r := CreateTestRule()
+		r.PrimaryKey = primaryKey
+
+		rm := rule_manager.NewRuleManager()
+		rm.AddRule(r)
+
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		})
+
+		msg := NewMessage()
+		msg.Rule = r
+		msg.Raw = raw
+
+		return p.Process(msg)
+	}
+
+	// Nested single key
+	result, err := process([]string{"nested.nested_id"}, `{"id":101,"nested":{"nested_id":"n1"}}`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []byte("n1"), result.ProductEvent.PrimaryKey)
+	}
+
+	// Composite key mixing top-level and nested fields
+	result, err = process([]string{"name", "nested.nested_id"}, `{"id":101,"name":"fred","nested":{"nested_id":"n1"}}`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []byte("fred_n1"), result.ProductEvent.PrimaryKey)
+	}
+
+	// Nested key is missing
+	_, err = process([]string{"name", "nested.nested_id"}, `{"id":101,"name":"fred","nested":{}}`)
+	var pkErr *rule_manager.MissingPrimaryKeyError
+	if assert.ErrorAs(t, err, &pkErr) {
+		assert.Equal(t, []string{"nested.nested_id"}, pkErr.Keys)
+	}
+
+	_, err = process([]string{"name", "nested.nested_id"}, `{"id":101,"name":"fred"}`)
+	if assert.ErrorAs(t, err, &pkErr) {
+		assert.Equal(t, []string{"nested.nested_id"}, pkErr.Keys)
+	}
+}
+
+func TestProcessor_DeprecatedFields(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	var warnings []string
+	p := NewProcessor(
+		WithDeprecationHandler(func(msg *Message, field string) {
+			warnings = append(warnings, field)
+		}),
+	)
+	defer p.Close()
+
+	r := CreateTestRule()
+	r.SchemaConfig["gender"] = map[string]interface{}{"type": "string", "deprecated": true}
+	r.SchemaConfig["nested"].(map[string]interface{})["fields"].(map[string]interface{})["nested_id"] = map[string]interface{}{
+		"type":       "string",
+		"deprecated": true,
+	}
+
+	rm := rule_manager.NewRuleManager()
+	if !assert.Nil(t, rm.AddRule(r)) {
+		return
+	}
+
+	process := func(payload string) *Message {
+
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		})
+
+		msg := NewMessage()
+		msg.Rule = r
+		msg.Raw = raw
+
+		result, err := p.Process(msg)
+		assert.Nil(t, err)
+
+		return result
+	}
+
+	// Deprecated fields are absent
+	process(`{"id":101,"name":"fred"}`)
+	assert.Empty(t, warnings)
+
+	// Deprecated fields are still processed
+	result := process(`{"id":101,"name":"fred","gender":"m","nested":{"nested_id":"n1"}}`)
+	assert.Equal(t, []string{"gender", "nested.nested_id"}, warnings)
+
+	record, err := result.ProductEvent.GetContent()
+	if assert.Nil(t, err) {
+		v, err := GetFieldValue(record, "gender")
+		assert.Nil(t, err)
+		assert.Equal(t, "m", v)
+	}
+}
+
+func TestProcessor_SubjectPrefix(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor(
+		WithDomain("default"),
+		WithSubjectPrefix("tenantA"),
+	)
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"name":"fred"}`),
+	})
+
+	msg := CreateTestMessage()
+	msg.Raw = raw
+
+	result, err := p.Process(msg)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, fmt.Sprintf("tenantA.$GVT.default.DP.TestDataProduct.%d.EVENT.dataCreated", result.Partition), result.OutputMsg.Subject)
+
+	assert.Nil(t, ValidateSubjectPrefix("tenantA"))
+	assert.Nil(t, ValidateSubjectPrefix("tenants.A"))
+	for _, prefix := range []string{"", "tenant..A", ".tenantA", "tenant*", "tenant.*", "tenant A", ">"} {
+		assert.ErrorIs(t, ValidateSubjectPrefix(prefix), ErrInvalidSubjectPrefix)
+	}
+}
+
+func TestProcessor_ErrorCollection(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	r := CreateTestRule()
+	r.SchemaConfig["level"] = map[string]interface{}{
+		"type": "int8",
+	}
+	r.SchemaConfig["score"] = map[string]interface{}{
+		"type": "uint8",
+	}
+
+	testRuleManager := rule_manager.NewRuleManager()
+	testRuleManager.AddRule(r)
+
+	// Three fields are invalid
+	process := func(p *Processor) error {
+
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(`{"id":101,"level":300,"score":-1,"tags":["a",{"b":1}]}`),
+		})
+
+		msg := NewMessage()
+		msg.Rule = r
+		msg.Raw = raw
+
+		_, err := p.Process(msg)
+
+		return err
+	}
+
+	// The first error only
+	err := process(NewProcessor())
+	var coercionErr *rule_manager.CoercionError
+	assert.ErrorAs(t, err, &coercionErr)
+
+	var fieldErrs *rule_manager.FieldErrors
+	assert.False(t, errors.As(err, &fieldErrs))
+
+	// Every error
+	err = process(NewProcessor(WithErrorCollection(rule_manager.CollectAll)))
+	if assert.ErrorAs(t, err, &fieldErrs) && assert.Len(t, fieldErrs.Errors, 3) {
+
+		fields := make([]string, 0, 3)
+		for _, e := range fieldErrs.Errors {
+			if assert.ErrorAs(t, e, &coercionErr) {
+				fields = append(fields, coercionErr.Field)
+			}
+		}
+
+		assert.ElementsMatch(t, []string{"level", "score", "tags.1"}, fields)
+	}
+
+	assert.ErrorAs(t, err, &coercionErr)
+}
+
+func TestProcessor_RemovedFieldsAsNull(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor()
+
+	r := CreateTestRule()
+	r.RemovedFieldsAsNull = true
+
+	testRuleManager := rule_manager.NewRuleManager()
+	testRuleManager.AddRule(r)
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event: "dataCreated",
+		RawPayload: []byte(`{
+	"$removedFields": ["id", "nested.nested_id"],
+	"name": "fred"
+}`),
+	})
+
+	msg := NewMessage()
+	msg.Rule = r
+	msg.Raw = raw
+
+	msg, err := p.Process(msg)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	record, err := msg.ProductEvent.GetContent()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = GetFieldValue(record, "$removedFields")
+	assert.NotNil(t, err)
+
+	for _, path := range []string{"id", "nested.nested_id"} {
+		field := record_type.GetField(record.Payload.Map.Fields, path)
+		if assert.NotNil(t, field, path) {
+			assert.Equal(t, record_type.DataType_NULL, field.Value.Type)
+		}
+	}
+
+	if v, err := GetFieldValue(record, "name"); assert.Nil(t, err) {
+		assert.Equal(t, "fred", v)
+	}
+}
+
+func TestProcessor_RemovedFieldsOrder(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor()
+	defer p.Close()
+
+	for i := 0; i < 10; i++ {
+
+		raw, _ := json.Marshal(MessageRawData{
+			Event: "dataCreated",
+			RawPayload: []byte(`{
+	"$removedFields": ["tags", "name", "nested.nested_id", "gender", "name"],
+	"id": 101
+}`),
+		})
+
+		msg := CreateTestMessage()
+		msg.Raw = raw
+
+		msg, err := p.Process(msg)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		record, err := msg.ProductEvent.GetContent()
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		if v, err := GetFieldValue(record, "$removedFields"); assert.Nil(t, err) {
+			assert.Equal(t, []interface{}{"gender", "name", "nested.nested_id", "tags"}, v)
+		}
+	}
+}
+
+func TestProcessor_MetaFieldNames(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	names := MetaFieldNames{RemovedFields: "__deleted__"}
+
+	p := NewProcessor(WithMetaFieldNames(names))
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event: "dataCreated",
+		RawPayload: []byte(`{
+	"$removedFields": ["name", "gender"],
+	"id": 101
+}`),
+	})
+
+	msg := CreateTestMessage()
+	msg.Raw = raw
+
+	msg, err := p.Process(msg)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	update, err := msg.ProductEvent.GetContent()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = GetFieldValue(update, "$removedFields")
+	assert.NotNil(t, err)
+
+	if v, err := GetFieldValue(update, "__deleted__"); assert.Nil(t, err) {
+		assert.Equal(t, []interface{}{"gender", "name"}, v)
+	}
+
+	// Update is read with the same names
+	r := CreateTestRecord(t, map[string]interface{}{
+		"id":     int64(101),
+		"name":   "fred",
+		"gender": "m",
+	})
+
+	if assert.Nil(t, names.ApplyUpdate(r, update)) {
+		assert.Equal(t, map[string]interface{}{"id": int64(101)}, r.AsMap())
+	}
+}
+
+func TestProcessor_TransformTimeout(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	errs := make(chan error, 1)
+
+	p := NewProcessor(
+		WithTransformTimeout(time.Millisecond),
+		WithErrorHandler(func(msg *Message, err error) {
+			errs <- err
+		}),
+	)
+
+	// Pathological payload takes much longer than timeout
+	var sb strings.Builder
+	sb.WriteString(`{"id":101,"tags":[`)
+	for i := 0; i < 500000; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+
+		sb.WriteString(`"tag"`)
+	}
+	sb.WriteString(`]}`)
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(sb.String()),
+	})
+
+	msg := CreateTestMessage()
+	msg.Raw = raw
+
+	p.Push(msg)
+
+	assert.ErrorIs(t, <-errs, ErrTransformTimeout)
+}
+
+func TestProcessor_MatchMode(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	createRuleManager := func(mode rule_manager.MatchMode) *rule_manager.RuleManager {
+
+		rm := rule_manager.NewRuleManager(rule_manager.WithMatchMode(mode))
+		for priority, product := range []string{"LowPriorityProduct", "HighPriorityProduct"} {
+			r := CreateTestRule()
+			r.Product = product
+			r.Priority = priority
+			rm.AddRule(r)
+		}
+
+		return rm
+	}
+
+	run := func(mode rule_manager.MatchMode) []string {
+
+		tables := make(chan string, 2)
+
+		p := NewProcessor(
+			WithRuleManager(createRuleManager(mode)),
+			WithOutputHandler(func(msg *Message) {
+				tables <- msg.ProductEvent.Table
+			}),
+		)
+		defer p.Close()
+
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(`{"id":101,"name":"fred"}`),
+		})
+
+		msg := NewMessage()
+		msg.Event = "dataCreated"
+		msg.Raw = raw
+
+		p.Push(msg)
+
+		results := []string{<-tables}
+		if mode == rule_manager.AllMatch {
+			results = append(results, <-tables)
+		}
+
+		// Nothing else fires
+		select {
+		case table := <-tables:
+			results = append(results, table)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		return results
+	}
+
+	assert.Equal(t, []string{"HighPriorityProduct"}, run(rule_manager.FirstMatch))
+	assert.Equal(t, []string{"HighPriorityProduct", "LowPriorityProduct"}, run(rule_manager.AllMatch))
+}
+
+func TestProcessor_PrimaryKeyFirst(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor()
+	defer p.Close()
+
+	process := func(msg *Message, payload string) []string {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		})
+
+		msg.Raw = raw
+
+		result, err := p.Process(msg)
+		if !assert.Nil(t, err) {
+			return nil
+		}
+
+		r, err := result.ProductEvent.GetContent()
+		if !assert.Nil(t, err) {
+			return nil
+		}
+
+		names := make([]string, 0)
+		for _, field := range r.Payload.Map.Fields {
+			names = append(names, field.Name)
+		}
+
+		return names
+	}
+
+	for i := 0; i < 10; i++ {
+		names := process(CreateTestMessage(), `{"name":"fred","gender":"m","id":101}`)
+		assert.Equal(t, []string{"id", "gender", "name"}, names)
+	}
+
+	// Composite key in declared order
+	msg := CreateTestMessage()
+	msg.Rule.PrimaryKey = []string{"name", "id"}
+
+	names := process(msg, `{"gender":"m","id":101,"name":"fred"}`)
+	assert.Equal(t, []string{"name", "id", "gender"}, names)
+}
+
+func TestProcessor_NestedCoercion(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor()
+	defer p.Close()
+
+	process := func(msg *Message, payload string) (*Message, error) {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		})
+
+		msg.Raw = raw
+
+		return p.Process(msg)
+	}
+
+	// Number is formatted as string
+	result, err := process(CreateTestMessage(), `{"id":101,"nested":{"nested_id":123}}`)
+	if assert.Nil(t, err) {
+		r, _ := result.ProductEvent.GetContent()
+		v, err := r.GetValueDataByPath("nested.nested_id")
+		if assert.Nil(t, err) {
+			assert.Equal(t, "123", v)
+		}
+	}
+
+	// Number is rejected for string
+	msg := CreateTestMessage()
+	msg.Rule.StrictStrings = true
+
+	_, err = process(msg, `{"id":101,"nested":{"nested_id":123}}`)
+	var coercionErr *rule_manager.CoercionError
+	if assert.ErrorAs(t, err, &coercionErr) {
+		assert.Equal(t, "nested.nested_id", coercionErr.Field)
+		assert.Contains(t, err.Error(), "nested.nested_id")
+	}
+
+	// Map is never turned into string
+	_, err = process(CreateTestMessage(), `{"id":101,"nested":{"nested_id":{"value":123}}}`)
+	if assert.ErrorAs(t, err, &coercionErr) {
+		assert.Equal(t, "nested.nested_id", coercionErr.Field)
+		assert.Equal(t, "map", coercionErr.From)
+	}
+}
+
+func TestProcessor_SelfDescribing(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	process := func(p *Processor) map[string]interface{} {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(`{"id":101,"name":"fred"}`),
+		})
+
+		msg := CreateTestMessage()
+		msg.Raw = raw
+
+		result, err := p.Process(msg)
+		if !assert.Nil(t, err) {
+			return nil
+		}
+
+		r, err := result.ProductEvent.GetContent()
+		if !assert.Nil(t, err) {
+			return nil
+		}
+
+		return r.Meta.AsMap()
+	}
+
+	p := NewProcessor(WithSelfDescribing(true))
+	defer p.Close()
+
+	meta := process(p)
+	assert.Equal(t, map[string]interface{}{
+		"id":     "int",
+		"name":   "string",
+		"gender": "string",
+		"nested": map[string]interface{}{
+			"type": "map",
+			"fields": map[string]interface{}{
+				"nested_id": "string",
+			},
+		},
+		"tags": map[string]interface{}{
+			"type":    "array",
+			"subtype": "string",
+		},
+	}, meta[SchemaMetaKey])
+
+	fingerprint, ok := meta[SchemaFingerprintMetaKey].(string)
+	if assert.True(t, ok) {
+		assert.Len(t, fingerprint, 64)
+	}
+
+	// Fingerprint only
+	fp := NewProcessor(WithSelfDescribingMode(SelfDescribingFingerprint))
+	defer fp.Close()
+
+	meta = process(fp)
+	assert.NotContains(t, meta, SchemaMetaKey)
+	assert.Equal(t, fingerprint, meta[SchemaFingerprintMetaKey])
+
+	// Disabled by default
+	np := NewProcessor()
+	defer np.Close()
+
+	assert.Empty(t, process(np))
+}
+
+func TestProcessor_AttachRaw(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	payload := `{"id":101,"name":"fred","gender":"male"}`
+
+	process := func(p *Processor, r *rule_manager.Rule) map[string]interface{} {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		})
+
+		msg := NewMessage()
+		msg.Rule = r
+		msg.Raw = raw
+
+		result, err := p.Process(msg)
+		if !assert.Nil(t, err) {
+			return nil
+		}
+
+		record, err := result.ProductEvent.GetContent()
+		if !assert.Nil(t, err) {
+			return nil
+		}
+
+		return record.Meta.AsMap()
+	}
+
+	p := NewProcessor(WithAttachRaw(true))
+	defer p.Close()
+
+	meta := process(p, CreateTestMessage().Rule)
+	assert.Equal(t, payload, meta[RawMetaKey])
+
+	// Masked fields are masked in raw payload as well
+	r := CreateTestRule()
+	r.SchemaConfig["name"] = map[string]interface{}{
+		"type": "string",
+		"mask": map[string]interface{}{"keep": float64(1)},
+	}
+
+	rm := rule_manager.NewRuleManager()
+	assert.Nil(t, rm.AddRule(r))
+
+	meta = process(p, r)
+
+	var raw map[string]interface{}
+	if assert.Nil(t, json.Unmarshal([]byte(meta[RawMetaKey].(string)), &raw)) {
+		assert.Equal(t, "***d", raw["name"])
+		assert.Equal(t, "male", raw["gender"])
+	}
+
+	// Dotted keys of partial updates and elements of arrays are masked as well
+	r.SchemaConfig["nested"] = map[string]interface{}{
+		"type": "map",
+		"fields": map[string]interface{}{
+			"nested_id": map[string]interface{}{"type": "string", "mask": true},
+		},
+	}
+
+	assert.Nil(t, rm.AddRule(r))
+
+	payload = `{"id":101,"nested.nested_id":"SECRET"}`
+	meta = process(p, r)
+	assert.NotContains(t, meta[RawMetaKey], "SECRET")
+
+	raw = nil
+	if assert.Nil(t, json.Unmarshal([]byte(meta[RawMetaKey].(string)), &raw)) {
+		assert.Equal(t, "******", raw["nested.nested_id"])
+	}
+
+	data := map[string]interface{}{
+		"Nested": []interface{}{
+			map[string]interface{}{"nested_id": "SECRET"},
+		},
+		"$append": map[string]interface{}{
+			"nested[0].NESTED_ID": "SECRET",
+		},
+	}
+
+	r.MaskRaw(data)
+	assert.Equal(t, "******", data["Nested"].([]interface{})[0].(map[string]interface{})["nested_id"])
+	assert.Equal(t, "******", data["$append"].(map[string]interface{})["nested[0].NESTED_ID"])
+
+	// Disabled by default
+	np := NewProcessor()
+	defer np.Close()
+
+	assert.Empty(t, process(np, CreateTestMessage().Rule))
+}
+
+func TestProcessor_ArrayElementFieldOrder(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	r := CreateTestRule()
+	r.SchemaConfig["items"] = map[string]interface{}{
+		"type":    "array",
+		"subtype": "map",
+		"fields": map[string]interface{}{
+			"sku": map[string]interface{}{"type": "string"},
+			"qty": map[string]interface{}{"type": "int"},
+		},
+	}
+
+	rm := rule_manager.NewRuleManager()
+	assert.Nil(t, rm.AddRule(r))
+
+	p := NewProcessor()
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"items":[{"sku":"a","qty":1},{"qty":2,"sku":"b"},{"sku":"c","qty":3}]}`),
+	})
+
+	for i := 0; i < 10; i++ {
+		msg := NewMessage()
+		msg.Rule = r
+		msg.Raw = raw
+
+		result, err := p.Process(msg)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		record, err := result.ProductEvent.GetContent()
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		items, err := record.GetValueByPath("items")
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		if !assert.Len(t, items.Array.Elements, 3) {
+			return
+		}
+
+		for _, ele := range items.Array.Elements {
+			names := make([]string, 0)
+			for _, field := range ele.Map.Fields {
+				names = append(names, field.Name)
+			}
+
+			assert.Equal(t, []string{"qty", "sku"}, names)
+		}
+	}
+}
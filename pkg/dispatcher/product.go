@@ -3,6 +3,7 @@ package dispatcher
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/connector"
@@ -11,7 +12,6 @@ import (
 	"github.com/BrobridgeOrg/schemer"
 	buffered_input "github.com/cfsghost/buffered-input"
 	"github.com/google/uuid"
-	"github.com/klauspost/compress/s2"
 	"github.com/nats-io/nats.go"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -201,7 +201,7 @@ func (pm *ProductManager) GetProduct(name string) *Product {
 	return v.(*Product)
 }
 
-func (pm *ProductManager) ApplySettings(name string, setting *product_sdk.ProductSetting) error {
+func (pm *ProductManager) ApplySettings(name string, setting *product_sdk.ProductSetting, opts ...func(*Product)) error {
 
 	ruleCount := 0
 	if setting.Rules != nil {
@@ -224,7 +224,7 @@ func (pm *ProductManager) ApplySettings(name string, setting *product_sdk.Produc
 		// New dataProduct
 		p := pm.CreateProduct(name, setting.Stream)
 
-		return p.ApplySettings(setting)
+		return p.ApplySettings(setting, opts...)
 	}
 
 	logger.Info("Update product",
@@ -233,7 +233,7 @@ func (pm *ProductManager) ApplySettings(name string, setting *product_sdk.Produc
 
 	// Apply new settings
 	p := v.(*Product)
-	return p.ApplySettings(setting)
+	return p.ApplySettings(setting, opts...)
 }
 
 type Product struct {
@@ -245,11 +245,16 @@ type Product struct {
 	Schema    *schemer.Schema
 	IsRunning bool
 
+	// SubjectPrefix is prepended to subjects of events of product, which is set by WithProductSubjectPrefix.
+	SubjectPrefix string
+
 	processor        *Processor
 	dispatcherBuffer *buffered_input.BufferedInput
 	manager          *ProductManager
 	watcher          *EventWatcher
 	onMessage        func(msg *Message)
+	activated        atomic.Bool
+	paused           atomic.Bool
 }
 
 func NewProduct(pm *ProductManager) *Product {
@@ -293,6 +298,7 @@ func (p *Product) reset() {
 	p.processor = NewProcessor(
 		WithDomain(p.Domain),
 		WithOutputHandler(p.emit),
+		WithRuleManager(p.Rules),
 	)
 }
 
@@ -411,20 +417,13 @@ func (p *Product) dispatch(msg *Message) {
 
 func (p *Product) handleMessage(eventName string, msg *nats.Msg) {
 
-	data := msg.Data
-
-	// Decompress message
-	if msg.Header.Get("Content-Encoding") == "s2" {
-		decompressedMessage, err := s2.Decode(nil, msg.Data)
-		if err != nil {
-			logger.Error("Failed to decompress message",
-				zap.Error(err),
-			)
-
-			return
-		}
+	data, err := decodeMessageData(msg)
+	if err != nil {
+		logger.Error("Failed to decompress message",
+			zap.Error(err),
+		)
 
-		data = decompressedMessage
+		return
 	}
 
 	m := NewMessage()
@@ -453,7 +452,7 @@ func (p *Product) HandleRawMessage(eventName string, raw []byte) {
 	p.processor.Push(m)
 }
 
-func (p *Product) ApplySettings(setting *product_sdk.ProductSetting) error {
+func (p *Product) ApplySettings(setting *product_sdk.ProductSetting, opts ...func(*Product)) error {
 
 	err := p.deactivate()
 	if err != nil {
@@ -465,10 +464,27 @@ func (p *Product) ApplySettings(setting *product_sdk.ProductSetting) error {
 	p.Name = setting.Name
 	p.Enabled = setting.Enabled
 
+	// Only product which was activated before is paused by disabling it
+	p.paused.Store(!setting.Enabled && p.activated.Load())
+
 	// Product schema
 	if setting.Schema != nil {
-		p.Schema = schemer.NewSchema()
-		err := schemer.Unmarshal(setting.Schema, p.Schema)
+		_, schema, err := rule_manager.ParseSchemaConfig(setting.Schema)
+		if err != nil {
+			return err
+		}
+
+		p.Schema = schema
+	}
+
+	// Options which product settings have no field for
+	p.SubjectPrefix = ""
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if len(p.SubjectPrefix) > 0 {
+		err := ValidateSubjectPrefix(p.SubjectPrefix)
 		if err != nil {
 			return err
 		}
@@ -491,6 +507,11 @@ func (p *Product) ApplySettings(setting *product_sdk.ProductSetting) error {
 	return nil
 }
 
+// Paused reports whether product was disabled by settings after it had been activated. Messages of paused product are dropped by processor.
+func (p *Product) Paused() bool {
+	return p.paused.Load()
+}
+
 func (p *Product) ApplyRules(rules []*product_sdk.Rule) error {
 
 	// Preparing new rules
@@ -499,11 +520,22 @@ func (p *Product) ApplyRules(rules []*product_sdk.Rule) error {
 	for _, r := range rules {
 		rule := rule_manager.NewRule(r)
 		rule.TargetSchema = p.Schema
-		rm.AddRule(rule)
+		rule.SubjectPrefix = p.SubjectPrefix
+
+		err := rm.AddRule(rule)
+		if err != nil {
+			logger.Error("Failed to apply rule, so its events are not dispatched",
+				zap.String("product", p.Name),
+				zap.String("rule", r.ID),
+				zap.String("event", r.Event),
+				zap.Error(err),
+			)
+		}
 	}
 
 	// Replace old rule manager
 	p.Rules = rm
+	p.processor.ReloadRules(rm)
 
 	if p.watcher == nil {
 		return nil
@@ -570,6 +602,7 @@ func (p *Product) Activate() error {
 	}
 
 	p.IsRunning = true
+	p.activated.Store(true)
 
 	logger.Info("Activating product",
 		zap.String("product", p.Name),
@@ -1,9 +1,11 @@
 package dispatcher
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
 	product_sdk "github.com/BrobridgeOrg/gravity-sdk/v2/product"
 	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
 	"github.com/stretchr/testify/assert"
@@ -265,3 +267,150 @@ func TestProductMessageHandler_StressTest(t *testing.T) {
 
 	assert.Equal(t, counter, targetNum)
 }
+
+func TestProduct_Disabled(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	setting := CreateTestProductSetting()
+	setting.Rules = map[string]*product_sdk.Rule{
+		"testRule": CreateTestProductRule(),
+	}
+
+	// Product which was never activated is not paused
+	product := NewProduct(nil)
+	product.ApplySettings(setting)
+	assert.False(t, product.Paused())
+
+	setting.Enabled = true
+	product.ApplySettings(setting)
+
+	rule := CreateTestRule()
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(rule)
+
+	done := make(chan *Message, 1)
+	drops := make(chan DropReason, 1)
+	p := NewProcessor(
+		WithRuleManager(rm),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+		WithDropHandler(func(msg *Message, reason DropReason) {
+			drops <- reason
+		}),
+	)
+	defer p.Close()
+
+	push := func() *Message {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(`{"id":101,"name":"fred"}`),
+		})
+
+		msg := NewMessage()
+		msg.Event = "dataCreated"
+		msg.Product = product
+		msg.Raw = raw
+
+		p.Push(msg)
+
+		return <-done
+	}
+
+	// Disabling product
+	setting.Enabled = false
+	product.ApplySettings(setting)
+
+	msg := push()
+	assert.True(t, msg.Ignore)
+	assert.Nil(t, msg.OutputMsg)
+	assert.Equal(t, DropReasonPaused, <-drops)
+	assert.Equal(t, RuleStats{Paused: 1}, p.RuleStats()[rule.ID])
+
+	// Re-enabling product
+	setting.Enabled = true
+	product.ApplySettings(setting)
+
+	msg = push()
+	assert.False(t, msg.Ignore)
+	assert.NotNil(t, msg.OutputMsg)
+	assert.Equal(t, RuleStats{Processed: 1, Paused: 1}, p.RuleStats()[rule.ID])
+}
+
+func TestProduct_SubjectPrefix(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	setting := CreateTestProductSetting()
+	setting.Rules = map[string]*product_sdk.Rule{
+		"testRule": CreateTestProductRule(),
+	}
+
+	// Prefix is stored next to fields of setting
+	raw, _ := json.Marshal(setting)
+	var doc map[string]interface{}
+	json.Unmarshal(raw, &doc)
+	doc[rule_manager.SubjectPrefixSettingKey] = "tenantA"
+	raw, _ = json.Marshal(doc)
+
+	prefix, err := storedSubjectPrefix(raw)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	product := NewProduct(nil)
+	assert.Nil(t, product.ApplySettings(setting, WithProductSubjectPrefix(prefix)))
+	assert.Equal(t, "tenantA", product.SubjectPrefix)
+
+	p := NewProcessor(
+		WithDomain("default"),
+		WithRuleManager(product.Rules),
+	)
+	defer p.Close()
+
+	raw, _ = json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"name":"fred"}`),
+	})
+
+	msg := NewMessage()
+	msg.Event = "dataCreated"
+	msg.Product = product
+	msg.Raw = raw
+
+	result, err := p.Process(msg)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, fmt.Sprintf("tenantA.$GVT.default.DP.TestDataProduct.%d.EVENT.dataCreated", result.Partition), result.OutputMsg.Subject)
+
+	// Invalid prefix is rejected
+	assert.ErrorIs(t, product.ApplySettings(setting, WithProductSubjectPrefix("tenant.*")), ErrInvalidSubjectPrefix)
+
+	// Settings without prefix clear it
+	assert.Nil(t, product.ApplySettings(setting))
+	assert.Empty(t, product.SubjectPrefix)
+}
+
+func TestProduct_ApplyInvalidRule(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	invalid := CreateTestProductRule()
+	invalid.ID = "invalid"
+	invalid.Event = "dataUpdated"
+	invalid.SchemaConfig["id"] = map[string]interface{}{"type": "int", "nullable": true}
+
+	setting := CreateTestProductSetting()
+	setting.Rules = map[string]*product_sdk.Rule{
+		"testRule": CreateTestProductRule(),
+		"invalid":  invalid,
+	}
+
+	// Rule which is not able to be applied never takes others down
+	product := NewProduct(nil)
+	assert.Nil(t, product.ApplySettings(setting))
+	assert.Equal(t, []string{"dataCreated"}, product.Rules.GetEvents())
+}
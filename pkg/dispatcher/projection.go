@@ -0,0 +1,86 @@
+package dispatcher
+
+import (
+	"slices"
+	"strings"
+
+	gravity_sdk_types_product_event "github.com/BrobridgeOrg/gravity-sdk/v2/types/product_event"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+)
+
+// Projection decides fields of record which a consumer is entitled to. Only fields in Allow are kept if it
+// was set, and fields in Deny are removed. Primary key fields and internal fields such as "$removedFields"
+// are always kept. Fields are names at top level of record.
+type Projection struct {
+	Allow []string
+	Deny  []string
+}
+
+// WithProjection writes processed messages to sink like WithSink, but records are limited to fields permitted
+// by projection. Sink receives a copy of message which is released once Write returns.
+func WithProjection(sink Sink, projection Projection) func(*Processor) {
+	return WithSink(&projectedSink{
+		sink:       sink,
+		projection: projection,
+	})
+}
+
+type projectedSink struct {
+	sink       Sink
+	projection Projection
+}
+
+func (s *projectedSink) Write(msg *Message) error {
+
+	c := msg.Clone()
+	defer c.Release()
+
+	r, err := c.ProductEvent.GetContent()
+	if err != nil {
+		return err
+	}
+
+	s.projection.apply(r, c.ProductEvent.PrimaryKeys)
+	c.ProductEvent.SetContent(r)
+
+	raw, err := gravity_sdk_types_product_event.Marshal(c.ProductEvent)
+	if err != nil {
+		return err
+	}
+
+	c.RawProductEvent = raw
+	c.OutputMsg.Data = raw
+
+	return s.sink.Write(c)
+}
+
+func (pj *Projection) apply(r *record_type.Record, primaryKeys []string) {
+
+	fields := r.Payload.Map.Fields[:0]
+	for _, field := range r.Payload.Map.Fields {
+		if pj.permits(field.Name, primaryKeys) {
+			fields = append(fields, field)
+		}
+	}
+
+	r.Payload.Map.Fields = fields
+}
+
+func (pj *Projection) permits(name string, primaryKeys []string) bool {
+
+	if strings.HasPrefix(name, "$") {
+		return true
+	}
+
+	for _, key := range primaryKeys {
+		if key == name || strings.HasPrefix(key, name+".") {
+			return true
+		}
+	}
+
+	if len(pj.Allow) > 0 && !slices.Contains(pj.Allow, name) {
+		return false
+	}
+
+	return !slices.Contains(pj.Deny, name)
+}
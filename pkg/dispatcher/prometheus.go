@@ -0,0 +1,74 @@
+package dispatcher
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusCollector exposes counters of rules of processor as Prometheus metrics labeled by product and event.
+// Counters of rules for the same product and event are summed up.
+type PrometheusCollector struct {
+	processor *Processor
+	processed *prometheus.Desc
+	errored   *prometheus.Desc
+	filtered  *prometheus.Desc
+	paused    *prometheus.Desc
+}
+
+type ruleLabels struct {
+	product string
+	event   string
+}
+
+// NewPrometheusCollector creates collector for processor, which is supposed to be registered by user.
+func NewPrometheusCollector(p *Processor) *PrometheusCollector {
+
+	labels := []string{"product", "event"}
+
+	return &PrometheusCollector{
+		processor: p,
+		processed: prometheus.NewDesc("gravity_dispatcher_processed_total", "Number of messages which were processed.", labels, nil),
+		errored:   prometheus.NewDesc("gravity_dispatcher_errors_total", "Number of messages which were failed to be processed.", labels, nil),
+		filtered:  prometheus.NewDesc("gravity_dispatcher_filtered_total", "Number of messages which were sampled, rate limited or had no result.", labels, nil),
+		paused:    prometheus.NewDesc("gravity_dispatcher_paused_total", "Number of messages which were dropped because product was disabled.", labels, nil),
+	}
+}
+
+func (c *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.processed
+	ch <- c.errored
+	ch <- c.filtered
+	ch <- c.paused
+}
+
+func (c *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
+
+	stats := make(map[ruleLabels]*RuleStats)
+	c.processor.ruleStats.counters.Range(func(key, value interface{}) bool {
+
+		rc := value.(*ruleCounters)
+		labels := ruleLabels{
+			product: rc.product,
+			event:   rc.event,
+		}
+
+		s, ok := stats[labels]
+		if !ok {
+			s = &RuleStats{}
+			stats[labels] = s
+		}
+
+		s.Processed += rc.processed.Load()
+		s.Errored += rc.errored.Load()
+		s.Filtered += rc.filtered.Load()
+		s.Paused += rc.paused.Load()
+
+		return true
+	})
+
+	for labels, s := range stats {
+		ch <- prometheus.MustNewConstMetric(c.processed, prometheus.CounterValue, float64(s.Processed), labels.product, labels.event)
+		ch <- prometheus.MustNewConstMetric(c.errored, prometheus.CounterValue, float64(s.Errored), labels.product, labels.event)
+		ch <- prometheus.MustNewConstMetric(c.filtered, prometheus.CounterValue, float64(s.Filtered), labels.product, labels.event)
+		ch <- prometheus.MustNewConstMetric(c.paused, prometheus.CounterValue, float64(s.Paused), labels.product, labels.event)
+	}
+}
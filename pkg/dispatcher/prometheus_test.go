@@ -0,0 +1,83 @@
+package dispatcher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	created := CreateTestRule()
+
+	// Primary key never exists
+	updated := CreateTestRule()
+	updated.Event = "dataUpdated"
+	updated.PrimaryKey = []string{"uid"}
+
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(created)
+	rm.AddRule(updated)
+
+	done := make(chan *Message, 8)
+	p := NewProcessor(
+		WithRuleManager(rm),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	events := []string{"dataCreated", "dataUpdated", "dataCreated"}
+	for _, event := range events {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      event,
+			RawPayload: []byte(`{"id":101,"name":"fred"}`),
+		})
+
+		msg := NewMessage()
+		msg.Event = event
+		msg.Raw = raw
+
+		p.Push(msg)
+	}
+
+	for range events {
+		<-done
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(NewPrometheusCollector(p))
+
+	expected := `
+# HELP gravity_dispatcher_errors_total Number of messages which were failed to be processed.
+# TYPE gravity_dispatcher_errors_total counter
+gravity_dispatcher_errors_total{event="dataCreated",product="TestDataProduct"} 0
+gravity_dispatcher_errors_total{event="dataUpdated",product="TestDataProduct"} 1
+# HELP gravity_dispatcher_processed_total Number of messages which were processed.
+# TYPE gravity_dispatcher_processed_total counter
+gravity_dispatcher_processed_total{event="dataCreated",product="TestDataProduct"} 2
+gravity_dispatcher_processed_total{event="dataUpdated",product="TestDataProduct"} 0
+`
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"gravity_dispatcher_processed_total",
+		"gravity_dispatcher_errors_total",
+	)
+	assert.Nil(t, err)
+
+	count, err := testutil.GatherAndCount(registry, "gravity_dispatcher_filtered_total")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = testutil.GatherAndCount(registry, "gravity_dispatcher_paused_total")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+}
@@ -0,0 +1,110 @@
+package dispatcher
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultQueueDepthInterval = 100 * time.Millisecond
+	flushPollInterval         = time.Millisecond
+)
+
+// queueDepthGauge samples number of messages which were pushed but not yet passed to output handler.
+// Sampling runs in its own goroutine, so processing never waits for gauge.
+type queueDepthGauge struct {
+	depth    atomic.Int64
+	fn       func(depth int)
+	interval time.Duration
+	done     chan struct{}
+}
+
+// WithQueueDepthGauge reports queue depth to fn periodically, and only when it was changed since the last report.
+func WithQueueDepthGauge(fn func(depth int)) func(*Processor) {
+	return func(p *Processor) {
+		p.queueDepth.fn = fn
+	}
+}
+
+// WithQueueDepthInterval sets how often queue depth is sampled for gauge, which is DefaultQueueDepthInterval by default.
+func WithQueueDepthInterval(interval time.Duration) func(*Processor) {
+	return func(p *Processor) {
+		p.queueDepth.interval = interval
+	}
+}
+
+// QueueDepth returns number of messages which were pushed but not yet passed to output handler.
+func (p *Processor) QueueDepth() int {
+	return int(p.queueDepth.depth.Load())
+}
+
+// Flush blocks until every message which was pushed has been passed to output handler, or ctx is done. Processor
+// remains open, so messages are able to be pushed afterward.
+func (p *Processor) Flush(ctx context.Context) error {
+
+	if p.queueDepth.depth.Load() == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(flushPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if p.queueDepth.depth.Load() == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+func (g *queueDepthGauge) start() {
+
+	if g.fn == nil {
+		return
+	}
+
+	if g.interval <= 0 {
+		g.interval = DefaultQueueDepthInterval
+	}
+
+	// Goroutine keeps its own reference, since stop clears the field
+	done := make(chan struct{})
+	g.done = done
+
+	go func() {
+
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+
+		last := int64(0)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				depth := g.depth.Load()
+				if depth == last {
+					continue
+				}
+
+				last = depth
+				g.fn(int(depth))
+			}
+		}
+	}()
+}
+
+func (g *queueDepthGauge) stop() {
+
+	if g.done == nil {
+		return
+	}
+
+	close(g.done)
+	g.done = nil
+}
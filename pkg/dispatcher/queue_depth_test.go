@@ -0,0 +1,114 @@
+package dispatcher
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_QueueDepthGauge(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	depths := make(chan int, 1024)
+	outputs := make(chan struct{}, 50)
+
+	p := NewProcessor(
+		WithQueueDepthGauge(func(depth int) {
+			depths <- depth
+		}),
+		WithQueueDepthInterval(5*time.Millisecond),
+		WithOutputHandler(func(msg *Message) {
+			// Slow consumer
+			time.Sleep(4 * time.Millisecond)
+			outputs <- struct{}{}
+		}),
+	)
+	defer p.Close()
+
+	pushTestMessages(p, 50)
+
+	for i := 0; i < 50; i++ {
+		<-outputs
+	}
+
+	// Waiting for gauge to report the empty queue
+	reported := make([]int, 0)
+	timeout := time.After(time.Second)
+	for len(reported) == 0 || reported[len(reported)-1] != 0 {
+		select {
+		case d := <-depths:
+			reported = append(reported, d)
+		case <-timeout:
+			t.Fatalf("queue depth never went back to zero: %v", reported)
+		}
+	}
+
+	assert.Equal(t, 0, p.QueueDepth())
+
+	// Depth rises from empty queue to a peak and then falls back
+	assert.Greater(t, reported[0], 0)
+
+	peak := 0
+	for i, d := range reported {
+		if d > reported[peak] {
+			peak = i
+		}
+	}
+
+	assert.Greater(t, reported[peak], 1)
+	for i := peak + 1; i < len(reported); i++ {
+		assert.Less(t, reported[i], reported[i-1])
+	}
+}
+
+func TestProcessor_Flush(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	var processed atomic.Int64
+	p := NewProcessor(
+		WithOutputHandler(func(msg *Message) {
+			time.Sleep(time.Millisecond)
+			processed.Add(1)
+		}),
+	)
+	defer p.Close()
+
+	pushTestMessages(p, 20)
+
+	err := p.Flush(context.Background())
+	if assert.Nil(t, err) {
+		assert.Equal(t, int64(20), processed.Load())
+		assert.Equal(t, 0, p.QueueDepth())
+	}
+
+	// Still usable
+	pushTestMessages(p, 5)
+
+	err = p.Flush(context.Background())
+	if assert.Nil(t, err) {
+		assert.Equal(t, int64(25), processed.Load())
+	}
+
+	// Deadline is reached before queue drains
+	block := make(chan struct{})
+	p = NewProcessor(
+		WithOutputHandler(func(msg *Message) {
+			<-block
+		}),
+	)
+	defer p.Close()
+	defer close(block)
+
+	pushTestMessages(p, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, p.Flush(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,118 @@
+package dispatcher
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+)
+
+var ErrInvalidRecord = errors.New("invalid record")
+
+// DiffRecords computes update which turns old record into new one. Only fields which were added or changed
+// are kept, nested maps are compared field by field, and sorted paths of fields which no longer exist are
+// listed in "$removedFields". Update is able to be applied to old record with record_type.ApplyChanges.
+func DiffRecords(old *record_type.Record, new *record_type.Record) (*record_type.Record, error) {
+
+	if old == nil || new == nil || !isMapValue(old.Payload) || !isMapValue(new.Payload) {
+		return nil, ErrInvalidRecord
+	}
+
+	update := record_type.NewRecord()
+	update.Meta = new.Meta
+
+	fields, removed := diffFields(old.Payload.Map.Fields, new.Payload.Map.Fields, "", nil)
+	update.Payload.Map.Fields = fields
+
+	if len(removed) > 0 {
+
+		sort.Strings(removed)
+
+		elements := make([]*record_type.Value, len(removed))
+		for i, path := range removed {
+			v, err := record_type.CreateValue(record_type.DataType_STRING, path)
+			if err != nil {
+				return nil, err
+			}
+
+			elements[i] = v
+		}
+
+		update.Payload.Map.Fields = append(update.Payload.Map.Fields, &record_type.Field{
+			Name: RemovedFieldsField,
+			Value: &record_type.Value{
+				Type: record_type.DataType_ARRAY,
+				Array: &record_type.ArrayValue{
+					Elements: elements,
+				},
+			},
+		})
+	}
+
+	return update, nil
+}
+
+func diffFields(oldFields []*record_type.Field, newFields []*record_type.Field, prefix string, removed []string) ([]*record_type.Field, []string) {
+
+	fields := make([]*record_type.Field, 0)
+	for _, f := range newFields {
+
+		prev := record_type.GetField(oldFields, f.Name)
+		if prev == nil {
+			fields = append(fields, f)
+			continue
+		}
+
+		// Compare nested fields if both are maps
+		if isMapValue(prev.Value) && isMapValue(f.Value) {
+
+			var changes []*record_type.Field
+			changes, removed = diffFields(prev.Value.Map.Fields, f.Value.Map.Fields, prefix+f.Name+".", removed)
+			if len(changes) == 0 {
+				continue
+			}
+
+			fields = append(fields, &record_type.Field{
+				Name: f.Name,
+				Value: &record_type.Value{
+					Type: record_type.DataType_MAP,
+					Map: &record_type.MapValue{
+						Fields: changes,
+					},
+				},
+			})
+
+			continue
+		}
+
+		if !isSameValue(prev.Value, f.Value) {
+			fields = append(fields, f)
+		}
+	}
+
+	for _, f := range oldFields {
+		if record_type.GetField(newFields, f.Name) == nil {
+			removed = append(removed, prefix+f.Name)
+		}
+	}
+
+	return fields, removed
+}
+
+func isMapValue(v *record_type.Value) bool {
+	return v != nil && v.Type == record_type.DataType_MAP && v.Map != nil
+}
+
+func isSameValue(a *record_type.Value, b *record_type.Value) bool {
+
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if a.Type != b.Type {
+		return false
+	}
+
+	return reflect.DeepEqual(record_type.GetValueData(a), record_type.GetValueData(b))
+}
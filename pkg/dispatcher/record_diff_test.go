@@ -0,0 +1,92 @@
+package dispatcher
+
+import (
+	"testing"
+
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"github.com/stretchr/testify/assert"
+)
+
+func CreateTestRecord(t *testing.T, data map[string]interface{}) *record_type.Record {
+
+	r := record_type.NewRecord()
+	err := record_type.UnmarshalMapData(data, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestDiffRecords(t *testing.T) {
+
+	old := CreateTestRecord(t, map[string]interface{}{
+		"id":     int64(101),
+		"name":   "fred",
+		"gender": "m",
+		"tags":   []interface{}{"a", "b"},
+		"nested": map[string]interface{}{
+			"nested_id": "n1",
+			"legacy":    "x",
+		},
+	})
+
+	new := CreateTestRecord(t, map[string]interface{}{
+		"id":    int64(101),
+		"name":  "stacy",
+		"email": "stacy@example.com",
+		"tags":  []interface{}{"a", "b"},
+		"nested": map[string]interface{}{
+			"nested_id": "n2",
+		},
+	})
+
+	update, err := DiffRecords(old, new)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"name":  "stacy",
+		"email": "stacy@example.com",
+		"nested": map[string]interface{}{
+			"nested_id": "n2",
+		},
+		"$removedFields": []interface{}{"gender", "nested.legacy"},
+	}, update.AsMap())
+}
+
+func TestDiffRecords_Unchanged(t *testing.T) {
+
+	data := map[string]interface{}{
+		"id":     int64(101),
+		"name":   "fred",
+		"nested": map[string]interface{}{"nested_id": "n1"},
+	}
+
+	update, err := DiffRecords(CreateTestRecord(t, data), CreateTestRecord(t, data))
+	if assert.Nil(t, err) {
+		assert.Empty(t, update.Payload.Map.Fields)
+	}
+}
+
+func TestDiffRecords_TypeChanged(t *testing.T) {
+
+	old := CreateTestRecord(t, map[string]interface{}{
+		"id":    int64(101),
+		"value": int64(1),
+	})
+
+	new := CreateTestRecord(t, map[string]interface{}{
+		"id":    int64(101),
+		"value": "1",
+	})
+
+	update, err := DiffRecords(old, new)
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]interface{}{"value": "1"}, update.AsMap())
+	}
+
+	_, err = DiffRecords(nil, new)
+	assert.ErrorIs(t, err, ErrInvalidRecord)
+}
@@ -0,0 +1,36 @@
+package dispatcher
+
+import (
+	gravity_sdk_types_product_event "github.com/BrobridgeOrg/gravity-sdk/v2/types/product_event"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"go.uber.org/zap"
+)
+
+// WithRecordHandler sets output handler which receives product event along with its decoded content, so
+// that content is decoded once by processor. Ignored messages are skipped, and messages which fail to be
+// decoded are passed to error handler instead.
+func WithRecordHandler(fn func(event *gravity_sdk_types_product_event.ProductEvent, r *record_type.Record)) func(*Processor) {
+	return func(p *Processor) {
+		p.outputHandler = func(msg *Message) {
+
+			if msg.Ignore || msg.ProductEvent == nil {
+				return
+			}
+
+			r, err := msg.getRecord()
+			if err != nil {
+				msg.Logger().Error("Failed to decode record",
+					zap.String("event", msg.Event),
+					zap.Error(err),
+				)
+
+				msg.Error = err
+				msg.Ignore = true
+				p.errorHandler(msg, err)
+				return
+			}
+
+			fn(msg.ProductEvent, r)
+		}
+	}
+}
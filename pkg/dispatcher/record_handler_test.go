@@ -0,0 +1,76 @@
+package dispatcher
+
+import (
+	"testing"
+
+	gravity_sdk_types_product_event "github.com/BrobridgeOrg/gravity-sdk/v2/types/product_event"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_RecordHandler(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	done := make(chan struct{})
+
+	p := NewProcessor(
+		WithRecordHandler(func(event *gravity_sdk_types_product_event.ProductEvent, r *record_type.Record) {
+			assert.Equal(t, "dataCreated", event.EventName)
+			assert.Equal(t, "TestDataProduct", event.Table)
+
+			id, err := r.GetValueDataByPath("id")
+			if assert.Nil(t, err) {
+				assert.Equal(t, int64(101), id)
+			}
+
+			name, err := r.GetValueDataByPath("name")
+			if assert.Nil(t, err) {
+				assert.Equal(t, "fred", name)
+			}
+
+			done <- struct{}{}
+		}),
+	)
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"name":"fred"}`),
+	})
+
+	msg := CreateTestMessage()
+	msg.Raw = raw
+
+	p.Push(msg)
+
+	<-done
+}
+
+func TestProcessor_RecordHandlerWithInvalidContent(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	errs := make(chan error, 1)
+
+	p := NewProcessor(
+		WithRecordHandler(func(event *gravity_sdk_types_product_event.ProductEvent, r *record_type.Record) {
+			t.Error("record handler should not be called")
+		}),
+		WithErrorHandler(func(msg *Message, err error) {
+			errs <- err
+		}),
+	)
+
+	msg := NewMessage()
+	msg.ProductEvent = &gravity_sdk_types_product_event.ProductEvent{
+		EventName: "dataCreated",
+		Data:      []byte("invalid"),
+	}
+
+	p.outputHandler(msg)
+
+	assert.NotNil(t, <-errs)
+	assert.True(t, msg.Ignore)
+}
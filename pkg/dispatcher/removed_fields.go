@@ -0,0 +1,46 @@
+package dispatcher
+
+import (
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+)
+
+// nullRemovedFields replaces "$removedFields" of record with null fields, which are named by paths as the
+// other fields of partial update.
+func nullRemovedFields(r *record_type.Record) {
+
+	fields := r.Payload.Map.Fields
+
+	var removed *record_type.Field
+	for i, field := range fields {
+		if field.Name == RemovedFieldsField {
+			removed = field
+			fields = append(fields[:i], fields[i+1:]...)
+			break
+		}
+	}
+
+	if removed == nil || removed.Value.Type != record_type.DataType_ARRAY {
+		return
+	}
+
+	for _, ele := range removed.Value.Array.Elements {
+
+		path, ok := record_type.GetValueData(ele).(string)
+		if !ok {
+			continue
+		}
+
+		// Removed field takes place of value of the same path
+		if field := record_type.GetField(fields, path); field != nil {
+			field.Value = &record_type.Value{Type: record_type.DataType_NULL}
+			continue
+		}
+
+		fields = append(fields, &record_type.Field{
+			Name:  path,
+			Value: &record_type.Value{Type: record_type.DataType_NULL},
+		})
+	}
+
+	r.Payload.Map.Fields = fields
+}
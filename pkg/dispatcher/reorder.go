@@ -0,0 +1,182 @@
+package dispatcher
+
+import (
+	"sort"
+)
+
+type reorderEntry struct {
+	seq uint64
+	msg *Message
+}
+
+// reorderBuffer holds messages of each primary key until they are in order of sequence. Processor had no reorder
+// buffer before, so it comes along with WithReorderMaxBuffered which bounds it, and it's off unless it's enabled
+// by WithReorderSequence. It's only used by goroutine which receives results, and by ResetState, which are
+// serialized by mutex of processor.
+type reorderBuffer struct {
+	sequenceOf  func(*Message) (uint64, bool)
+	maxBuffered int
+
+	// Sequence expected next and messages held for each key
+	next    map[string]uint64
+	pending map[string][]*reorderEntry
+}
+
+// WithReorderSequence holds messages of each primary key until they are in order of sequence which fn returns, such
+// as sequence of change in source database. Sequence of the first message of key is taken as the start, messages
+// which are not newer than the last one emitted are dropped as duplicates, and messages for which fn returns false
+// pass through. Messages held in buffer are not counted by QueueDepth.
+func WithReorderSequence(fn func(*Message) (uint64, bool)) func(*Processor) {
+	return func(p *Processor) {
+		p.reorder.sequenceOf = fn
+	}
+}
+
+func (rb *reorderBuffer) enabled() bool {
+	return rb.sequenceOf != nil
+}
+
+// arrange returns messages which are ready to be emitted in order, and keeps the rest.
+func (rb *reorderBuffer) arrange(msgs []*Message) []*Message {
+
+	if rb.next == nil {
+		rb.next = make(map[string]uint64)
+		rb.pending = make(map[string][]*reorderEntry)
+	}
+
+	if rb.maxBuffered <= 0 {
+		rb.maxBuffered = DefaultReorderMaxBuffered
+	}
+
+	ready := make([]*Message, 0, len(msgs))
+	for _, msg := range msgs {
+
+		key, ok := reorderKey(msg)
+		if !ok {
+			ready = append(ready, msg)
+			continue
+		}
+
+		seq, ok := rb.sequenceOf(msg)
+		if !ok {
+			ready = append(ready, msg)
+			continue
+		}
+
+		next, seen := rb.next[key]
+		if !seen {
+			next = seq
+		}
+
+		if seq < next {
+			ready = append(ready, markDuplicate(msg))
+			continue
+		}
+
+		if seq == next {
+			ready = append(ready, msg)
+			rb.next[key] = seq + 1
+			ready = rb.drain(key, ready)
+			continue
+		}
+
+		rb.next[key] = next
+
+		if !rb.hold(key, seq, msg) {
+			ready = append(ready, markDuplicate(msg))
+			continue
+		}
+
+		ready = rb.guard(key, ready)
+	}
+
+	return ready
+}
+
+// hold inserts message in order of sequence, and reports false if sequence is held already.
+func (rb *reorderBuffer) hold(key string, seq uint64, msg *Message) bool {
+
+	entries := rb.pending[key]
+
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].seq >= seq
+	})
+
+	if i < len(entries) && entries[i].seq == seq {
+		return false
+	}
+
+	entries = append(entries, nil)
+	copy(entries[i+1:], entries[i:])
+	entries[i] = &reorderEntry{seq: seq, msg: msg}
+
+	rb.pending[key] = entries
+
+	return true
+}
+
+// drain releases messages of key which are in order from sequence expected next.
+func (rb *reorderBuffer) drain(key string, ready []*Message) []*Message {
+
+	entries := rb.pending[key]
+	next := rb.next[key]
+
+	i := 0
+	for ; i < len(entries) && entries[i].seq == next; i++ {
+		ready = append(ready, entries[i].msg)
+		next++
+	}
+
+	rb.next[key] = next
+
+	if i == len(entries) {
+		delete(rb.pending, key)
+	} else {
+		rb.pending[key] = entries[i:]
+	}
+
+	return ready
+}
+
+// reset forgets sequences of keys, and returns messages which were held in order of sequence for each key.
+func (rb *reorderBuffer) reset() []*Message {
+
+	keys := make([]string, 0, len(rb.pending))
+	for key := range rb.pending {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	msgs := make([]*Message, 0)
+	for _, key := range keys {
+		for _, entry := range rb.pending[key] {
+			msgs = append(msgs, entry.msg)
+		}
+	}
+
+	rb.next = nil
+	rb.pending = nil
+
+	return msgs
+}
+
+func reorderKey(msg *Message) (string, bool) {
+
+	pe := msg.ProductEvent
+	if pe == nil || len(pe.PrimaryKey) == 0 {
+		return "", false
+	}
+
+	return pe.Table + "\x00" + string(pe.PrimaryKey), true
+}
+
+func markDuplicate(msg *Message) *Message {
+
+	msg.Ignore = true
+	if msg.Error == nil {
+		msg.Dropped = DropReasonDuplicate
+	}
+
+	return msg
+}
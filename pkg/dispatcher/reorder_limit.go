@@ -0,0 +1,38 @@
+package dispatcher
+
+import (
+	"go.uber.org/zap"
+)
+
+const DefaultReorderMaxBuffered = 1024
+
+// WithReorderMaxBuffered limits messages held for each key, which is DefaultReorderMaxBuffered by default. Once it's
+// exceeded, the oldest messages are flushed in order regardless of missing ones and a warning is logged, so memory
+// is bounded even if missing messages never arrive.
+func WithReorderMaxBuffered(n int) func(*Processor) {
+	return func(p *Processor) {
+		p.reorder.maxBuffered = n
+	}
+}
+
+// guard flushes the oldest messages of key until buffer is within limit.
+func (rb *reorderBuffer) guard(key string, ready []*Message) []*Message {
+
+	for len(rb.pending[key]) > rb.maxBuffered {
+
+		oldest := rb.pending[key][0]
+
+		oldest.msg.Logger().Warn("Reorder buffer is full, so the oldest message is flushed",
+			zap.String("event", oldest.msg.Event),
+			zap.Uint64("sequence", oldest.seq),
+			zap.Uint64("expected", rb.next[key]),
+			zap.Int("max_buffered", rb.maxBuffered),
+		)
+
+		// Missing messages are skipped
+		rb.next[key] = oldest.seq
+		ready = rb.drain(key, ready)
+	}
+
+	return ready
+}
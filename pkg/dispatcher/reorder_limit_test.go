@@ -0,0 +1,75 @@
+package dispatcher
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_ReorderMaxBuffered(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	done := make(chan uint64, 16)
+
+	sequenceOf := func(msg *Message) (uint64, bool) {
+		seq, err := strconv.ParseUint(fmt.Sprint(msg.Data.Payload["seq"]), 10, 64)
+		return seq, err == nil
+	}
+
+	p := NewProcessor(
+		WithReorderSequence(sequenceOf),
+		WithReorderMaxBuffered(2),
+		WithOutputHandler(func(msg *Message) {
+			seq, _ := sequenceOf(msg)
+			done <- seq
+		}),
+	)
+	defer p.Close()
+
+	push := func(seqs ...uint64) {
+		for _, seq := range seqs {
+			raw, _ := json.Marshal(MessageRawData{
+				Event:      "dataCreated",
+				RawPayload: []byte(fmt.Sprintf(`{"id":101,"seq":%d}`, seq)),
+			})
+
+			msg := CreateTestMessage()
+			msg.Raw = raw
+
+			p.Push(msg)
+		}
+	}
+
+	receive := func(n int) []uint64 {
+		seqs := make([]uint64, 0, n)
+		for i := 0; i < n; i++ {
+			select {
+			case seq := <-done:
+				seqs = append(seqs, seq)
+			case <-time.After(5 * time.Second):
+				return seqs
+			}
+		}
+
+		return seqs
+	}
+
+	// 4, 6 and 7 are held for missing ones, and buffer is over the limit once 7 arrives
+	push(1, 4, 6, 7)
+	assert.Equal(t, []uint64{1, 4}, receive(2))
+
+	select {
+	case seq := <-done:
+		assert.Fail(t, "message should be held", seq)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Missing one arrives
+	push(5)
+	assert.Equal(t, []uint64{5, 6, 7}, receive(3))
+}
@@ -0,0 +1,81 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_ReorderResetState(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	done := make(chan *Message, 16)
+
+	sequenceOf := func(msg *Message) (uint64, bool) {
+		seq, err := strconv.ParseUint(fmt.Sprint(msg.Data.Payload["seq"]), 10, 64)
+		return seq, err == nil
+	}
+
+	p := NewProcessor(
+		WithReorderSequence(sequenceOf),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	push := func(seqs ...uint64) {
+		for _, seq := range seqs {
+			raw, _ := json.Marshal(MessageRawData{
+				Event:      "dataCreated",
+				RawPayload: []byte(fmt.Sprintf(`{"id":101,"seq":%d}`, seq)),
+			})
+
+			msg := CreateTestMessage()
+			msg.Raw = raw
+
+			p.Push(msg)
+		}
+	}
+
+	receive := func() *Message {
+		select {
+		case msg := <-done:
+			return msg
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "message was not received")
+		}
+
+		return nil
+	}
+
+	push(1, 2, 2, 4)
+	for _, seq := range []uint64{1, 2} {
+		msg := receive()
+		assert.False(t, msg.Ignore)
+		assert.Equal(t, fmt.Sprint(seq), fmt.Sprint(msg.Data.Payload["seq"]))
+	}
+
+	// Duplicate is dropped
+	msg := receive()
+	assert.True(t, msg.Ignore)
+	assert.Equal(t, DropReasonDuplicate, msg.Dropped)
+
+	// Held message is emitted, and sequence of key starts over
+	assert.Nil(t, p.Flush(context.Background()))
+	p.ResetState()
+	msg = receive()
+	assert.False(t, msg.Ignore)
+	assert.Equal(t, "4", fmt.Sprint(msg.Data.Payload["seq"]))
+
+	push(2)
+	msg = receive()
+	assert.False(t, msg.Ignore)
+	assert.Equal(t, "2", fmt.Sprint(msg.Data.Payload["seq"]))
+}
@@ -0,0 +1,80 @@
+package dispatcher
+
+import (
+	"context"
+	"strings"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/nats-io/nats.go"
+)
+
+// decodeMessageData returns data of message, which is decompressed if needed.
+func decodeMessageData(msg *nats.Msg) ([]byte, error) {
+
+	if msg.Header.Get("Content-Encoding") != "s2" {
+		return msg.Data, nil
+	}
+
+	return s2.Decode(nil, msg.Data)
+}
+
+// ReplayStream reads events of stream from fromSeq to the last sequence when replay started, and pushes them
+// to processor again. Event name is taken from the last token of subject, and rules of processor are used as
+// messages don't belong to any product. It returns number of messages replayed.
+func (p *Processor) ReplayStream(ctx context.Context, js nats.JetStreamContext, stream string, fromSeq uint64) (int, error) {
+
+	info, err := js.StreamInfo(stream, nats.Context(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	// Nothing to replay
+	lastSeq := info.State.LastSeq
+	if info.State.Msgs == 0 || fromSeq > lastSeq {
+		return 0, nil
+	}
+
+	if fromSeq < info.State.FirstSeq {
+		fromSeq = info.State.FirstSeq
+	}
+
+	sub, err := js.SubscribeSync("",
+		nats.BindStream(stream),
+		nats.OrderedConsumer(),
+		nats.StartSequence(fromSeq),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer sub.Unsubscribe()
+
+	count := 0
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return count, err
+		}
+
+		meta, err := msg.Metadata()
+		if err != nil {
+			return count, err
+		}
+
+		data, err := decodeMessageData(msg)
+		if err != nil {
+			return count, err
+		}
+
+		m := NewMessage()
+		m.Context = ctx
+		m.Event = msg.Subject[strings.LastIndexByte(msg.Subject, '.')+1:]
+		m.Raw = data
+
+		p.Push(m)
+		count++
+
+		if meta.Sequence.Stream >= lastSeq {
+			return count, nil
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/klauspost/compress/s2"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func CreateTestJetStream(t testing.TB) nats.JetStreamContext {
+
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start()
+
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("test server is not ready")
+	}
+
+	t.Cleanup(s.Shutdown)
+
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(nc.Close)
+
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return js
+}
+
+func TestProcessor_ReplayStream(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	js := CreateTestJetStream(t)
+
+	stream := fmt.Sprintf(domainStream, "default")
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     stream,
+		Subjects: []string{fmt.Sprintf(domainEventSubject, "default", "*")},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// Publish raw events, and the last one is compressed
+	for i := 1; i <= 5; i++ {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(fmt.Sprintf(`{"id":%d,"name":"user-%d"}`, i, i)),
+		})
+
+		msg := nats.NewMsg(fmt.Sprintf(domainEventSubject, "default", "dataCreated"))
+		msg.Data = raw
+
+		if i == 5 {
+			msg.Header.Set("Content-Encoding", "s2")
+			msg.Data = s2.Encode(nil, raw)
+		}
+
+		_, err := js.PublishMsg(msg)
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(CreateTestRule())
+
+	done := make(chan *Message, 10)
+	p := NewProcessor(
+		WithRuleManager(rm),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := p.ReplayStream(ctx, js, stream, 3)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, 3, count)
+
+	for i := 3; i <= 5; i++ {
+		msg := <-done
+		if !assert.Nil(t, msg.Error) || !assert.False(t, msg.Ignore) {
+			return
+		}
+
+		r, err := msg.ProductEvent.GetContent()
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		if v, err := GetFieldValue(r, "id"); assert.Nil(t, err) {
+			assert.Equal(t, int64(i), v)
+		}
+	}
+
+	// Nothing beyond the last sequence
+	count, err = p.ReplayStream(ctx, js, stream, 6)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+}
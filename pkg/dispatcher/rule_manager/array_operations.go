@@ -0,0 +1,68 @@
+package rule_manager
+
+// Meta fields of partial update for array fields, which are objects keyed by path of field. Value can be
+// either an element or a list of elements.
+const (
+	ArrayAppendField        = "$append"
+	ArrayRemoveElementField = "$removeElement"
+)
+
+var arrayOperationFields = []string{
+	ArrayAppendField,
+	ArrayRemoveElementField,
+}
+
+// prepareArrayOperations checks fields of array operations and turns every value into a list of elements.
+func prepareArrayOperations(fields map[string]*FieldSchema, data map[string]interface{}) error {
+
+	for _, op := range arrayOperationFields {
+
+		v, ok := data[op]
+		if !ok {
+			continue
+		}
+
+		ops, ok := v.(map[string]interface{})
+		if !ok {
+			return &SchemaValidationError{
+				Field:  op,
+				Reason: "should be an object keyed by path of field",
+			}
+		}
+
+		for path, value := range ops {
+
+			elements, ok := value.([]interface{})
+			if !ok {
+				elements = []interface{}{value}
+			}
+
+			if len(fields) > 0 {
+
+				fs := LookupFieldSchema(fields, path)
+				if fs == nil || fs.Type != "array" {
+					return &SchemaValidationError{
+						Field:  path,
+						Reason: op + " is only available for array",
+					}
+				}
+
+				err := fs.validateElements(path, elements, nil)
+				if err != nil {
+					return err
+				}
+
+				coerced, err := fs.coerce(path, elements, nil)
+				if err != nil {
+					return err
+				}
+
+				elements = coerced.([]interface{})
+			}
+
+			ops[path] = elements
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package rule_manager
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ArraySortAsc  = "asc"
+	ArraySortDesc = "desc"
+)
+
+// parseArrayTransforms validates "unique" and "sort" properties of array, which dedupe and order elements after
+// they are coerced.
+func (fs *FieldSchema) parseArrayTransforms() error {
+
+	unique, hasUnique := fs.Props["unique"]
+	order, hasSort := fs.Props["sort"]
+
+	if !hasUnique && !hasSort {
+		return nil
+	}
+
+	if fs.Type != "array" {
+		return fmt.Errorf("%w: %s: unique and sort are only for arrays", ErrInvalidFieldDefinition, fs.Name)
+	}
+
+	if hasUnique {
+		if _, ok := unique.(bool); !ok {
+			return fmt.Errorf("%w: %s: unique should be a boolean", ErrInvalidFieldDefinition, fs.Name)
+		}
+	}
+
+	if !hasSort {
+		return nil
+	}
+
+	switch order {
+	case ArraySortAsc, ArraySortDesc:
+	default:
+		return fmt.Errorf("%w: %s: sort should be \"%s\" or \"%s\"", ErrInvalidFieldDefinition, fs.Name, ArraySortAsc, ArraySortDesc)
+	}
+
+	// Maps and arrays have no order
+	if fs.Subtype != nil && (fs.Subtype.Type == "map" || fs.Subtype.Type == "array") {
+		return fmt.Errorf("%w: %s: elements of %s are not able to be sorted", ErrInvalidFieldDefinition, fs.Name, fs.Subtype.Type)
+	}
+
+	return nil
+}
+
+func (fs *FieldSchema) hasArrayTransforms() bool {
+
+	if unique, _ := fs.Props["unique"].(bool); unique {
+		return true
+	}
+
+	_, ok := fs.Props["sort"]
+
+	return ok
+}
+
+// transformArray dedupes elements by keeping the first occurrence, and then sorts them if it's required.
+func (fs *FieldSchema) transformArray(elements []interface{}) []interface{} {
+
+	if unique, _ := fs.Props["unique"].(bool); unique {
+		elements = uniqueElements(elements)
+	}
+
+	order, ok := fs.Props["sort"].(string)
+	if !ok {
+		return elements
+	}
+
+	sort.SliceStable(elements, func(i, j int) bool {
+		if order == ArraySortDesc {
+			return compareElements(elements[j], elements[i]) < 0
+		}
+
+		return compareElements(elements[i], elements[j]) < 0
+	})
+
+	return elements
+}
+
+func uniqueElements(elements []interface{}) []interface{} {
+
+	seen := make(map[interface{}]struct{}, len(elements))
+	results := elements[:0]
+
+	for _, ele := range elements {
+
+		// Maps, arrays and bytes are not able to be keys of map
+		if ele != nil && !reflect.TypeOf(ele).Comparable() {
+			if !containsElement(results, ele) {
+				results = append(results, ele)
+			}
+
+			continue
+		}
+
+		if _, ok := seen[ele]; ok {
+			continue
+		}
+
+		seen[ele] = struct{}{}
+		results = append(results, ele)
+	}
+
+	// Clear references which were moved forward
+	clear(elements[len(results):])
+
+	return results
+}
+
+func containsElement(elements []interface{}, ele interface{}) bool {
+
+	for _, e := range elements {
+		if reflect.DeepEqual(e, ele) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Elements of different kinds are ordered by kind, with null first.
+func elementRank(v interface{}) int {
+
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return 2
+	case string:
+		return 3
+	case time.Time:
+		return 4
+	case []byte:
+		return 5
+	}
+
+	return 6
+}
+
+func compareElements(a interface{}, b interface{}) int {
+
+	ra, rb := elementRank(a), elementRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+
+	switch x := a.(type) {
+	case bool:
+		y := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+
+		return 1
+	case string:
+		return strings.Compare(x, b.(string))
+	case time.Time:
+		return x.Compare(b.(time.Time))
+	case []byte:
+		return bytes.Compare(x, b.([]byte))
+	}
+
+	if ra != 2 {
+		return 0
+	}
+
+	return compareNumbers(a, b)
+}
+
+func compareNumbers(a interface{}, b interface{}) int {
+
+	// Integers are compared exactly, since floats lose precision of large ones
+	if x, ok := toInt64(a); ok {
+		if y, ok := toInt64(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+
+			return 0
+		}
+	}
+
+	x, _ := toCoordinate(a)
+	y, _ := toCoordinate(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+
+	return 0
+}
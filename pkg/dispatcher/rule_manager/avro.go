@@ -0,0 +1,356 @@
+package rule_manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/converter"
+	"github.com/linkedin/goavro/v2"
+)
+
+const AvroNamespace = "gravity"
+
+var (
+	ErrUnsupportedAvroType = errors.New("unsupported avro type")
+	ErrInvalidAvroValue    = errors.New("invalid avro value")
+)
+
+var avroNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type avroCodec struct {
+	codec *goavro.Codec
+	err   error
+}
+
+// AvroCodec returns codec for Avro schema which is generated from schema of rule. Every field is nullable,
+// maps with fields become records and arrays keep element type.
+func (r *Rule) AvroCodec() (*goavro.Codec, error) {
+
+	r.avroOnce.Do(func() {
+
+		schema, err := r.AvroSchema()
+		if err != nil {
+			r.avroCodec.err = err
+			return
+		}
+
+		r.avroCodec.codec, r.avroCodec.err = goavro.NewCodec(schema)
+	})
+
+	return r.avroCodec.codec, r.avroCodec.err
+}
+
+// AvroSchema generates Avro schema in JSON from schema of rule.
+func (r *Rule) AvroSchema() (string, error) {
+
+	schema, err := avroRecordSchema(r.avroRecordName(), r.Fields)
+	if err != nil {
+		return "", err
+	}
+
+	schema["namespace"] = AvroNamespace
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// AvroNative converts data of record to native form which is accepted by codec of rule.
+func (r *Rule) AvroNative(data map[string]interface{}) (map[string]interface{}, error) {
+	return avroNativeRecord(r.avroRecordName(), r.Fields, data)
+}
+
+func (r *Rule) avroRecordName() string {
+
+	name := avroName(r.Product)
+	if len(name) == 0 {
+		return "Record"
+	}
+
+	return name
+}
+
+func avroName(name string) string {
+
+	var sb strings.Builder
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			c = '_'
+		}
+
+		sb.WriteRune(c)
+	}
+
+	return sb.String()
+}
+
+func sortedFieldNames(fields map[string]*FieldSchema) []string {
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func avroRecordSchema(name string, fields map[string]*FieldSchema) (map[string]interface{}, error) {
+
+	defs := make([]interface{}, 0, len(fields))
+	for _, fieldName := range sortedFieldNames(fields) {
+
+		if !avroNamePattern.MatchString(fieldName) {
+			return nil, fmt.Errorf("%w: invalid field name \"%s\"", ErrUnsupportedAvroType, fieldName)
+		}
+
+		t, err := avroType(name+"_"+fieldName, fields[fieldName])
+		if err != nil {
+			return nil, err
+		}
+
+		defs = append(defs, map[string]interface{}{
+			"name":    fieldName,
+			"type":    []interface{}{"null", t},
+			"default": nil,
+		})
+	}
+
+	return map[string]interface{}{
+		"type":   "record",
+		"name":   name,
+		"fields": defs,
+	}, nil
+}
+
+func avroType(name string, fs *FieldSchema) (interface{}, error) {
+
+	switch fs.Type {
+	case "int8", "int16", "int32", "uint8", "uint16":
+		return "int", nil
+	case "int", "int64", "uint", "uint32", "uint64":
+		return "long", nil
+	case "float":
+		return "double", nil
+	case "string":
+		return "string", nil
+	case "bool":
+		return "boolean", nil
+	case "binary", "bytes":
+		return "bytes", nil
+	case "time":
+		return map[string]interface{}{
+			"type":        "long",
+			"logicalType": "timestamp-micros",
+		}, nil
+	case "map":
+		if fs.ValueType != nil {
+			values, err := avroType(name+"_value", fs.ValueType)
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]interface{}{
+				"type":   "map",
+				"values": values,
+			}, nil
+		}
+
+		if fs.Fields == nil {
+			break
+		}
+
+		return avroRecordSchema(name, fs.Fields)
+	case "array":
+		if fs.Subtype == nil {
+			break
+		}
+
+		items, err := avroType(name+"_item", fs.Subtype)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"type":  "array",
+			"items": items,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: field \"%s\" is %s", ErrUnsupportedAvroType, fs.Name, fs.Type)
+}
+
+// avroTypeName returns name which is used by goavro for branch of union.
+func avroTypeName(name string, fs *FieldSchema) string {
+
+	switch fs.Type {
+	case "map":
+		if fs.ValueType != nil {
+			return "map"
+		}
+
+		return AvroNamespace + "." + name
+	case "array":
+		return "array"
+	case "time":
+		return "long.timestamp-micros"
+	}
+
+	t, _ := avroType(name, fs)
+	if v, ok := t.(string); ok {
+		return v
+	}
+
+	return ""
+}
+
+func avroNativeRecord(name string, fields map[string]*FieldSchema, data map[string]interface{}) (map[string]interface{}, error) {
+
+	native := make(map[string]interface{}, len(fields))
+	for fieldName, fs := range fields {
+
+		v, ok := data[fieldName]
+		if !ok || v == nil {
+			native[fieldName] = nil
+			continue
+		}
+
+		typeName := name + "_" + fieldName
+		value, err := avroNativeValue(typeName, fs, v)
+		if err != nil {
+			return nil, err
+		}
+
+		native[fieldName] = goavro.Union(avroTypeName(typeName, fs), value)
+	}
+
+	return native, nil
+}
+
+func avroNativeValue(name string, fs *FieldSchema, v interface{}) (interface{}, error) {
+
+	switch fs.Type {
+	case "int8", "int16", "int32", "uint8", "uint16":
+		n, ok := toInt64(v)
+		if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+			break
+		}
+
+		return int32(n), nil
+	case "int", "int64", "uint", "uint32", "uint64":
+		n, ok := toInt64(v)
+		if !ok {
+			break
+		}
+
+		return n, nil
+	case "float":
+		if f, ok := v.(float64); ok {
+			return f, nil
+		}
+	case "string":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "bool":
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case int8:
+			// Boolean of record
+			return b != 0, nil
+		}
+	case "binary", "bytes":
+		switch b := v.(type) {
+		case []byte:
+			return b, nil
+		case converter.Binary:
+			return []byte(b), nil
+		}
+	case "time":
+		if t, ok := v.(time.Time); ok {
+			return t, nil
+		}
+	case "map":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		if fs.ValueType == nil {
+			return avroNativeRecord(name, fs.Fields, m)
+		}
+
+		values := make(map[string]interface{}, len(m))
+		for k, ele := range m {
+			value, err := avroNativeValue(name+"_value", fs.ValueType, ele)
+			if err != nil {
+				return nil, err
+			}
+
+			values[k] = value
+		}
+
+		return values, nil
+	case "array":
+		elements, ok := v.([]interface{})
+		if !ok {
+			break
+		}
+
+		items := make([]interface{}, len(elements))
+		for i, ele := range elements {
+			item, err := avroNativeValue(name+"_item", fs.Subtype, ele)
+			if err != nil {
+				return nil, err
+			}
+
+			items[i] = item
+		}
+
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("%w: field \"%s\" expects %s, but got %T", ErrInvalidAvroValue, fs.Name, fs.Type, v)
+}
+
+func toInt64(v interface{}) (int64, bool) {
+
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, false
+		}
+
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,87 @@
+package rule_manager
+
+import (
+	product_sdk "github.com/BrobridgeOrg/gravity-sdk/v2/product"
+)
+
+// Clone returns a deep copy of rule, so changing schema config or primary key of either one never affects the
+// other. Clone of a rule which was added to rule manager is prepared as well, and its rate limit starts over.
+func (r *Rule) Clone() *Rule {
+
+	c := NewRule(&r.Rule)
+	c.TargetSchema = r.TargetSchema
+	c.OutputSubjectTemplate = r.OutputSubjectTemplate
+	c.SampleRate = r.SampleRate
+	c.MaxPerSecond = r.MaxPerSecond
+	c.PrimaryKeyStrategy = r.PrimaryKeyStrategy
+	c.PrimaryKeyField = r.PrimaryKeyField
+	c.PrimaryKeySeparator = r.PrimaryKeySeparator
+	c.PrimaryKeyEscaping = r.PrimaryKeyEscaping
+	c.UnknownFields = r.UnknownFields
+	c.EventTimeField = r.EventTimeField
+	c.CaseInsensitiveFields = r.CaseInsensitiveFields
+	c.CoerceStrings = r.CoerceStrings
+	c.StrictStrings = r.StrictStrings
+	c.Priority = r.Priority
+	c.RemovedFieldsAsNull = r.RemovedFieldsAsNull
+	c.Explode = r.Explode
+	c.RecordTransform = r.RecordTransform
+	c.SubjectPrefix = r.SubjectPrefix
+	c.StrictPrimaryKey = r.StrictPrimaryKey
+
+	if r.SchemaRef != nil {
+		ref := *r.SchemaRef
+		c.SchemaRef = &ref
+	}
+
+	if r.BaseSchemas != nil {
+		c.BaseSchemas = append([]SchemaRef{}, r.BaseSchemas...)
+	}
+
+	// Schema config has been resolved already
+	if r.Schema != nil {
+		c.applyConfigs()
+
+		// Transforms and coercion hook come from rule manager
+		c.fieldTransforms = r.fieldTransforms
+		c.recordTransform = r.recordTransform
+		c.coercionHook = r.coercionHook
+	}
+
+	return c
+}
+
+// CloneProductRule returns a deep copy of settings of rule.
+func CloneProductRule(rule *product_sdk.Rule) *product_sdk.Rule {
+	c := cloneProductRule(rule)
+	return &c
+}
+
+// cloneProductRule copies settings of rule, so rule never shares schema config with its source.
+func cloneProductRule(rule *product_sdk.Rule) product_sdk.Rule {
+
+	c := *rule
+
+	if rule.PrimaryKey != nil {
+		c.PrimaryKey = append([]string{}, rule.PrimaryKey...)
+	}
+
+	c.SchemaConfig = CloneSchemaConfig(rule.SchemaConfig)
+
+	if rule.HandlerConfig != nil {
+		hc := *rule.HandlerConfig
+		c.HandlerConfig = &hc
+	}
+
+	return c
+}
+
+// CloneSchemaConfig returns a deep copy of schema config, including nested fields.
+func CloneSchemaConfig(config map[string]interface{}) map[string]interface{} {
+
+	if config == nil {
+		return nil
+	}
+
+	return cloneValue(config).(map[string]interface{})
+}
@@ -0,0 +1,89 @@
+package rule_manager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CoercionHook converts raw value of field in domain-specific way, such as "Y" and "N" for bool. It returns
+// false if value is left to built-in coercion.
+type CoercionHook func(field FieldSchema, raw interface{}) (interface{}, bool, error)
+
+// WithCoercionHook sets hook which is consulted for every value of fields with schema before built-in
+// coercion, including fields of nested maps and elements of arrays. Values from hook are still checked against
+// types of fields.
+func WithCoercionHook(hook CoercionHook) func(*RuleManager) {
+	return func(rm *RuleManager) {
+		rm.coercionHook = hook
+	}
+}
+
+func applyCoercionHook(hook CoercionHook, fields map[string]*FieldSchema, prefix string, data map[string]interface{}, ts *transformState) error {
+
+	for k, v := range data {
+
+		// Skip internal fields
+		if strings.HasPrefix(k, "$") {
+			continue
+		}
+
+		err := ts.interrupted()
+		if err != nil {
+			return err
+		}
+
+		fs := LookupFieldSchema(fields, k)
+		if fs == nil {
+			continue
+		}
+
+		v, err = fs.applyCoercionHook(hook, prefix+k, v, ts)
+		if err != nil {
+			return err
+		}
+
+		data[k] = v
+	}
+
+	return nil
+}
+
+func (fs *FieldSchema) applyCoercionHook(hook CoercionHook, path string, value interface{}, ts *transformState) (interface{}, error) {
+
+	v, handled, err := hook(*fs, value)
+	if err != nil {
+		err = fmt.Errorf("field \"%s\": %w", path, err)
+		if ts.collect(err) {
+			return value, nil
+		}
+
+		return nil, err
+	}
+
+	if handled {
+		return v, nil
+	}
+
+	switch fs.Type {
+	case "map":
+		m, ok := value.(map[string]interface{})
+		if ok && fs.ValueType == nil {
+			return value, applyCoercionHook(hook, fs.Fields, path+".", m, ts)
+		}
+	case "array":
+		elements, ok := value.([]interface{})
+		if !ok || fs.Subtype == nil {
+			return value, nil
+		}
+
+		for i, ele := range elements {
+			elements[i], err = fs.Subtype.applyCoercionHook(hook, path+"."+strconv.Itoa(i), ele, ts)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return value, nil
+}
@@ -0,0 +1,225 @@
+package rule_manager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidRule = errors.New("invalid rule")
+
+type SchemaChangeKind string
+
+const (
+	SchemaChangeFieldAdded        SchemaChangeKind = "field_added"
+	SchemaChangeFieldRemoved      SchemaChangeKind = "field_removed"
+	SchemaChangeTypeChanged       SchemaChangeKind = "type_changed"
+	SchemaChangeRequiredAdded     SchemaChangeKind = "required_added"
+	SchemaChangePrimaryKeyChanged SchemaChangeKind = "primary_key_changed"
+)
+
+// SchemaChange describes difference of a field between two schemas.
+type SchemaChange struct {
+	Path     string
+	Kind     SchemaChangeKind
+	From     string
+	To       string
+	Breaking bool
+}
+
+// CompatReport lists changes from old schema to new one, sorted by path.
+type CompatReport struct {
+	Changes []SchemaChange
+}
+
+// Compatible reports whether records of old schema are still accepted by new schema.
+func (cr CompatReport) Compatible() bool {
+	return len(cr.BreakingChanges()) == 0
+}
+
+func (cr CompatReport) BreakingChanges() []SchemaChange {
+
+	changes := make([]SchemaChange, 0)
+	for _, c := range cr.Changes {
+		if c.Breaking {
+			changes = append(changes, c)
+		}
+	}
+
+	return changes
+}
+
+// widerIntegers are types which are able to hold every value of key type.
+var widerIntegers = map[string][]string{
+	"int8":   {"int16", "int32", "int64", "int"},
+	"int16":  {"int32", "int64", "int"},
+	"int32":  {"int64", "int"},
+	"int64":  {"int"},
+	"int":    {"int64"},
+	"uint8":  {"uint16", "uint32", "uint64", "uint", "int16", "int32", "int64", "int"},
+	"uint16": {"uint32", "uint64", "uint", "int32", "int64", "int"},
+	"uint32": {"uint64", "uint", "int64", "int"},
+	"uint64": {"uint"},
+	"uint":   {"uint64"},
+}
+
+// CheckCompatibility compares schemas of two rules without applying any of them. Removed fields, changes of
+// primary key, new required fields and types which are not able to hold old values are breaking.
+func CheckCompatibility(old *Rule, new *Rule) (CompatReport, error) {
+
+	report := CompatReport{
+		Changes: make([]SchemaChange, 0),
+	}
+
+	if old == nil || new == nil {
+		return report, ErrInvalidRule
+	}
+
+	oldFields, err := old.fieldSchemas()
+	if err != nil {
+		return report, fmt.Errorf("%w: old: %w", ErrInvalidRule, err)
+	}
+
+	newFields, err := new.fieldSchemas()
+	if err != nil {
+		return report, fmt.Errorf("%w: new: %w", ErrInvalidRule, err)
+	}
+
+	if strings.Join(old.PrimaryKey, ",") != strings.Join(new.PrimaryKey, ",") {
+		report.Changes = append(report.Changes, SchemaChange{
+			Kind:     SchemaChangePrimaryKeyChanged,
+			From:     strings.Join(old.PrimaryKey, ","),
+			To:       strings.Join(new.PrimaryKey, ","),
+			Breaking: true,
+		})
+	}
+
+	primaryKeys := make(map[string]bool, len(new.PrimaryKey))
+	for _, pk := range new.PrimaryKey {
+		primaryKeys[pk] = true
+	}
+
+	report.Changes = compareFields(oldFields, newFields, "", primaryKeys, report.Changes)
+
+	sort.SliceStable(report.Changes, func(i, j int) bool {
+		return report.Changes[i].Path < report.Changes[j].Path
+	})
+
+	return report, nil
+}
+
+// fieldSchemas returns parsed fields, and parses SchemaConfig for rules which were not applied.
+func (r *Rule) fieldSchemas() (map[string]*FieldSchema, error) {
+
+	if r.Fields != nil {
+		return r.Fields, nil
+	}
+
+	return ParseFieldSchemas(r.SchemaConfig)
+}
+
+func compareFields(oldFields map[string]*FieldSchema, newFields map[string]*FieldSchema, prefix string, primaryKeys map[string]bool, changes []SchemaChange) []SchemaChange {
+
+	for _, name := range sortedFieldNames(oldFields) {
+
+		path := prefix + name
+
+		fs, ok := newFields[name]
+		if !ok {
+			changes = append(changes, SchemaChange{
+				Path:     path,
+				Kind:     SchemaChangeFieldRemoved,
+				From:     oldFields[name].Type,
+				Breaking: true,
+			})
+			continue
+		}
+
+		changes = compareField(oldFields[name], fs, path, primaryKeys, changes)
+	}
+
+	for _, name := range sortedFieldNames(newFields) {
+
+		if _, ok := oldFields[name]; ok {
+			continue
+		}
+
+		path := prefix + name
+		fs := newFields[name]
+
+		if primaryKeys[path] || fs.isRequired() {
+			changes = append(changes, SchemaChange{
+				Path:     path,
+				Kind:     SchemaChangeRequiredAdded,
+				To:       fs.Type,
+				Breaking: true,
+			})
+			continue
+		}
+
+		changes = append(changes, SchemaChange{
+			Path: path,
+			Kind: SchemaChangeFieldAdded,
+			To:   fs.Type,
+		})
+	}
+
+	return changes
+}
+
+func compareField(old *FieldSchema, new *FieldSchema, path string, primaryKeys map[string]bool, changes []SchemaChange) []SchemaChange {
+
+	if old.Type != new.Type {
+		return append(changes, SchemaChange{
+			Path:     path,
+			Kind:     SchemaChangeTypeChanged,
+			From:     old.Type,
+			To:       new.Type,
+			Breaking: !isWidening(old.Type, new.Type),
+		})
+	}
+
+	switch old.Type {
+	case "map":
+		if old.ValueType != nil && new.ValueType != nil {
+			return compareField(old.ValueType, new.ValueType, path+".*", primaryKeys, changes)
+		}
+
+		return compareFields(old.Fields, new.Fields, path+".", primaryKeys, changes)
+	case "array":
+		if old.Subtype != nil && new.Subtype != nil {
+			return compareField(old.Subtype, new.Subtype, path+"[]", primaryKeys, changes)
+		}
+	}
+
+	return changes
+}
+
+func isWidening(from string, to string) bool {
+
+	for _, t := range widerIntegers[from] {
+		if t == to {
+			return true
+		}
+	}
+
+	// Float keeps precision of integers up to 32 bits
+	if it, ok := IntegerTypes[from]; ok && it.Bits <= 32 && to == "float" {
+		return true
+	}
+
+	return false
+}
+
+func (fs *FieldSchema) isRequired() bool {
+
+	notNull, _ := fs.Props["notNull"].(bool)
+	if !notNull {
+		return false
+	}
+
+	_, hasDefault := fs.Props["default"]
+
+	return !hasDefault
+}
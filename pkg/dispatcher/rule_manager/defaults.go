@@ -0,0 +1,139 @@
+package rule_manager
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IsPartialUpdate checks whether data only carries changes of a record, such as removed fields or dotted paths.
+func IsPartialUpdate(data map[string]interface{}) bool {
+
+	for k := range data {
+		if strings.HasPrefix(k, "$") || strings.Contains(k, ".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseDefault validates "default" and "defaultOn" properties of field. The default can be an object keyed
+// by event name, unless field is a map which takes object as its own value.
+func (fs *FieldSchema) parseDefault() error {
+
+	if v, ok := fs.Props["defaultOn"]; ok {
+
+		events, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%w: %s: defaultOn should be a list of event names", ErrInvalidFieldDefinition, fs.Name)
+		}
+
+		for _, event := range events {
+			if _, ok := event.(string); !ok {
+				return fmt.Errorf("%w: %s: defaultOn should be a list of event names", ErrInvalidFieldDefinition, fs.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// DefaultFor returns default value of field for specific event.
+func (fs *FieldSchema) DefaultFor(event string) (interface{}, bool) {
+
+	def, ok := fs.Props["default"]
+	if !ok {
+		return nil, false
+	}
+
+	if events, ok := fs.Props["defaultOn"].([]interface{}); ok {
+
+		matched := false
+		for _, e := range events {
+			if e.(string) == event {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return nil, false
+		}
+	}
+
+	// Defaults keyed by event name
+	if m, ok := def.(map[string]interface{}); ok && fs.isDefaultKeyedByEvent() {
+		v, ok := m[event]
+		if !ok {
+			return nil, false
+		}
+
+		return cloneValue(v), true
+	}
+
+	return cloneValue(def), true
+}
+
+func (fs *FieldSchema) isDefaultKeyedByEvent() bool {
+	return fs.Type != "map" && fs.Type != "any"
+}
+
+// hasEventDefaults checks whether default of field only applies to specific events.
+func (fs *FieldSchema) hasEventDefaults() bool {
+
+	if _, ok := fs.Props["defaultOn"]; ok {
+		return true
+	}
+
+	_, ok := fs.Props["default"].(map[string]interface{})
+
+	return ok && fs.isDefaultKeyedByEvent()
+}
+
+// applyDefaults fills absent fields with defaults for event, while explicit null is kept. It only works on complete records,
+// changes of partial update are not supposed to be filled.
+func applyDefaults(fields map[string]*FieldSchema, event string, data map[string]interface{}) {
+
+	for name, fs := range fields {
+
+		v, ok := data[name]
+		if !ok {
+			if def, ok := fs.DefaultFor(event); ok {
+				data[name] = def
+			}
+
+			continue
+		}
+
+		if fs.Type != "map" || fs.Fields == nil {
+			continue
+		}
+
+		// Nested fields
+		if m, ok := v.(map[string]interface{}); ok {
+			applyDefaults(fs.Fields, event, m)
+		}
+	}
+}
+
+func cloneValue(v interface{}) interface{} {
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, ele := range val {
+			m[k] = cloneValue(ele)
+		}
+
+		return m
+	case []interface{}:
+		arr := make([]interface{}, len(val))
+		for i, ele := range val {
+			arr[i] = cloneValue(ele)
+		}
+
+		return arr
+	}
+
+	return v
+}
@@ -0,0 +1,67 @@
+package rule_manager
+
+import (
+	"sort"
+	"strings"
+)
+
+func (r *Rule) prepareDeprecatedFields() {
+	r.deprecatedFields = collectDeprecatedFields(r.Fields, "", nil)
+	sort.Strings(r.deprecatedFields)
+}
+
+func collectDeprecatedFields(fields map[string]*FieldSchema, prefix string, paths []string) []string {
+
+	for name, fs := range fields {
+
+		if deprecated, ok := fs.Props["deprecated"].(bool); ok && deprecated {
+			paths = append(paths, prefix+name)
+		}
+
+		if fs.Type == "map" {
+			paths = collectDeprecatedFields(fs.Fields, prefix+name+".", paths)
+		}
+	}
+
+	return paths
+}
+
+// DeprecatedFields returns paths of fields which are marked as deprecated in schema and present in payload.
+func (r *Rule) DeprecatedFields(payload map[string]interface{}) []string {
+
+	if len(r.deprecatedFields) == 0 {
+		return nil
+	}
+
+	var found []string
+	for _, path := range r.deprecatedFields {
+		if hasPath(payload, path) {
+			found = append(found, path)
+		}
+	}
+
+	return found
+}
+
+func hasPath(data map[string]interface{}, path string) bool {
+
+	for {
+		name, rest, nested := strings.Cut(path, ".")
+
+		v, ok := data[name]
+		if !ok {
+			return false
+		}
+
+		if !nested {
+			return true
+		}
+
+		data, ok = v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		path = rest
+	}
+}
@@ -0,0 +1,156 @@
+package rule_manager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// validateElements checks values of fields, including fields of nested maps and every element of arrays,
+// against declared types before normalizing, since schemer drops the entire array when any of elements is
+// invalid and turns the other mismatched values into zero values.
+func validateElements(fields map[string]*FieldSchema, prefix string, data map[string]interface{}, ts *transformState) error {
+
+	for k, v := range data {
+
+		// Skip internal fields
+		if strings.HasPrefix(k, "$") {
+			continue
+		}
+
+		err := ts.interrupted()
+		if err != nil {
+			return err
+		}
+
+		fs := LookupFieldSchema(fields, k)
+		if fs == nil {
+			continue
+		}
+
+		err = fs.validateElements(prefix+k, v, ts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *FieldSchema) validateElements(path string, value interface{}, ts *transformState) error {
+
+	switch fs.Type {
+	case "array":
+
+		elements, ok := value.([]interface{})
+		if !ok || fs.Subtype == nil {
+			return nil
+		}
+
+		for i, ele := range elements {
+
+			err := ts.interrupted()
+			if err != nil {
+				return err
+			}
+
+			err = ts.checkValue(fs.Subtype, path+"."+strconv.Itoa(i), ele)
+			if err != nil {
+				if ts.collect(err) {
+					continue
+				}
+
+				return err
+			}
+
+			// Path is only needed for nested elements
+			switch ele.(type) {
+			case map[string]interface{}, []interface{}:
+				err := fs.Subtype.validateElements(path+"."+strconv.Itoa(i), ele, ts)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+	case "map":
+
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		return validateElements(fs.Fields, path+".", m, ts)
+
+	default:
+
+		// Fields of nested maps are checked as well, so path is full path of field
+		err := ts.checkValue(fs, path, value)
+		if err != nil && !ts.collect(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkElement verifies element is able to be coerced to type of field.
+func (fs *FieldSchema) checkElement(value interface{}) bool {
+
+	if value == nil {
+		return true
+	}
+
+	valid := true
+	switch fs.BaseType() {
+	case "string":
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			valid = false
+		}
+	case "int", "uint", "float":
+		switch v := value.(type) {
+		case string:
+			_, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			valid = err == nil
+		case map[string]interface{}, []interface{}:
+			valid = false
+		}
+	case "bool":
+		switch v := value.(type) {
+		case string:
+			_, err := strconv.ParseBool(v)
+			valid = err == nil
+		case map[string]interface{}, []interface{}:
+			valid = false
+		}
+	case "map":
+		_, valid = value.(map[string]interface{})
+	case "array":
+		_, valid = value.([]interface{})
+	}
+
+	return valid
+}
+
+func typeNameOf(value interface{}) string {
+
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case float64, float32:
+		return "float"
+	case int, int8, int16, int32, int64:
+		return "int"
+	case uint, uint8, uint16, uint32, uint64:
+		return "uint"
+	case map[string]interface{}:
+		return "map"
+	case []interface{}:
+		return "array"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
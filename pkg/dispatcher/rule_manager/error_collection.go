@@ -0,0 +1,35 @@
+package rule_manager
+
+import (
+	"strings"
+)
+
+// ErrorCollectionMode decides whether transforming stops at the first error of fields.
+type ErrorCollectionMode int
+
+const (
+	// FailFast returns the first error of fields as it is.
+	FailFast ErrorCollectionMode = iota
+
+	// CollectAll goes through the entire payload and returns every error of fields as FieldErrors.
+	CollectAll
+)
+
+// FieldErrors aggregates errors of fields in a payload, which are sorted by message.
+type FieldErrors struct {
+	Errors []error
+}
+
+func (e *FieldErrors) Error() string {
+
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+func (e *FieldErrors) Unwrap() []error {
+	return e.Errors
+}
@@ -0,0 +1,42 @@
+package rule_manager
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SchemaValidationError indicates value of field doesn't meet constraints of schema.
+type SchemaValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("field \"%s\": %s", e.Field, e.Reason)
+}
+
+// CoercionError indicates value of field is unable to be converted to declared type.
+type CoercionError struct {
+	Field string
+	From  string
+	To    string
+	Value interface{}
+}
+
+func (e *CoercionError) Error() string {
+
+	if e.Value == nil {
+		return fmt.Sprintf("field \"%s\": cannot coerce %s to %s", e.Field, e.From, e.To)
+	}
+
+	return fmt.Sprintf("field \"%s\": cannot coerce %s (%v) to %s", e.Field, e.From, e.Value, e.To)
+}
+
+// MissingPrimaryKeyError indicates primary key fields are absent from record.
+type MissingPrimaryKeyError struct {
+	Keys []string
+}
+
+func (e *MissingPrimaryKeyError) Error() string {
+	return fmt.Sprintf("missing primary key: %s", strings.Join(e.Keys, ", "))
+}
@@ -0,0 +1,52 @@
+package rule_manager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"github.com/BrobridgeOrg/schemer/types"
+)
+
+var ErrInvalidEventTimeField = errors.New("invalid event time field")
+
+func (r *Rule) prepareEventTimeField() error {
+
+	if len(r.EventTimeField) == 0 || len(r.Fields) == 0 {
+		return nil
+	}
+
+	fs := LookupFieldSchema(r.Fields, r.EventTimeField)
+	if fs == nil || fs.Type != "time" {
+		return fmt.Errorf("%w: field \"%s\" should be defined as time", ErrInvalidEventTimeField, r.EventTimeField)
+	}
+
+	return nil
+}
+
+// EventTime extracts event time from record. It returns false if rule has no event time field or
+// value of field is not available.
+func (r *Rule) EventTime(record *record_type.Record) (time.Time, bool) {
+
+	if len(r.EventTimeField) == 0 {
+		return time.Time{}, false
+	}
+
+	v, err := record.GetValueDataByPath(r.EventTimeField)
+	if err != nil || v == nil {
+		return time.Time{}, false
+	}
+
+	if t, ok := v.(time.Time); ok {
+		return t, true
+	}
+
+	// Value which was not normalized by schema
+	t, err := types.NewTime().GetValue(v)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
@@ -0,0 +1,44 @@
+package rule_manager
+
+// ExplodePayload splits data into one payload for every element of array field named by Explode, such as line
+// items of an order. Scalar fields of data are merged into each element unless element has field of the same
+// name. Data is returned as it is if Explode is not set or field is not an array, and elements which are not
+// maps are skipped.
+func (r *Rule) ExplodePayload(data map[string]interface{}) []map[string]interface{} {
+
+	if len(r.Explode) == 0 {
+		return []map[string]interface{}{data}
+	}
+
+	elements, ok := data[r.Explode].([]interface{})
+	if !ok {
+		return []map[string]interface{}{data}
+	}
+
+	payloads := make([]map[string]interface{}, 0, len(elements))
+	for _, ele := range elements {
+
+		m, ok := ele.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		payload := make(map[string]interface{}, len(data)+len(m))
+		for k, v := range data {
+			switch v.(type) {
+			case map[string]interface{}, []interface{}:
+				continue
+			}
+
+			payload[k] = v
+		}
+
+		for k, v := range m {
+			payload[k] = v
+		}
+
+		payloads = append(payloads, payload)
+	}
+
+	return payloads
+}
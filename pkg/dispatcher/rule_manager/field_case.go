@@ -0,0 +1,71 @@
+package rule_manager
+
+import (
+	"strings"
+)
+
+// lookupFieldName finds declared name of field regardless of case. Exact match is preferred.
+func lookupFieldName(fields map[string]*FieldSchema, key string) (string, bool) {
+
+	if _, ok := fields[key]; ok {
+		return key, true
+	}
+
+	for name := range fields {
+		if strings.EqualFold(name, key) {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// normalizeFieldCase renames keys of data to the casing declared in schema. It fails if multiple keys
+// refer to the same field.
+func normalizeFieldCase(fields map[string]*FieldSchema, prefix string, data map[string]interface{}) error {
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	resolved := make(map[string]string, len(data))
+	for k := range data {
+
+		// Skip internal fields and paths of partial update
+		if strings.HasPrefix(k, "$") || strings.Contains(k, ".") {
+			continue
+		}
+
+		name, ok := lookupFieldName(fields, k)
+		if !ok {
+			continue
+		}
+
+		if other, ok := resolved[name]; ok {
+			return &SchemaValidationError{
+				Field:  prefix + name,
+				Reason: "ambiguous field name between \"" + other + "\" and \"" + k + "\"",
+			}
+		}
+
+		resolved[name] = k
+	}
+
+	for name, k := range resolved {
+
+		v := data[k]
+		if name != k {
+			delete(data, k)
+			data[name] = v
+		}
+
+		if m, ok := v.(map[string]interface{}); ok && fields[name].Type == "map" {
+			err := normalizeFieldCase(fields[name].Fields, prefix+name+".", m)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,581 @@
+package rule_manager
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/converter"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"github.com/BrobridgeOrg/schemer"
+)
+
+var (
+	ErrInvalidFieldDefinition = errors.New("invalid field definition")
+	ErrInvalidFieldType       = errors.New("invalid field type")
+)
+
+type IntegerType struct {
+	Bits     int
+	Unsigned bool
+}
+
+// IntegerTypes are sized integer types on top of what schemer supports natively.
+var IntegerTypes = map[string]IntegerType{
+	"int8":   {Bits: 8},
+	"int16":  {Bits: 16},
+	"int32":  {Bits: 32},
+	"int64":  {Bits: 64},
+	"uint8":  {Bits: 8, Unsigned: true},
+	"uint16": {Bits: 16, Unsigned: true},
+	"uint32": {Bits: 32, Unsigned: true},
+	"uint64": {Bits: 64, Unsigned: true},
+}
+
+// FieldSchema keeps everything declared for a field in SchemaConfig, including
+// types and properties which schemer doesn't know about.
+type FieldSchema struct {
+	Name    string
+	Type    string
+	Subtype *FieldSchema
+	Fields  map[string]*FieldSchema
+	Props   map[string]interface{}
+
+	// ValueType is type of every value of map with arbitrary keys, which takes place of Fields.
+	ValueType   *FieldSchema
+	valueSchema *schemer.Schema
+
+	// coercible is set if value of field or its children needs to be coerced after normalizing
+	coercible bool
+
+	// maxSize limits decoded size of bytes if limitSize is set
+	maxSize   int64
+	limitSize bool
+}
+
+func ParseFieldSchemas(config map[string]interface{}) (map[string]*FieldSchema, error) {
+
+	fields := make(map[string]*FieldSchema, len(config))
+
+	for name, v := range config {
+		fs, err := parseFieldSchema(name, v)
+		if err != nil {
+			return nil, err
+		}
+
+		fields[name] = fs
+	}
+
+	return fields, nil
+}
+
+func parseFieldSchema(name string, data interface{}) (*FieldSchema, error) {
+
+	def, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFieldDefinition, name)
+	}
+
+	t, ok := def["type"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFieldType, name)
+	}
+
+	fs := &FieldSchema{
+		Name:  name,
+		Type:  t,
+		Props: make(map[string]interface{}),
+	}
+
+	for key, value := range def {
+		switch key {
+		case "type":
+		case "subtype":
+
+			// Subtype can be a type name or a complete definition
+			var subDef interface{} = value
+			if st, ok := value.(string); ok {
+				subDef = map[string]interface{}{
+					"type":   st,
+					"fields": def["fields"],
+				}
+			}
+
+			subtype, err := parseFieldSchema(name, subDef)
+			if err != nil {
+				return nil, err
+			}
+
+			fs.Subtype = subtype
+
+		case "fields":
+
+			if value == nil {
+				continue
+			}
+
+			fieldsDef, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidFieldDefinition, name)
+			}
+
+			fields, err := ParseFieldSchemas(fieldsDef)
+			if err != nil {
+				return nil, err
+			}
+
+			fs.Fields = fields
+
+		case "valueType":
+
+			// Value type can be a type name or a complete definition
+			var valueDef interface{} = value
+			if vt, ok := value.(string); ok {
+				valueDef = map[string]interface{}{
+					"type": vt,
+				}
+			}
+
+			valueType, err := parseFieldSchema(name, valueDef)
+			if err != nil {
+				return nil, err
+			}
+
+			fs.ValueType = valueType
+
+		default:
+			fs.Props[key] = value
+		}
+	}
+
+	err := fs.parseDefault()
+	if err != nil {
+		return nil, err
+	}
+
+	err = fs.parseArrayTransforms()
+	if err != nil {
+		return nil, err
+	}
+
+	err = fs.parseMaxSize()
+	if err != nil {
+		return nil, err
+	}
+
+	if fs.ValueType != nil {
+		err := fs.prepareValueType()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fs.coercible = fs.isCoercible()
+
+	return fs, nil
+}
+
+// BaseType returns type name which is able to be recognized by schemer.
+func (fs *FieldSchema) BaseType() string {
+
+	if it, ok := IntegerTypes[fs.Type]; ok {
+
+		// Keep sign of value for range check
+		if it.Unsigned && it.Bits == 64 {
+			return "uint"
+		}
+
+		return "int"
+	}
+
+	switch fs.Type {
+	case "bytes":
+		return "binary"
+	case GeoPointType:
+		return "any"
+	}
+
+	return fs.Type
+}
+
+func (fs *FieldSchema) schemerConfig() map[string]interface{} {
+
+	config := make(map[string]interface{}, len(fs.Props)+3)
+	for k, v := range fs.Props {
+		config[k] = v
+	}
+
+	config["type"] = fs.BaseType()
+
+	// Keys of map are unknown to schemer, so values are normalized by value schema instead
+	if fs.ValueType != nil {
+		config["type"] = "any"
+		return config
+	}
+
+	if fs.Subtype != nil {
+		config["subtype"] = fs.Subtype.schemerConfig()
+	}
+
+	if fs.Fields != nil {
+		config["fields"] = buildSchemerConfig(fs.Fields)
+	}
+
+	return config
+}
+
+func buildSchemerConfig(fields map[string]*FieldSchema) map[string]interface{} {
+
+	config := make(map[string]interface{}, len(fields))
+	for name, fs := range fields {
+		config[name] = fs.schemerConfig()
+	}
+
+	return config
+}
+
+// ParseSchemaConfig parses SchemaConfig into field schemas and schemer schema.
+func ParseSchemaConfig(config map[string]interface{}) (map[string]*FieldSchema, *schemer.Schema, error) {
+
+	fields, err := ParseFieldSchemas(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	schema := schemer.NewSchema()
+	err = schemer.Unmarshal(buildSchemerConfig(fields), schema)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return fields, schema, nil
+}
+
+// LookupFieldSchema finds field schema by path, such as "nested.nested_id" or "tags.0".
+func LookupFieldSchema(fields map[string]*FieldSchema, path string) *FieldSchema {
+
+	// Plain field name doesn't need to be parsed
+	if !strings.ContainsAny(path, ".[") {
+		return fields[path]
+	}
+
+	var fs *FieldSchema
+	for _, token := range record_type.ParsePath(path) {
+
+		if fs != nil {
+			switch fs.Type {
+			case "array":
+
+				// Element of array
+				if _, err := strconv.Atoi(token.Value); err == nil {
+					fs = fs.Subtype
+					if fs == nil {
+						return nil
+					}
+
+					continue
+				}
+
+				return nil
+			case "map":
+				fields = fs.Fields
+			default:
+				return nil
+			}
+		}
+
+		v, ok := fields[token.Value]
+		if !ok {
+			return nil
+		}
+
+		fs = v
+	}
+
+	return fs
+}
+
+func (fs *FieldSchema) isCoercible() bool {
+
+	if _, ok := IntegerTypes[fs.Type]; ok {
+		return true
+	}
+
+	switch fs.Type {
+	case "bytes", GeoPointType:
+		return true
+	case "array":
+		return fs.hasArrayTransforms() || (fs.Subtype != nil && fs.Subtype.coercible)
+	case "map":
+		if fs.ValueType != nil {
+			return true
+		}
+
+		for _, f := range fs.Fields {
+			if f.coercible {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (fs *FieldSchema) coerce(path string, value interface{}, ts *transformState) (interface{}, error) {
+
+	if value == nil || !fs.coercible {
+		return value, nil
+	}
+
+	if it, ok := IntegerTypes[fs.Type]; ok {
+		return coerceInteger(path, fs.Type, it, value)
+	}
+
+	switch fs.Type {
+	case "bytes":
+		return fs.coerceBytes(path, value)
+	case GeoPointType:
+		return coerceGeoPoint(path, value)
+	case "array":
+
+		elements, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+
+		// Elements are coerced before they are deduped and sorted
+		if fs.Subtype != nil && fs.Subtype.coercible {
+			for i, ele := range elements {
+
+				err := ts.interrupted()
+				if err != nil {
+					return nil, err
+				}
+
+				v, err := fs.Subtype.coerce(path+"."+strconv.Itoa(i), ele, ts)
+				if err != nil {
+					if ts.collect(err) {
+						continue
+					}
+
+					return nil, err
+				}
+
+				elements[i] = v
+			}
+		}
+
+		return fs.transformArray(elements), nil
+
+	case "map":
+
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+
+		if fs.ValueType != nil {
+			return value, fs.coerceValues(path, m, ts)
+		}
+
+		err := coerceFields(fs.Fields, path+".", m, ts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return value, nil
+}
+
+func coerceFields(fields map[string]*FieldSchema, prefix string, data map[string]interface{}, ts *transformState) error {
+
+	for k, v := range data {
+
+		// Skip internal fields
+		if strings.HasPrefix(k, "$") {
+			continue
+		}
+
+		err := ts.interrupted()
+		if err != nil {
+			return err
+		}
+
+		fs := LookupFieldSchema(fields, k)
+		if fs == nil || !fs.coercible {
+			continue
+		}
+
+		val, err := fs.coerce(prefix+k, v, ts)
+		if err != nil {
+			if ts.collect(err) {
+				continue
+			}
+
+			return err
+		}
+
+		data[k] = val
+	}
+
+	return nil
+}
+
+func coerceInteger(path string, typeName string, it IntegerType, value interface{}) (interface{}, error) {
+
+	switch v := value.(type) {
+	case int64:
+
+		if it.Unsigned {
+			if v < 0 || (it.Bits < 64 && uint64(v) > uint64(1)<<it.Bits-1) {
+				return nil, &CoercionError{Field: path, From: "int64", To: typeName, Value: v}
+			}
+
+			return convertUnsigned(it.Bits, uint64(v)), nil
+		}
+
+		if it.Bits < 64 {
+			max := int64(1)<<(it.Bits-1) - 1
+			min := -max - 1
+			if v < min || v > max {
+				return nil, &CoercionError{Field: path, From: "int64", To: typeName, Value: v}
+			}
+		}
+
+		return convertSigned(it.Bits, v), nil
+
+	case uint64:
+
+		if it.Unsigned {
+			if it.Bits < 64 && v > uint64(1)<<it.Bits-1 {
+				return nil, &CoercionError{Field: path, From: "uint64", To: typeName, Value: v}
+			}
+
+			return convertUnsigned(it.Bits, v), nil
+		}
+
+		if v > uint64(1)<<(it.Bits-1)-1 {
+			return nil, &CoercionError{Field: path, From: "uint64", To: typeName, Value: v}
+		}
+
+		return convertSigned(it.Bits, int64(v)), nil
+	}
+
+	return value, nil
+}
+
+func convertSigned(bits int, v int64) interface{} {
+
+	switch bits {
+	case 8:
+		return int8(v)
+	case 16:
+		return int16(v)
+	case 32:
+		return int32(v)
+	}
+
+	return v
+}
+
+func convertUnsigned(bits int, v uint64) interface{} {
+
+	switch bits {
+	case 8:
+		return uint8(v)
+	case 16:
+		return uint16(v)
+	case 32:
+		return uint32(v)
+	}
+
+	return v
+}
+
+// parseMaxSize validates "maxSize" property of bytes, which is a non-negative integer of any numeric type.
+func (fs *FieldSchema) parseMaxSize() error {
+
+	v, ok := fs.Props["maxSize"]
+	if !ok {
+		return nil
+	}
+
+	size, ok := parseSize(v)
+	if !ok {
+		return fmt.Errorf("%w: %s: maxSize should be a non-negative integer", ErrInvalidFieldDefinition, fs.Name)
+	}
+
+	fs.maxSize = size
+	fs.limitSize = true
+
+	return nil
+}
+
+func parseSize(v interface{}) (int64, bool) {
+
+	var size int64
+	switch n := v.(type) {
+	case int:
+		size = int64(n)
+	case float64:
+		if n != math.Trunc(n) || n > math.MaxInt64 {
+			return 0, false
+		}
+
+		size = int64(n)
+	case float32:
+		return parseSize(float64(n))
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			return 0, false
+		}
+
+		size = i
+	case uint:
+		return parseSize(uint64(n))
+	default:
+		i, ok := toInt64(v)
+		if !ok {
+			return 0, false
+		}
+
+		size = i
+	}
+
+	return size, size >= 0
+}
+
+func (fs *FieldSchema) coerceBytes(path string, value interface{}) (interface{}, error) {
+
+	var encoded []byte
+	switch v := value.(type) {
+	case string:
+		encoded = []byte(v)
+	case []byte:
+		encoded = v
+	default:
+		return nil, &CoercionError{Field: path, From: fmt.Sprintf("%T", value), To: fs.Type}
+	}
+
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+	n, err := base64.StdEncoding.Decode(data, encoded)
+	if err != nil {
+		return nil, &CoercionError{Field: path, From: "string", To: fs.Type, Value: string(encoded)}
+	}
+
+	data = data[:n]
+
+	if fs.limitSize && int64(len(data)) > fs.maxSize {
+		return nil, &SchemaValidationError{
+			Field:  path,
+			Reason: fmt.Sprintf("size %d exceeds maxSize %d", len(data), fs.maxSize),
+		}
+	}
+
+	return converter.Binary(data), nil
+}
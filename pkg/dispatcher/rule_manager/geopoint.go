@@ -0,0 +1,70 @@
+package rule_manager
+
+import "fmt"
+
+// GeoPointType is type of location, which accepts {"lat": .., "lng": ..} or [lng, lat] and is normalized
+// into {"lat": .., "lng": ..} of floats.
+const GeoPointType = "geopoint"
+
+func coerceGeoPoint(path string, value interface{}) (interface{}, error) {
+
+	var lat, lng interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		lat, lng = v["lat"], v["lng"]
+	case []interface{}:
+		if len(v) != 2 {
+			return nil, &CoercionError{Field: path, From: "array", To: GeoPointType, Value: value}
+		}
+
+		// Longitude comes first as GeoJSON does
+		lng, lat = v[0], v[1]
+	default:
+		return nil, &CoercionError{Field: path, From: typeNameOf(value), To: GeoPointType, Value: value}
+	}
+
+	latitude, ok := toCoordinate(lat)
+	if !ok {
+		return nil, &CoercionError{Field: path + ".lat", From: typeNameOf(lat), To: "float", Value: lat}
+	}
+
+	longitude, ok := toCoordinate(lng)
+	if !ok {
+		return nil, &CoercionError{Field: path + ".lng", From: typeNameOf(lng), To: "float", Value: lng}
+	}
+
+	if latitude < -90 || latitude > 90 {
+		return nil, &SchemaValidationError{
+			Field:  path,
+			Reason: fmt.Sprintf("latitude %v is out of range [-90, 90]", latitude),
+		}
+	}
+
+	if longitude < -180 || longitude > 180 {
+		return nil, &SchemaValidationError{
+			Field:  path,
+			Reason: fmt.Sprintf("longitude %v is out of range [-180, 180]", longitude),
+		}
+	}
+
+	return map[string]interface{}{
+		"lat": latitude,
+		"lng": longitude,
+	}, nil
+}
+
+func toCoordinate(v interface{}) (float64, bool) {
+
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+
+	i, ok := toInt64(v)
+
+	return float64(i), ok
+}
@@ -0,0 +1,189 @@
+package rule_manager
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Constraints which are carried over to JSON Schema with the same names
+var jsonSchemaKeywords = []string{
+	"description",
+	"enum",
+	"format",
+	"pattern",
+	"minimum",
+	"maximum",
+	"exclusiveMinimum",
+	"exclusiveMaximum",
+	"minLength",
+	"maxLength",
+	"minItems",
+	"maxItems",
+}
+
+// ToJSONSchema exports schema of rule as JSON Schema document. Primary keys and fields
+// with "required" property are listed as required, and nested primary keys make their parents required as well.
+func (r *Rule) ToJSONSchema() ([]byte, error) {
+
+	fields := r.Fields
+	if fields == nil {
+		var err error
+		fields, err = ParseFieldSchemas(r.SchemaConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	doc := objectJSONSchema(fields, r.PrimaryKey)
+	doc["$schema"] = jsonSchemaDraft
+
+	if len(r.Product) > 0 {
+		doc["title"] = r.Product
+	}
+
+	return json.Marshal(doc)
+}
+
+func objectJSONSchema(fields map[string]*FieldSchema, requiredFields []string) map[string]interface{} {
+
+	// Paths of nested fields are required by their parents
+	nestedRequired := make(map[string][]string)
+	topRequired := make([]string, 0, len(requiredFields))
+	for _, path := range requiredFields {
+		name, rest, nested := strings.Cut(path, ".")
+		topRequired = append(topRequired, name)
+
+		if nested {
+			nestedRequired[name] = append(nestedRequired[name], rest)
+		}
+	}
+
+	properties := make(map[string]interface{}, len(fields))
+	required := make([]string, 0)
+
+	for name, fs := range fields {
+		properties[name] = fs.jsonSchema(nestedRequired[name])
+
+		if v, ok := fs.Props["required"].(bool); ok && v {
+			required = append(required, name)
+		}
+	}
+
+	for _, name := range topRequired {
+		if _, ok := fields[name]; ok && !containsString(required, name) {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+func (fs *FieldSchema) jsonSchema(requiredFields []string) map[string]interface{} {
+
+	schema := make(map[string]interface{})
+
+	if it, ok := IntegerTypes[fs.Type]; ok {
+		schema["type"] = "integer"
+
+		if it.Unsigned {
+			schema["minimum"] = 0
+			if it.Bits < 64 {
+				schema["maximum"] = uint64(1)<<it.Bits - 1
+			} else {
+				schema["maximum"] = uint64(math.MaxUint64)
+			}
+		} else {
+			schema["minimum"] = -(int64(1) << (it.Bits - 1))
+			schema["maximum"] = int64(1)<<(it.Bits-1) - 1
+		}
+	}
+
+	switch fs.Type {
+	case "string":
+		schema["type"] = "string"
+	case "int":
+		schema["type"] = "integer"
+	case "uint":
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+	case "float":
+		schema["type"] = "number"
+	case "bool":
+		schema["type"] = "boolean"
+	case "time":
+		schema["type"] = "string"
+		schema["format"] = "date-time"
+	case "binary", "bytes":
+		schema["type"] = "string"
+		schema["contentEncoding"] = "base64"
+	case GeoPointType:
+		schema["type"] = "object"
+		schema["properties"] = map[string]interface{}{
+			"lat": map[string]interface{}{"type": "number", "minimum": -90, "maximum": 90},
+			"lng": map[string]interface{}{"type": "number", "minimum": -180, "maximum": 180},
+		}
+		schema["required"] = []string{"lat", "lng"}
+	case "map":
+		if fs.ValueType != nil {
+			schema["type"] = "object"
+			schema["additionalProperties"] = fs.ValueType.jsonSchema(nil)
+			break
+		}
+
+		for k, v := range objectJSONSchema(fs.Fields, requiredFields) {
+			schema[k] = v
+		}
+	case "array":
+		schema["type"] = "array"
+		if fs.Subtype != nil {
+			schema["items"] = fs.Subtype.jsonSchema(nil)
+		}
+	}
+
+	// Aliases for range
+	if v, ok := fs.Props["min"]; ok {
+		schema["minimum"] = v
+	}
+
+	if v, ok := fs.Props["max"]; ok {
+		schema["maximum"] = v
+	}
+
+	for _, keyword := range jsonSchemaKeywords {
+		if v, ok := fs.Props[keyword]; ok {
+			schema[keyword] = v
+		}
+	}
+
+	// Only defaults which apply to all events
+	if v, ok := fs.Props["default"]; ok && !fs.hasEventDefaults() {
+		schema["default"] = v
+	}
+
+	return schema
+}
+
+func containsString(list []string, s string) bool {
+
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
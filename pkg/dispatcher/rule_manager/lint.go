@@ -0,0 +1,184 @@
+package rule_manager
+
+import (
+	"fmt"
+	"sort"
+	"unicode"
+)
+
+type LintSeverity string
+
+const (
+	LintSeverityInfo    LintSeverity = "info"
+	LintSeverityWarning LintSeverity = "warning"
+	LintSeverityError   LintSeverity = "error"
+)
+
+// LintWarning is advisory about schema of rule, which never stops rule from working.
+type LintWarning struct {
+	Field    string
+	Severity LintSeverity
+	Message  string
+}
+
+func (w LintWarning) String() string {
+
+	if len(w.Field) == 0 {
+		return fmt.Sprintf("%s: %s", w.Severity, w.Message)
+	}
+
+	return fmt.Sprintf("%s: field \"%s\": %s", w.Severity, w.Field, w.Message)
+}
+
+// Constraints which limit range of integers
+var integerConstraints = []string{
+	"min",
+	"max",
+	"minimum",
+	"maximum",
+	"exclusiveMinimum",
+	"exclusiveMaximum",
+	"enum",
+}
+
+// Lint looks for common mistakes in schema of rule which are still valid, such as time kept in string field,
+// integer primary key without range and array without subtype. Warnings are sorted by field.
+func Lint(rule *Rule) []LintWarning {
+
+	fields := rule.Fields
+	if fields == nil {
+		var err error
+		fields, err = ParseFieldSchemas(rule.SchemaConfig)
+		if err != nil {
+			return []LintWarning{
+				{Severity: LintSeverityError, Message: fmt.Sprintf("invalid schema: %v", err)},
+			}
+		}
+	}
+
+	warnings := lintFields(fields, "", nil)
+
+	for _, key := range rule.PrimaryKey {
+
+		fs := LookupFieldSchema(fields, key)
+		if fs == nil {
+			continue
+		}
+
+		switch fs.BaseType() {
+		case "int", "uint":
+			if !hasAnyProp(fs, integerConstraints) {
+				warnings = append(warnings, LintWarning{
+					Field:    key,
+					Severity: LintSeverityInfo,
+					Message:  "integer primary key has no range constraint",
+				})
+			}
+		case "float":
+			warnings = append(warnings, LintWarning{
+				Field:    key,
+				Severity: LintSeverityWarning,
+				Message:  "float primary key is not able to be compared exactly",
+			})
+		}
+	}
+
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Field < warnings[j].Field
+	})
+
+	return warnings
+}
+
+func lintFields(fields map[string]*FieldSchema, prefix string, warnings []LintWarning) []LintWarning {
+
+	for name, fs := range fields {
+
+		path := prefix + name
+
+		switch fs.Type {
+		case "string":
+			if looksLikeTime(name) {
+				warnings = append(warnings, LintWarning{
+					Field:    path,
+					Severity: LintSeverityWarning,
+					Message:  "field is named like time but declared as string, consider \"time\"",
+				})
+			}
+		case "array":
+			if fs.Subtype == nil {
+				warnings = append(warnings, LintWarning{
+					Field:    path,
+					Severity: LintSeverityWarning,
+					Message:  "array has no subtype, so elements are never checked",
+				})
+			} else if fs.Subtype.Type == "map" {
+				warnings = lintFields(fs.Subtype.Fields, path+".", warnings)
+			}
+		case "map":
+			warnings = lintFields(fs.Fields, path+".", warnings)
+		}
+	}
+
+	return warnings
+}
+
+// looksLikeTime tells names which end with word of time, such as "createdAt", "created_at", "birthDate" and
+// "timestamp", while "candidate" is not one of them.
+func looksLikeTime(name string) bool {
+
+	words := splitWords(name)
+	if len(words) == 0 {
+		return false
+	}
+
+	switch words[len(words)-1] {
+	case "date", "time", "timestamp", "datetime":
+		return true
+	case "at":
+		return len(words) > 1
+	}
+
+	return false
+}
+
+// splitWords splits name in camel case or snake case into lower case words.
+func splitWords(name string) []string {
+
+	words := make([]string, 0)
+	var word []rune
+	for _, r := range name {
+
+		switch {
+		case r == '_' || r == '-':
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+
+			word = nil
+			continue
+		case unicode.IsUpper(r) && len(word) > 0:
+			words = append(words, string(word))
+			word = nil
+		}
+
+		word = append(word, unicode.ToLower(r))
+	}
+
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+
+	return words
+}
+
+func hasAnyProp(fs *FieldSchema, props []string) bool {
+
+	for _, prop := range props {
+		if _, ok := fs.Props[prop]; ok {
+			return true
+		}
+	}
+
+	return false
+}
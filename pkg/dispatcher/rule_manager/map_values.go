@@ -0,0 +1,81 @@
+package rule_manager
+
+import (
+	"fmt"
+
+	"github.com/BrobridgeOrg/schemer"
+)
+
+func (fs *FieldSchema) prepareValueType() error {
+
+	if fs.Type != "map" || fs.Fields != nil {
+		return fmt.Errorf("%w: %s: valueType is only for map without fields", ErrInvalidFieldDefinition, fs.Name)
+	}
+
+	fs.valueSchema = schemer.NewSchema()
+	err := schemer.Unmarshal(map[string]interface{}{
+		"value": fs.ValueType.schemerConfig(),
+	}, fs.valueSchema)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidFieldType, fs.Name, err)
+	}
+
+	return nil
+}
+
+// coerceValues normalizes every value of map with value type, which is skipped by schemer.
+func (fs *FieldSchema) coerceValues(path string, data map[string]interface{}, ts *transformState) error {
+
+	for k, v := range data {
+
+		err := ts.interrupted()
+		if err != nil {
+			return err
+		}
+
+		if v == nil {
+			continue
+		}
+
+		err = ts.checkString(fs.ValueType, path+"."+k, v)
+		if err != nil {
+			if ts.collect(err) {
+				continue
+			}
+
+			return err
+		}
+
+		if !fs.ValueType.checkElement(v) {
+			err := &CoercionError{
+				Field: path + "." + k,
+				From:  typeNameOf(v),
+				To:    fs.ValueType.Type,
+				Value: v,
+			}
+
+			if ts.collect(err) {
+				continue
+			}
+
+			return err
+		}
+
+		normalized := fs.valueSchema.Normalize(map[string]interface{}{
+			"value": v,
+		})
+
+		val, err := fs.ValueType.coerce(path+"."+k, normalized["value"], ts)
+		if err != nil {
+			if ts.collect(err) {
+				continue
+			}
+
+			return err
+		}
+
+		data[k] = val
+	}
+
+	return nil
+}
@@ -0,0 +1,403 @@
+package rule_manager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+const (
+	DefaultMaskChar = "*"
+
+	// DefaultMaskConditionTimeout limits time of evaluating condition of mask for each record.
+	DefaultMaskConditionTimeout = 100 * time.Millisecond
+)
+
+var (
+	ErrInvalidMask          = errors.New("invalid mask")
+	ErrMaskConditionTimeout = errors.New("mask condition timed out")
+)
+
+// freezeGlobals makes built-in objects and global scope of runtime immutable, so that conditions never leave
+// anything for later evaluations on the same runtime.
+const freezeGlobals = `(function() {
+	"use strict";
+	Object.getOwnPropertyNames(globalThis).forEach(function(name) {
+		var v = globalThis[name];
+		if (v !== null && (typeof v === "object" || typeof v === "function")) {
+			Object.freeze(v);
+			if (v.prototype) {
+				Object.freeze(v.prototype);
+			}
+		}
+	});
+	Object.freeze(globalThis);
+})()`
+
+// maskRuntime evaluates conditions, which are compiled to functions once for each runtime.
+type maskRuntime struct {
+	vm         *goja.Runtime
+	conditions map[*goja.Program]goja.Callable
+}
+
+var maskRuntimePool = sync.Pool{
+	New: func() interface{} {
+
+		rt := &maskRuntime{
+			vm:         goja.New(),
+			conditions: make(map[*goja.Program]goja.Callable),
+		}
+
+		_, err := rt.vm.RunString(freezeGlobals)
+		if err != nil {
+			panic(err)
+		}
+
+		return rt
+	},
+}
+
+// fieldMask hides value of string field, except for the last characters which are kept. Masking takes
+// place only if condition is true for the record, or always without condition.
+type fieldMask struct {
+	path      string
+	keep      int
+	char      string
+	when      string
+	condition *goja.Program
+}
+
+func (r *Rule) prepareMasks() error {
+
+	masks, err := collectMasks(r.Fields, "", nil)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(masks, func(i, j int) bool {
+		return masks[i].path < masks[j].path
+	})
+
+	r.masks = masks
+
+	return nil
+}
+
+// collectMasks parses "mask" of fields, which is true or options such as:
+//
+//	{ "keep": 4, "char": "#", "when": "source.country == 'US'" }
+//
+// Condition is JavaScript expression in strict mode, and a copy of record is available as source like scripts of
+// handler. Globals are read-only.
+func collectMasks(fields map[string]*FieldSchema, prefix string, masks []*fieldMask) ([]*fieldMask, error) {
+
+	for name, fs := range fields {
+
+		path := prefix + name
+
+		if fs.Type == "map" {
+			var err error
+			masks, err = collectMasks(fs.Fields, path+".", masks)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		config, ok := fs.Props["mask"]
+		if !ok {
+			continue
+		}
+
+		m, err := parseMask(path, fs, config)
+		if err != nil {
+			return nil, err
+		}
+
+		if m != nil {
+			masks = append(masks, m)
+		}
+	}
+
+	return masks, nil
+}
+
+func parseMask(path string, fs *FieldSchema, config interface{}) (*fieldMask, error) {
+
+	m := &fieldMask{
+		path: path,
+		char: DefaultMaskChar,
+	}
+
+	switch c := config.(type) {
+	case bool:
+		if !c {
+			return nil, nil
+		}
+	case map[string]interface{}:
+
+		if keep, ok := c["keep"]; ok {
+			n, ok := keep.(float64)
+			if !ok || n < 0 || n != float64(int(n)) {
+				return nil, fmt.Errorf("%w: field \"%s\": keep must be a non-negative integer", ErrInvalidMask, path)
+			}
+
+			m.keep = int(n)
+		}
+
+		if char, ok := c["char"]; ok {
+			s, ok := char.(string)
+			if !ok || len([]rune(s)) != 1 {
+				return nil, fmt.Errorf("%w: field \"%s\": char must be a single character", ErrInvalidMask, path)
+			}
+
+			m.char = s
+		}
+
+		if when, ok := c["when"]; ok {
+			s, ok := when.(string)
+			if !ok || len(strings.TrimSpace(s)) == 0 {
+				return nil, fmt.Errorf("%w: field \"%s\": condition must be an expression", ErrInvalidMask, path)
+			}
+
+			prog, err := goja.Compile(path, "(function(source) { \"use strict\"; return ("+s+"); })", true)
+			if err != nil {
+				return nil, fmt.Errorf("%w: field \"%s\": %v", ErrInvalidMask, path, err)
+			}
+
+			m.when = s
+			m.condition = prog
+		}
+	default:
+		return nil, fmt.Errorf("%w: field \"%s\": unexpected %T", ErrInvalidMask, path, config)
+	}
+
+	if fs.Type != "string" {
+		return nil, fmt.Errorf("%w: field \"%s\" is %s, but only string is supported", ErrInvalidMask, path, fs.Type)
+	}
+
+	return m, nil
+}
+
+func (r *Rule) applyMasks(results []map[string]interface{}) error {
+
+	if len(r.masks) == 0 {
+		return nil
+	}
+
+	for _, result := range results {
+		for _, m := range r.masks {
+			err := m.apply(result)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *fieldMask) apply(data map[string]interface{}) error {
+
+	parent, name := lookupParent(data, m.path)
+	if parent == nil {
+		return nil
+	}
+
+	s, ok := parent[name].(string)
+	if !ok {
+		return nil
+	}
+
+	if m.condition != nil {
+		matched, err := m.match(data)
+		if errors.Is(err, ErrMaskConditionTimeout) {
+			return fmt.Errorf("%w: field \"%s\" exceeded %s", ErrMaskConditionTimeout, m.path, DefaultMaskConditionTimeout)
+		} else if err != nil {
+			return fmt.Errorf("%w: field \"%s\": %v", ErrInvalidMask, m.path, err)
+		}
+
+		if !matched {
+			return nil
+		}
+	}
+
+	parent[name] = m.mask(s)
+
+	return nil
+}
+
+// match evaluates condition with a copy of record, so condition is able to change nothing but the copy. It's
+// interrupted once DefaultMaskConditionTimeout is exceeded.
+func (m *fieldMask) match(data map[string]interface{}) (bool, error) {
+
+	rt := maskRuntimePool.Get().(*maskRuntime)
+
+	fn, ok := rt.conditions[m.condition]
+	if !ok {
+		v, err := rt.vm.RunProgram(m.condition)
+		if err != nil {
+			maskRuntimePool.Put(rt)
+			return false, err
+		}
+
+		fn, ok = goja.AssertFunction(v)
+		if !ok {
+			maskRuntimePool.Put(rt)
+			return false, errors.New("condition is not an expression")
+		}
+
+		rt.conditions[m.condition] = fn
+	}
+
+	timer := time.AfterFunc(DefaultMaskConditionTimeout, func() {
+		rt.vm.Interrupt(ErrMaskConditionTimeout)
+	})
+
+	v, err := fn(goja.Undefined(), rt.vm.ToValue(cloneValue(data)))
+
+	// Runtime might be interrupted anytime if timer has fired, so it's never reused
+	if timer.Stop() {
+		maskRuntimePool.Put(rt)
+	}
+
+	if err != nil {
+		var interrupted *goja.InterruptedError
+		if errors.As(err, &interrupted) {
+			return false, ErrMaskConditionTimeout
+		}
+
+		return false, err
+	}
+
+	return v.ToBoolean(), nil
+}
+
+func (m *fieldMask) mask(s string) string {
+
+	chars := []rune(s)
+	if len(chars) <= m.keep {
+		return s
+	}
+
+	return strings.Repeat(m.char, len(chars)-m.keep) + string(chars[len(chars)-m.keep:])
+}
+
+// HasMasks tells whether any field of rule is masked.
+func (r *Rule) HasMasks() bool {
+	return len(r.masks) > 0
+}
+
+// MaskRaw masks fields of data which was decoded from raw payload, so that raw payload never reveals values which
+// are masked in records. Raw payload is neither normalized nor transformed, so names are matched regardless of
+// case and conditions are ignored, which means masked fields are always masked.
+func (r *Rule) MaskRaw(data map[string]interface{}) {
+	for _, m := range r.masks {
+		m.maskRaw(data, strings.Split(m.path, "."))
+	}
+}
+
+// maskRaw looks for every occurrence of masked field in raw value. Keys are able to be dotted paths as partial
+// updates have, and arrays and internal fields such as "$append" are walked through.
+func (m *fieldMask) maskRaw(v interface{}, path []string) {
+
+	switch d := v.(type) {
+	case []interface{}:
+		for _, ele := range d {
+			m.maskRaw(ele, path)
+		}
+	case map[string]interface{}:
+		for key, value := range d {
+
+			if strings.HasPrefix(key, "$") {
+				m.maskRaw(value, path)
+				continue
+			}
+
+			rest, ok := matchRawKey(key, path)
+			if !ok {
+				continue
+			}
+
+			if len(rest) == 0 {
+				d[key] = m.maskRawValue(value)
+				continue
+			}
+
+			m.maskRaw(value, rest)
+		}
+	}
+}
+
+// matchRawKey matches key such as "nested", "nested.nested_id" or "items[0].name" with the beginning of path, and
+// returns the rest of path. Indexes of arrays are skipped.
+func matchRawKey(key string, path []string) ([]string, bool) {
+
+	tokens := strings.FieldsFunc(key, func(r rune) bool {
+		return r == '.' || r == '[' || r == ']'
+	})
+
+	for _, token := range tokens {
+
+		if _, err := strconv.Atoi(token); err == nil {
+			continue
+		}
+
+		if len(path) == 0 || !strings.EqualFold(token, path[0]) {
+			return nil, false
+		}
+
+		path = path[1:]
+	}
+
+	return path, true
+}
+
+// maskRawValue masks value even if it's not a string, since it was never coerced.
+func (m *fieldMask) maskRawValue(v interface{}) interface{} {
+
+	switch d := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return m.mask(d)
+	case []interface{}:
+		for i, ele := range d {
+			d[i] = m.maskRawValue(ele)
+		}
+
+		return d
+	case map[string]interface{}:
+		for k, value := range d {
+			d[k] = m.maskRawValue(value)
+		}
+
+		return d
+	}
+
+	return m.mask(fmt.Sprint(v))
+}
+
+// lookupParent returns map which contains the last field of dotted path.
+func lookupParent(data map[string]interface{}, path string) (map[string]interface{}, string) {
+
+	for {
+		name, rest, nested := strings.Cut(path, ".")
+		if !nested {
+			return data, name
+		}
+
+		v, ok := data[name].(map[string]interface{})
+		if !ok {
+			return nil, ""
+		}
+
+		data = v
+		path = rest
+	}
+}
@@ -0,0 +1,94 @@
+package rule_manager
+
+import (
+	"sort"
+)
+
+// MatchMode decides which of rules for the same event apply to a message.
+type MatchMode int
+
+const (
+	// FirstMatch applies the rule with the highest priority only.
+	FirstMatch MatchMode = iota
+
+	// AllMatch applies every rule of event in order of priority.
+	AllMatch
+)
+
+// WithMatchMode sets default match mode for events, which is FirstMatch by default.
+func WithMatchMode(mode MatchMode) func(*RuleManager) {
+	return func(rm *RuleManager) {
+		rm.matchMode = mode
+	}
+}
+
+// SetEventMatchMode overrides match mode for specific event.
+func (rm *RuleManager) SetEventMatchMode(eventName string, mode MatchMode) {
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	if rm.eventMatchModes == nil {
+		rm.eventMatchModes = make(map[string]MatchMode)
+	}
+
+	rm.eventMatchModes[eventName] = mode
+}
+
+// Match returns every rule of event in order of priority regardless of match mode, such as for previewing
+// routing of events.
+func (rm *RuleManager) Match(eventName string) []*Rule {
+
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	return rm.match(eventName)
+}
+
+// MatchRules returns rules which apply to event according to match mode, in order of priority.
+func (rm *RuleManager) MatchRules(eventName string) []*Rule {
+
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	rules := rm.match(eventName)
+	if len(rules) > 1 && rm.eventMatchMode(eventName) == FirstMatch {
+		return rules[:1]
+	}
+
+	return rules
+}
+
+func (rm *RuleManager) match(eventName string) []*Rule {
+
+	ruleSet := rm.events.GetRuleSet(eventName)
+	if ruleSet == nil {
+		return make([]*Rule, 0)
+	}
+
+	return sortRulesByPriority(ruleSet.List())
+}
+
+func (rm *RuleManager) eventMatchMode(eventName string) MatchMode {
+
+	if mode, ok := rm.eventMatchModes[eventName]; ok {
+		return mode
+	}
+
+	return rm.matchMode
+}
+
+// sortRulesByPriority sorts rules by priority in descending order, and rules of the same priority by ID
+// so that order is stable.
+func sortRulesByPriority(rules []*Rule) []*Rule {
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority > rules[j].Priority
+		}
+
+		return rules[i].ID < rules[j].ID
+	})
+
+	return rules
+}
@@ -0,0 +1,119 @@
+package rule_manager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PrimaryKeyStrategyUUID = "uuid"
+	PrimaryKeyStrategyHash = "hash"
+)
+
+var ErrInvalidPrimaryKeyStrategy = errors.New("invalid primary key strategy")
+
+func (r *Rule) preparePrimaryKeyStrategy() error {
+
+	switch r.PrimaryKeyStrategy {
+	case "":
+		return nil
+	case PrimaryKeyStrategyUUID, PrimaryKeyStrategyHash:
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidPrimaryKeyStrategy, r.PrimaryKeyStrategy)
+	}
+
+	if len(r.PrimaryKeyField) == 0 {
+		if len(r.PrimaryKey) != 1 {
+			return fmt.Errorf("%w: field to store generated key is required", ErrInvalidPrimaryKeyStrategy)
+		}
+
+		r.PrimaryKeyField = r.PrimaryKey[0]
+	}
+
+	// Generated key is written to result directly
+	if strings.ContainsAny(r.PrimaryKeyField, ".[") {
+		return fmt.Errorf("%w: generated key should be stored in top-level field", ErrInvalidPrimaryKeyStrategy)
+	}
+
+	if len(r.PrimaryKey) == 0 {
+		r.PrimaryKey = []string{
+			r.PrimaryKeyField,
+		}
+	} else if !containsString(r.PrimaryKey, r.PrimaryKeyField) {
+		return fmt.Errorf("%w: field \"%s\" is not a primary key", ErrInvalidPrimaryKeyStrategy, r.PrimaryKeyField)
+	}
+
+	if len(r.Fields) > 0 {
+		fs := LookupFieldSchema(r.Fields, r.PrimaryKeyField)
+		if fs == nil || fs.Type != "string" {
+			return fmt.Errorf("%w: field \"%s\" should be defined as string", ErrInvalidPrimaryKeyStrategy, r.PrimaryKeyField)
+		}
+	}
+
+	return nil
+}
+
+// generatedKeyField returns field which key is generated for, or empty string if key is never generated.
+func (r *Rule) generatedKeyField() string {
+
+	if len(r.PrimaryKeyStrategy) == 0 {
+		return ""
+	}
+
+	if len(r.PrimaryKeyField) == 0 && len(r.PrimaryKey) == 1 {
+		return r.PrimaryKey[0]
+	}
+
+	return r.PrimaryKeyField
+}
+
+// hashPayload calculates key with entire payload, so identical payloads always get the same key.
+func hashPayload(data map[string]interface{}) (string, error) {
+
+	// Keys of map are sorted by encoder
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(raw)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// generatePrimaryKey fills key field of results which come without it.
+func (r *Rule) generatePrimaryKey(data map[string]interface{}, results []map[string]interface{}) error {
+
+	var hash string
+	for _, result := range results {
+
+		if v, ok := result[r.PrimaryKeyField]; ok && v != nil {
+			continue
+		}
+
+		switch r.PrimaryKeyStrategy {
+		case PrimaryKeyStrategyUUID:
+			result[r.PrimaryKeyField] = uuid.NewString()
+		case PrimaryKeyStrategyHash:
+
+			if len(hash) == 0 {
+				h, err := hashPayload(data)
+				if err != nil {
+					return err
+				}
+
+				hash = h
+			}
+
+			result[r.PrimaryKeyField] = hash
+		}
+	}
+
+	return nil
+}
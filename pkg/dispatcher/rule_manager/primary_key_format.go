@@ -0,0 +1,103 @@
+package rule_manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+)
+
+const (
+	PrimaryKeyEscapingBackslash = "backslash"
+	PrimaryKeyEscapingPercent   = "percent"
+)
+
+var ErrInvalidPrimaryKeyFormat = errors.New("invalid primary key format")
+
+func (r *Rule) preparePrimaryKeyFormat() error {
+
+	if len(r.PrimaryKeySeparator) == 0 {
+		if len(r.PrimaryKeyEscaping) > 0 {
+			return fmt.Errorf("%w: escaping requires separator", ErrInvalidPrimaryKeyFormat)
+		}
+
+		return nil
+	}
+
+	switch r.PrimaryKeyEscaping {
+	case "":
+		r.PrimaryKeyEscaping = PrimaryKeyEscapingBackslash
+		fallthrough
+	case PrimaryKeyEscapingBackslash:
+		if strings.Contains(r.PrimaryKeySeparator, "\\") {
+			return fmt.Errorf("%w: separator \"%s\" conflicts with escaping", ErrInvalidPrimaryKeyFormat, r.PrimaryKeySeparator)
+		}
+	case PrimaryKeyEscapingPercent:
+		if strings.Contains(r.PrimaryKeySeparator, "%") {
+			return fmt.Errorf("%w: separator \"%s\" conflicts with escaping", ErrInvalidPrimaryKeyFormat, r.PrimaryKeySeparator)
+		}
+	default:
+		return fmt.Errorf("%w: unknown escaping %s", ErrInvalidPrimaryKeyFormat, r.PrimaryKeyEscaping)
+	}
+
+	return nil
+}
+
+// CalculateKey serializes primary key of record. Values are joined by "_" as they are unless separator was set,
+// in which case separator and escape characters in values are escaped, so that keys never collide.
+func (r *Rule) CalculateKey(record *record_type.Record) ([]byte, error) {
+
+	if len(r.PrimaryKeySeparator) == 0 {
+		return record.CalculateKey(r.PrimaryKey)
+	}
+
+	sep := []byte(r.PrimaryKeySeparator)
+
+	var buf bytes.Buffer
+	for i, path := range r.PrimaryKey {
+
+		v, err := record.GetValueByPath(path)
+		if err != nil {
+			return nil, record_type.ErrNotFoundKeyPath
+		}
+
+		data, _ := v.GetBytes()
+
+		if i > 0 {
+			buf.Write(sep)
+		}
+
+		r.escapeKey(&buf, data, sep)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// escapeKey escapes every character of separator rather than separator as a whole, otherwise values next to
+// separators would be ambiguous for separators like "::".
+func (r *Rule) escapeKey(buf *bytes.Buffer, data []byte, sep []byte) {
+
+	for _, c := range data {
+		switch {
+		case bytes.IndexByte(sep, c) >= 0,
+			r.PrimaryKeyEscaping == PrimaryKeyEscapingBackslash && c == '\\',
+			r.PrimaryKeyEscaping == PrimaryKeyEscapingPercent && c == '%':
+			r.escapeByte(buf, c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+func (r *Rule) escapeByte(buf *bytes.Buffer, c byte) {
+
+	if r.PrimaryKeyEscaping == PrimaryKeyEscapingPercent {
+		fmt.Fprintf(buf, "%%%02X", c)
+		return
+	}
+
+	buf.WriteByte('\\')
+	buf.WriteByte(c)
+}
@@ -1,9 +1,12 @@
 package rule_manager
 
 import (
+	"context"
+	"fmt"
 	"sync"
 
 	product_sdk "github.com/BrobridgeOrg/gravity-sdk/v2/product"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
 	"github.com/BrobridgeOrg/schemer"
 )
 
@@ -11,14 +14,91 @@ type Rule struct {
 	product_sdk.Rule
 	handlerPool  sync.Pool
 	Handler      *Handler
+	Fields       map[string]*FieldSchema
 	Schema       *schemer.Schema
 	TargetSchema *schemer.Schema
+
+	// OutputSubjectTemplate is used to compute subject for output with field values, such as "events.{country}".
+	// It takes place of subject of product stream, and subject prefixes are still prepended.
+	OutputSubjectTemplate string
+	outputSubject         *SubjectTemplate
+
+	// SampleRate keeps only a fraction of records (0 < rate < 1) which is chosen by hash of primary key.
+	SampleRate float64
+
+	// MaxPerSecond limits number of records per second, records beyond the limit are dropped.
+	MaxPerSecond int
+	limiter      *rateLimiter
+
+	// PrimaryKeyStrategy generates primary key for records without one, which is "uuid" or "hash" of payload.
+	PrimaryKeyStrategy string
+
+	// PrimaryKeyField is where generated key is stored. It is the only primary key if not set.
+	PrimaryKeyField string
+
+	// PrimaryKeySeparator joins values of composite primary key, which are escaped by PrimaryKeyEscaping
+	// ("backslash" by default, or "percent"). Values are joined by "_" without escaping if not set.
+	PrimaryKeySeparator string
+	PrimaryKeyEscaping  string
+
+	// UnknownFields is policy for fields not defined in schema, which drops them by default.
+	UnknownFields string
+
+	// EventTimeField is time field which tells when event happened, it is used for watermarks.
+	EventTimeField string
+
+	// CaseInsensitiveFields matches fields of payload to schema regardless of case, and renames them
+	// to the declared casing.
+	CaseInsensitiveFields bool
+
+	// SchemaRef refers to a shared schema in registry, which takes place of SchemaConfig.
+	SchemaRef *SchemaRef
+
+	// BaseSchemas refer to schemas in registry which are merged into schema of rule, such as audit fields.
+	BaseSchemas []SchemaRef
+
+	// CoerceStrings parses strings for numeric and boolean fields, such as "101" for int. Strings are rejected
+	// for these fields otherwise.
+	CoerceStrings bool
+
+	// StrictStrings rejects numbers and booleans for string fields, which are formatted as strings otherwise.
+	StrictStrings bool
+
+	// Priority orders rules of the same event, and rule with higher priority is applied first.
+	Priority int
+
+	// Explode names array field of payload whose elements become records on their own, such as line items of
+	// an order. Schema of rule describes these records.
+	Explode string
+
+	// RecordTransform names function in transform registry which transforms every record after handler.
+	RecordTransform string
+
+	// StrictPrimaryKey rejects fields of primary key which are nullable by default, so they have to be declared
+	// with "notNull". Only fields declared as nullable or optional are rejected otherwise.
+	StrictPrimaryKey bool
+
+	// SubjectPrefix is prepended to subjects of output, which is subject prefix of product.
+	SubjectPrefix string
+
+	// RemovedFieldsAsNull emits removed fields of partial update as null fields instead of "$removedFields".
+	RemovedFieldsAsNull bool
+
+	deprecatedFields []string
+	masks            []*fieldMask
+	fieldTransforms  []*fieldTransform
+	recordTransform  TransformFunc
+	coercionHook     CoercionHook
+	avroOnce         sync.Once
+	avroCodec        avroCodec
+	descriptorOnce   sync.Once
+	schemaDescriptor schemaDescriptor
 }
 
 func NewRule(rule *product_sdk.Rule) *Rule {
 
 	r := &Rule{
-		Rule: *rule,
+		Rule: cloneProductRule(rule),
 	}
 
 	r.handlerPool = sync.Pool{
@@ -33,14 +113,68 @@ func NewRule(rule *product_sdk.Rule) *Rule {
 func (r *Rule) applyConfigs() error {
 
 	// Preparing schema
-	schema := schemer.NewSchema()
-	err := schemer.Unmarshal(r.SchemaConfig, schema)
+	fields, schema, err := ParseSchemaConfig(r.SchemaConfig)
 	if err != nil {
 		return err
 	}
 
+	r.Fields = fields
 	r.Schema = schema
 
+	// Preparing output subject template
+	r.outputSubject = nil
+	if len(r.OutputSubjectTemplate) > 0 {
+		st, err := ParseSubjectTemplate(r.OutputSubjectTemplate)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range st.Fields() {
+			if len(r.Fields) > 0 && LookupFieldSchema(r.Fields, path) == nil {
+				return fmt.Errorf("%w: field \"%s\" is not defined in schema", ErrInvalidSubjectTemplate, path)
+			}
+		}
+
+		r.outputSubject = st
+	}
+
+	err = r.prepareUnknownFields()
+	if err != nil {
+		return err
+	}
+
+	err = r.preparePrimaryKeyStrategy()
+	if err != nil {
+		return err
+	}
+
+	err = r.preparePrimaryKeyFormat()
+	if err != nil {
+		return err
+	}
+
+	err = r.prepareEventTimeField()
+	if err != nil {
+		return err
+	}
+
+	r.prepareDeprecatedFields()
+
+	err = r.prepareMasks()
+	if err != nil {
+		return err
+	}
+
+	// Avro codec and schema descriptor are generated on demand
+	r.avroOnce = sync.Once{}
+	r.descriptorOnce = sync.Once{}
+
+	// Preparing rate limiter
+	r.limiter = nil
+	if r.MaxPerSecond > 0 {
+		r.limiter = newRateLimiter(r.MaxPerSecond)
+	}
+
 	// Preparing handler
 	if r.HandlerConfig == nil {
 		r.HandlerConfig = &product_sdk.HandlerConfig{
@@ -56,7 +190,141 @@ func (r *Rule) applyConfigs() error {
 }
 
 func (r *Rule) Transform(env map[string]interface{}, data map[string]interface{}) ([]map[string]interface{}, error) {
+	return r.TransformWithErrorCollection(env, data, FailFast)
+}
+
+// TransformWithErrorCollection transforms data like Transform, and mode decides whether errors of fields are
+// returned one at a time or all at once.
+func (r *Rule) TransformWithErrorCollection(env map[string]interface{}, data map[string]interface{}, mode ErrorCollectionMode) ([]map[string]interface{}, error) {
+	return r.TransformContext(context.Background(), env, data, mode)
+}
+
+// TransformContext transforms data like TransformWithErrorCollection, and gives up as soon as ctx is done.
+// Walking through payload is aborted with error of ctx, while script of handler always runs to completion.
+func (r *Rule) TransformContext(ctx context.Context, env map[string]interface{}, data map[string]interface{}, mode ErrorCollectionMode) ([]map[string]interface{}, error) {
+
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
 	handler := r.handlerPool.Get()
 	defer r.handlerPool.Put(handler)
-	return handler.(*Handler).Run(env, data)
+
+	if r.CaseInsensitiveFields {
+		err := normalizeFieldCase(r.Fields, "", data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Defaults are for complete records only, so they will be normalized with source schema as well
+	partial := IsPartialUpdate(data)
+	if !partial {
+		applyDefaults(r.Fields, r.Event, data)
+	}
+
+	ts := &transformState{
+		ctx:           ctx,
+		mode:          mode,
+		coerceStrings: r.CoerceStrings,
+		strictStrings: r.StrictStrings,
+	}
+
+	if r.coercionHook != nil {
+		err := applyCoercionHook(r.coercionHook, r.Fields, "", data, ts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = validateElements(r.Fields, "", data, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = prepareArrayOperations(r.Fields, data)
+	if err != nil {
+		return nil, err
+	}
+
+	passthrough, err := r.checkUnknownFields(data)
+	if err != nil && !ts.collect(err) {
+		return nil, err
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := handler.(*Handler).Run(env, data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	// Unknown fields are carried over without types
+	for k, v := range passthrough {
+		for _, result := range results {
+			if _, ok := result[k]; !ok {
+				result[k] = v
+			}
+		}
+	}
+
+	if len(r.PrimaryKeyStrategy) > 0 && !partial {
+		err := r.generatePrimaryKey(data, results)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Transformed values are coerced as well
+	results, err = r.applyTransforms(results)
+	if err != nil {
+		return nil, err
+	}
+
+	// Coerce values based on field schemas
+	for _, result := range results {
+		err := coerceFields(r.Fields, "", result, ts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = ts.err()
+	if err != nil {
+		return nil, err
+	}
+
+	// Masks are applied last, so conditions see coerced values
+	err = r.applyMasks(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// RenderOutputSubject computes output subject with values of record. It returns empty string if no template was set.
+func (r *Rule) RenderOutputSubject(record *record_type.Record) (string, error) {
+
+	if r.outputSubject == nil {
+		return "", nil
+	}
+
+	return r.outputSubject.Render(func(path string) (interface{}, bool) {
+		v, err := record.GetValueDataByPath(path)
+		if err != nil {
+			return nil, false
+		}
+
+		return v, true
+	})
 }
@@ -1,19 +1,39 @@
 package rule_manager
 
 import (
+	"sync"
+
 	"github.com/google/uuid"
 )
 
+// RuleManager is safe for concurrent use, so rules can be reloaded while processor is looking them up.
+// Lookups return slices which are not affected by later changes.
 type RuleManager struct {
-	rules  *RuleSet
-	events *EventManager
+	rules             *RuleSet
+	events            *EventManager
+	schemaRegistry    SchemaRegistry
+	transformRegistry *TransformRegistry
+	coercionHook      CoercionHook
+	matchMode         MatchMode
+
+	// eventMatchModes overrides match mode for specific events
+	eventMatchModes map[string]MatchMode
+
+	mutex sync.RWMutex
 }
 
-func NewRuleManager() *RuleManager {
-	return &RuleManager{
+func NewRuleManager(opts ...func(*RuleManager)) *RuleManager {
+
+	rm := &RuleManager{
 		rules:  NewRuleSet(),
 		events: NewEventManager(),
 	}
+
+	for _, o := range opts {
+		o(rm)
+	}
+
+	return rm
 }
 
 func (rm *RuleManager) AddRule(rule *Rule) error {
@@ -21,11 +41,36 @@ func (rm *RuleManager) AddRule(rule *Rule) error {
 	id, _ := uuid.NewUUID()
 	rule.ID = id.String()
 
-	err := rule.applyConfigs()
+	err := rm.resolveSchemaRef(rule)
+	if err != nil {
+		return err
+	}
+
+	err = rm.resolveBaseSchemas(rule)
+	if err != nil {
+		return err
+	}
+
+	err = rule.applyConfigs()
+	if err != nil {
+		return err
+	}
+
+	err = rule.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = rm.resolveTransforms(rule)
 	if err != nil {
 		return err
 	}
 
+	rule.coercionHook = rm.coercionHook
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
 	// Registering
 	rm.rules.Set(rule.ID, rule)
 	rm.events.AddRule(rule.Event, rule)
@@ -35,6 +80,9 @@ func (rm *RuleManager) AddRule(rule *Rule) error {
 
 func (rm *RuleManager) DeleteRule(id string) {
 
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
 	rule := rm.rules.Get(id)
 	if rule == nil {
 		return
@@ -46,15 +94,26 @@ func (rm *RuleManager) DeleteRule(id string) {
 }
 
 func (rm *RuleManager) GetRule(id string) *Rule {
+
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
 	return rm.rules.Get(id)
 }
 
 func (rm *RuleManager) GetRules() []*Rule {
+
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
 	return rm.rules.List()
 }
 
 func (rm *RuleManager) GetRulesByEvent(eventName string) []*Rule {
 
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
 	ruleSet := rm.events.GetRuleSet(eventName)
 	if ruleSet == nil {
 		return make([]*Rule, 0)
@@ -65,14 +124,22 @@ func (rm *RuleManager) GetRulesByEvent(eventName string) []*Rule {
 
 func (rm *RuleManager) GetRuleByEvent(eventName string) *Rule {
 
-	ruleSet := rm.events.GetRuleSet(eventName)
-	if ruleSet == nil {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	// Rule with the highest priority
+	rules := rm.match(eventName)
+	if len(rules) == 0 {
 		return nil
 	}
 
-	return ruleSet.First()
+	return rules[0]
 }
 
 func (rm *RuleManager) GetEvents() []string {
+
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
 	return rm.events.GetEvents()
 }
@@ -0,0 +1,277 @@
+package rule_manager
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	product_sdk "github.com/BrobridgeOrg/gravity-sdk/v2/product"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleManager_ConcurrentAccess(t *testing.T) {
+
+	rm := NewRuleManager()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	// Reloading rules
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				r := NewRule(product_sdk.NewRule())
+				r.Event = "dataCreated"
+				r.SchemaConfig = map[string]interface{}{
+					"id": map[string]interface{}{"type": "int"},
+				}
+
+				if !assert.Nil(t, rm.AddRule(r)) {
+					return
+				}
+
+				rm.DeleteRule(r.ID)
+			}
+		}()
+	}
+
+	// Matching rules
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			for _, r := range rm.GetRulesByEvent("dataCreated") {
+				assert.Equal(t, "dataCreated", r.Event)
+			}
+
+			rm.GetRuleByEvent("dataCreated")
+			rm.GetEvents()
+		}
+	}()
+
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	assert.Empty(t, rm.GetRules())
+	assert.Empty(t, rm.GetRulesByEvent("dataCreated"))
+}
+
+func TestRuleManager_MatchRules(t *testing.T) {
+
+	createRule := func(product string, priority int) *Rule {
+		r := NewRule(product_sdk.NewRule())
+		r.Event = "dataCreated"
+		r.Product = product
+		r.Priority = priority
+		return r
+	}
+
+	rm := NewRuleManager()
+	assert.Nil(t, rm.AddRule(createRule("Low", 1)))
+	assert.Nil(t, rm.AddRule(createRule("High", 10)))
+
+	// The higher one only
+	rules := rm.MatchRules("dataCreated")
+	if assert.Len(t, rules, 1) {
+		assert.Equal(t, "High", rules[0].Product)
+	}
+
+	assert.Equal(t, "High", rm.GetRuleByEvent("dataCreated").Product)
+
+	// Both of them in order of priority
+	rm.SetEventMatchMode("dataCreated", AllMatch)
+
+	rules = rm.MatchRules("dataCreated")
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, "High", rules[0].Product)
+		assert.Equal(t, "Low", rules[1].Product)
+	}
+
+	rm = NewRuleManager(WithMatchMode(AllMatch))
+	assert.Nil(t, rm.AddRule(createRule("Low", 1)))
+	assert.Nil(t, rm.AddRule(createRule("High", 10)))
+	assert.Len(t, rm.MatchRules("dataCreated"), 2)
+	assert.Empty(t, rm.MatchRules("dataUpdated"))
+}
+
+func TestRuleManager_Match(t *testing.T) {
+
+	rm := NewRuleManager()
+
+	r := NewRule(product_sdk.NewRule())
+	r.Event = "dataCreated"
+	r.Product = "TestDataProduct"
+	assert.Nil(t, rm.AddRule(r))
+
+	rules := rm.Match("dataCreated")
+	if assert.Len(t, rules, 1) {
+		assert.Equal(t, r.ID, rules[0].ID)
+	}
+
+	assert.Empty(t, rm.Match("unknown"))
+
+	// Every rule regardless of match mode
+	another := NewRule(product_sdk.NewRule())
+	another.Event = "dataCreated"
+	another.Product = "AnotherProduct"
+	another.Priority = 10
+	assert.Nil(t, rm.AddRule(another))
+
+	rules = rm.Match("dataCreated")
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, "AnotherProduct", rules[0].Product)
+		assert.Equal(t, "TestDataProduct", rules[1].Product)
+	}
+
+	assert.Len(t, rm.MatchRules("dataCreated"), 1)
+}
+
+func TestRuleManager_TransformRegistry(t *testing.T) {
+
+	registry := NewTransformRegistry()
+	registry.Register("normalizeAddress", func(value interface{}) (interface{}, error) {
+		s, ok := value.(string)
+		if !ok {
+			return nil, errors.New("address is not a string")
+		}
+
+		return strings.ToUpper(strings.TrimSpace(s)), nil
+	})
+	registry.Register("stamp", func(value interface{}) (interface{}, error) {
+		record := value.(map[string]interface{})
+		record["source"] = "registry"
+		return record, nil
+	})
+
+	rm := NewRuleManager(WithTransformRegistry(registry))
+
+	r := CreateTestRuleWithSchema(t, `{
+	"id": { "type": "int" },
+	"source": { "type": "string" },
+	"contact": {
+		"type": "map",
+		"fields": {
+			"address": { "type": "string", "transform": "normalizeAddress" }
+		}
+	}
+}`)
+	r.Event = "dataCreated"
+	r.RecordTransform = "stamp"
+
+	err := rm.AddRule(r)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id": float64(101),
+		"contact": map[string]interface{}{
+			"address": " 1 main st ",
+		},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]interface{}{"address": "1 MAIN ST"}, results[0]["contact"])
+		assert.Equal(t, "registry", results[0]["source"])
+	}
+
+	// Failure of transform
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id": float64(102),
+		"contact": map[string]interface{}{
+			"address": nil,
+		},
+	})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "contact.address")
+	}
+
+	// Unknown names are rejected
+	r = CreateTestRuleWithSchema(t, `{
+	"id": { "type": "int" },
+	"address": { "type": "string", "transform": "missing" }
+}`)
+	err = rm.AddRule(r)
+	assert.ErrorIs(t, err, ErrTransformNotFound)
+
+	r = CreateTestRuleWithSchema(t, `{ "id": { "type": "int" } }`)
+	r.RecordTransform = "missing"
+	err = NewRuleManager().AddRule(r)
+	assert.ErrorIs(t, err, ErrTransformNotFound)
+}
+
+func TestRuleManager_CoercionHook(t *testing.T) {
+
+	hook := func(field FieldSchema, raw interface{}) (interface{}, bool, error) {
+
+		s, ok := raw.(string)
+		if !ok || field.Type != "bool" {
+			return nil, false, nil
+		}
+
+		switch s {
+		case "Y":
+			return true, true, nil
+		case "N":
+			return false, true, nil
+		}
+
+		return nil, false, errors.New("expected Y or N")
+	}
+
+	rm := NewRuleManager(WithCoercionHook(hook))
+
+	r := CreateTestRuleWithSchema(t, `{
+	"id": { "type": "int" },
+	"enabled": { "type": "bool" },
+	"flags": { "type": "array", "subtype": "bool" }
+}`)
+	r.Event = "dataCreated"
+
+	err := rm.AddRule(r)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id":      float64(101),
+		"enabled": "Y",
+		"flags":   []interface{}{"N", true},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, true, results[0]["enabled"])
+		assert.Equal(t, []interface{}{false, true}, results[0]["flags"])
+	}
+
+	// Error of hook
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id":      float64(102),
+		"enabled": "maybe",
+	})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), `field "enabled": expected Y or N`)
+	}
+
+	// Built-in coercion rejects the same value without hook
+	r = CreateTestRuleWithSchema(t, `{ "id": { "type": "int" }, "enabled": { "type": "bool" } }`)
+	NewRuleManager().AddRule(r)
+
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id":      float64(103),
+		"enabled": "Y",
+	})
+	var coercionErr *CoercionError
+	assert.ErrorAs(t, err, &coercionErr)
+}
@@ -0,0 +1,1471 @@
+package rule_manager
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/converter"
+	product_sdk "github.com/BrobridgeOrg/gravity-sdk/v2/product"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func CreateTestRule(t *testing.T, schemaRaw string) *Rule {
+
+	var schemaConfig map[string]interface{}
+	err := json.Unmarshal([]byte(schemaRaw), &schemaConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRule(product_sdk.NewRule())
+	r.Event = "dataCreated"
+	r.Product = "TestDataProduct"
+	r.PrimaryKey = []string{
+		"id",
+	}
+	r.SchemaConfig = schemaConfig
+
+	rm := NewRuleManager()
+	err = rm.AddRule(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestRule_SizedIntegers(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"level": { "type": "int8" },
+	"count": { "type": "uint32" }
+}`)
+
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id":    float64(1),
+		"level": float64(-12),
+		"count": float64(4000000000),
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, int64(1), results[0]["id"])
+	assert.Equal(t, int8(-12), results[0]["level"])
+	assert.Equal(t, uint32(4000000000), results[0]["count"])
+}
+
+func TestRule_SizedIntegerOverflow(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"level": { "type": "int8" },
+	"count": { "type": "uint16" }
+}`)
+
+	_, err := r.Transform(nil, map[string]interface{}{
+		"id":    float64(1),
+		"level": float64(300),
+	})
+	assert.NotNil(t, err)
+
+	// Negative value for unsigned integer
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id":    float64(1),
+		"count": float64(-1),
+	})
+	assert.NotNil(t, err)
+}
+
+func TestRule_Bytes(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"signature": { "type": "bytes", "maxSize": 4 }
+}`)
+
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id":        float64(1),
+		"signature": "AQIDBA==",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, []byte{1, 2, 3, 4}, []byte(results[0]["signature"].(converter.Binary)))
+
+	// Invalid base64 string
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id":        float64(1),
+		"signature": "not base64!",
+	})
+	assert.NotNil(t, err)
+
+	// Exceeds max size
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id":        float64(1),
+		"signature": "AQIDBAU=",
+	})
+	assert.NotNil(t, err)
+}
+
+func TestFieldSchema_MaxSize(t *testing.T) {
+
+	// Any numeric type is accepted
+	for _, size := range []interface{}{4, int64(4), uint8(4), float64(4), json.Number("4")} {
+
+		fields, err := ParseFieldSchemas(map[string]interface{}{
+			"signature": map[string]interface{}{"type": "bytes", "maxSize": size},
+		})
+		if !assert.Nil(t, err, size) {
+			continue
+		}
+
+		fs := fields["signature"]
+
+		_, err = fs.coerceBytes("signature", "AQIDBA==")
+		assert.Nil(t, err, size)
+
+		_, err = fs.coerceBytes("signature", "AQIDBAU=")
+		assert.NotNil(t, err, size)
+	}
+
+	for _, size := range []interface{}{-1, float64(-1), 1.5, "4", json.Number("4.5"), true, nil} {
+		_, err := ParseFieldSchemas(map[string]interface{}{
+			"signature": map[string]interface{}{"type": "bytes", "maxSize": size},
+		})
+		assert.ErrorIs(t, err, ErrInvalidFieldDefinition, size)
+	}
+}
+
+func TestRule_OutputSubjectTemplateValidation(t *testing.T) {
+
+	rm := NewRuleManager()
+
+	r := NewRule(product_sdk.NewRule())
+	r.SchemaConfig = map[string]interface{}{
+		"id": map[string]interface{}{"type": "int"},
+	}
+
+	// Unclosed brace
+	r.OutputSubjectTemplate = "events.{id"
+	assert.ErrorIs(t, rm.AddRule(r), ErrInvalidSubjectTemplate)
+
+	// Unknown field
+	r.OutputSubjectTemplate = "events.{country}"
+	assert.ErrorIs(t, rm.AddRule(r), ErrInvalidSubjectTemplate)
+
+	r.OutputSubjectTemplate = "events.{id}"
+	assert.Nil(t, rm.AddRule(r))
+}
+
+func TestRule_DefaultsByEvent(t *testing.T) {
+
+	schemaRaw := `{
+	"id": { "type": "int" },
+	"status": {
+		"type": "string",
+		"default": {
+			"dataCreated": "new",
+			"dataImported": "imported"
+		}
+	},
+	"level": { "type": "int8", "default": 1, "defaultOn": [ "dataCreated" ] },
+	"tags": { "type": "array", "subtype": "string", "default": [] }
+}`
+
+	created := CreateTestRule(t, schemaRaw)
+
+	results, err := created.Transform(nil, map[string]interface{}{
+		"id": float64(1),
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, "new", results[0]["status"])
+	assert.Equal(t, int8(1), results[0]["level"])
+	assert.Equal(t, []interface{}{}, results[0]["tags"])
+
+	// Values from payload are kept
+	results, err = created.Transform(nil, map[string]interface{}{
+		"id":     float64(2),
+		"status": "active",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, "active", results[0]["status"])
+
+	// Defaults for another event
+	updated := CreateTestRule(t, schemaRaw)
+	updated.Event = "dataUpdated"
+
+	results, err = updated.Transform(nil, map[string]interface{}{
+		"id": float64(1),
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.NotContains(t, results[0], "status")
+	assert.NotContains(t, results[0], "level")
+	assert.Equal(t, []interface{}{}, results[0]["tags"])
+
+	// Partial update is never filled
+	results, err = created.Transform(nil, map[string]interface{}{
+		"id":          float64(1),
+		"nested.name": "fred",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.NotContains(t, results[0], "status")
+}
+
+func TestRule_InvalidDefaultOn(t *testing.T) {
+
+	_, _, err := ParseSchemaConfig(map[string]interface{}{
+		"status": map[string]interface{}{
+			"type":      "string",
+			"default":   "new",
+			"defaultOn": "dataCreated",
+		},
+	})
+	assert.ErrorIs(t, err, ErrInvalidFieldDefinition)
+}
+
+func TestRule_ArrayElements(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"tags": { "type": "array", "subtype": "string" },
+	"scores": { "type": "array", "subtype": "int" }
+}`)
+
+	// Clean string array
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id":   float64(1),
+		"tags": []interface{}{"a", "b"},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []interface{}{"a", "b"}, results[0]["tags"])
+	}
+
+	// Coercible elements, while strings for numbers are parsed only if it's enabled
+	r.CoerceStrings = true
+	results, err = r.Transform(nil, map[string]interface{}{
+		"id":     float64(1),
+		"tags":   []interface{}{"1", float64(2), true},
+		"scores": []interface{}{"3", float64(4)},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []interface{}{"1", "2", "true"}, results[0]["tags"])
+		assert.Equal(t, []interface{}{int64(3), int64(4)}, results[0]["scores"])
+	}
+
+	// Uncoercible elements
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id":   float64(1),
+		"tags": []interface{}{"1", float64(2), map[string]interface{}{"a": "b"}},
+	})
+
+	var coercionErr *CoercionError
+	if assert.ErrorAs(t, err, &coercionErr) {
+		assert.Equal(t, "tags.2", coercionErr.Field)
+		assert.Equal(t, "map", coercionErr.From)
+	}
+
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id":     float64(1),
+		"scores": []interface{}{float64(1), "abc"},
+	})
+	if assert.ErrorAs(t, err, &coercionErr) {
+		assert.Equal(t, "scores.1", coercionErr.Field)
+	}
+}
+
+func TestRule_ArrayUniqueSort(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"tags": { "type": "array", "subtype": "string", "unique": true, "sort": "asc" },
+	"scores": { "type": "array", "subtype": "int32", "unique": true, "sort": "desc" },
+	"history": { "type": "array", "subtype": "string", "sort": "asc" }
+}`)
+
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id":      float64(1),
+		"tags":    []interface{}{"b", "a", "b", "c", "a"},
+		"scores":  []interface{}{float64(3), float64(10), float64(3), float64(1)},
+		"history": []interface{}{"b", "a", "b"},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []interface{}{"a", "b", "c"}, results[0]["tags"])
+		assert.Equal(t, []interface{}{int32(10), int32(3), int32(1)}, results[0]["scores"])
+
+		// Duplicates are kept without unique
+		assert.Equal(t, []interface{}{"a", "b", "b"}, results[0]["history"])
+	}
+
+	// Invalid definitions
+	for _, schema := range []string{
+		`{ "tags": { "type": "array", "subtype": "string", "sort": "up" } }`,
+		`{ "tags": { "type": "array", "subtype": "string", "unique": "yes" } }`,
+		`{ "tags": { "type": "string", "unique": true } }`,
+		`{ "tags": { "type": "array", "subtype": "map", "sort": "asc" } }`,
+	} {
+		var config map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(schema), &config))
+
+		_, err := ParseFieldSchemas(config)
+		assert.ErrorIs(t, err, ErrInvalidFieldDefinition, schema)
+	}
+}
+
+func TestRule_ToJSONSchema(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"name": { "type": "string", "required": true, "pattern": "^[a-z]+$", "maxLength": 32 },
+	"gender": { "type": "string", "enum": [ "male", "female" ] },
+	"level": { "type": "uint8", "max": 10 },
+	"nested": {
+		"type": "map",
+		"fields": {
+			"nested_id": { "type": "string" }
+		}
+	},
+	"tags": {
+		"type": "array",
+		"subtype": "string"
+	}
+}`)
+
+	doc, err := r.ToJSONSchema()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	expected := `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"title": "TestDataProduct",
+	"type": "object",
+	"required": [ "id", "name" ],
+	"properties": {
+		"id": { "type": "integer" },
+		"name": { "type": "string", "pattern": "^[a-z]+$", "maxLength": 32 },
+		"gender": { "type": "string", "enum": [ "male", "female" ] },
+		"level": { "type": "integer", "minimum": 0, "maximum": 10 },
+		"nested": {
+			"type": "object",
+			"properties": {
+				"nested_id": { "type": "string" }
+			}
+		},
+		"tags": {
+			"type": "array",
+			"items": { "type": "string" }
+		}
+	}
+}`
+
+	assert.JSONEq(t, expected, string(doc))
+}
+
+func TestRule_ToJSONSchemaWithNestedPrimaryKey(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"nested": {
+		"type": "map",
+		"fields": {
+			"nested_id": { "type": "string" },
+			"name": { "type": "string" }
+		}
+	}
+}`)
+	r.PrimaryKey = []string{"id", "nested.nested_id"}
+
+	doc, err := r.ToJSONSchema()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	expected := `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"title": "TestDataProduct",
+	"type": "object",
+	"required": [ "id", "nested" ],
+	"properties": {
+		"id": { "type": "integer" },
+		"nested": {
+			"type": "object",
+			"required": [ "nested_id" ],
+			"properties": {
+				"nested_id": { "type": "string" },
+				"name": { "type": "string" }
+			}
+		}
+	}
+}`
+
+	assert.JSONEq(t, expected, string(doc))
+}
+
+func TestRule_PrimaryKeyStrategy(t *testing.T) {
+
+	schemaRaw := `{
+	"key": { "type": "string" },
+	"message": { "type": "string" }
+}`
+
+	createRule := func(strategy string) *Rule {
+		r := NewRule(product_sdk.NewRule())
+		r.Event = "logCreated"
+		r.Product = "TestLogs"
+		r.PrimaryKeyStrategy = strategy
+		r.PrimaryKeyField = "key"
+
+		err := json.Unmarshal([]byte(schemaRaw), &r.SchemaConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = NewRuleManager().AddRule(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	}
+
+	// UUID
+	r := createRule(PrimaryKeyStrategyUUID)
+	assert.Equal(t, []string{"key"}, r.PrimaryKey)
+
+	results, err := r.Transform(nil, map[string]interface{}{
+		"message": "hello",
+	})
+	if assert.Nil(t, err) {
+		_, err := uuid.Parse(results[0]["key"].(string))
+		assert.Nil(t, err)
+	}
+
+	// Key from payload is kept
+	results, err = r.Transform(nil, map[string]interface{}{
+		"key":     "natural",
+		"message": "hello",
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "natural", results[0]["key"])
+	}
+
+	// Hash
+	r = createRule(PrimaryKeyStrategyHash)
+
+	keys := make([]string, 0)
+	for _, message := range []string{"hello", "hello", "world"} {
+		results, err := r.Transform(nil, map[string]interface{}{
+			"message": message,
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		keys = append(keys, results[0]["key"].(string))
+	}
+
+	assert.Len(t, keys[0], 64)
+	assert.Equal(t, keys[0], keys[1])
+	assert.NotEqual(t, keys[0], keys[2])
+}
+
+func TestRule_InvalidPrimaryKeyStrategy(t *testing.T) {
+
+	r := NewRule(product_sdk.NewRule())
+	r.PrimaryKeyStrategy = "sequence"
+	r.PrimaryKeyField = "key"
+	assert.ErrorIs(t, NewRuleManager().AddRule(r), ErrInvalidPrimaryKeyStrategy)
+
+	// Field has to be one of primary keys
+	r = NewRule(product_sdk.NewRule())
+	r.PrimaryKey = []string{"id"}
+	r.PrimaryKeyStrategy = PrimaryKeyStrategyUUID
+	r.PrimaryKeyField = "key"
+	assert.ErrorIs(t, NewRuleManager().AddRule(r), ErrInvalidPrimaryKeyStrategy)
+
+	// Generated key is unable to be stored in nested field
+	r = NewRule(product_sdk.NewRule())
+	r.PrimaryKey = []string{"nested.key"}
+	r.PrimaryKeyStrategy = PrimaryKeyStrategyUUID
+	assert.ErrorIs(t, NewRuleManager().AddRule(r), ErrInvalidPrimaryKeyStrategy)
+}
+
+func TestRule_UnknownFields(t *testing.T) {
+
+	schemaRaw := `{
+	"id": { "type": "int" },
+	"name": { "type": "string" }
+}`
+
+	payload := func() map[string]interface{} {
+		return map[string]interface{}{
+			"id":    float64(1),
+			"name":  "fred",
+			"extra": "value",
+		}
+	}
+
+	// Unknown fields are dropped by default
+	r := CreateTestRule(t, schemaRaw)
+	assert.Equal(t, UnknownFieldsIgnore, r.UnknownFields)
+
+	results, err := r.Transform(nil, payload())
+	if assert.Nil(t, err) {
+		assert.NotContains(t, results[0], "extra")
+		assert.Equal(t, "fred", results[0]["name"])
+	}
+
+	// Strict
+	r = CreateTestRule(t, schemaRaw)
+	r.UnknownFields = UnknownFieldsError
+
+	_, err = r.Transform(nil, payload())
+	var validationErr *SchemaValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, "extra", validationErr.Field)
+	}
+
+	// Passthrough
+	r = CreateTestRule(t, schemaRaw)
+	r.UnknownFields = UnknownFieldsPassthrough
+
+	results, err = r.Transform(nil, payload())
+	if assert.Nil(t, err) {
+		assert.Equal(t, "value", results[0]["extra"])
+		assert.Equal(t, int64(1), results[0]["id"])
+	}
+}
+
+func TestRule_InvalidUnknownFields(t *testing.T) {
+
+	r := NewRule(product_sdk.NewRule())
+	r.UnknownFields = "reject"
+	assert.ErrorIs(t, NewRuleManager().AddRule(r), ErrInvalidUnknownFieldsPolicy)
+}
+
+func TestRule_EventTime(t *testing.T) {
+
+	r := NewRule(product_sdk.NewRule())
+	r.EventTimeField = "meta.updatedAt"
+	r.SchemaConfig = map[string]interface{}{
+		"id": map[string]interface{}{"type": "int"},
+		"meta": map[string]interface{}{
+			"type": "map",
+			"fields": map[string]interface{}{
+				"updatedAt": map[string]interface{}{"type": "time"},
+			},
+		},
+	}
+
+	if !assert.Nil(t, NewRuleManager().AddRule(r)) {
+		return
+	}
+
+	updatedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	record := record_type.NewRecord()
+	fields, err := converter.Convert(r.Schema, map[string]interface{}{
+		"id": int64(1),
+		"meta": map[string]interface{}{
+			"updatedAt": updatedAt,
+		},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	record.Payload.Map.Fields = fields
+
+	et, ok := r.EventTime(record)
+	if assert.True(t, ok) {
+		assert.True(t, updatedAt.Equal(et))
+	}
+
+	// Field is missing
+	record.Payload.Map.Fields, _ = converter.Convert(r.Schema, map[string]interface{}{
+		"id": int64(1),
+	})
+	_, ok = r.EventTime(record)
+	assert.False(t, ok)
+}
+
+func TestRule_InvalidEventTimeField(t *testing.T) {
+
+	r := NewRule(product_sdk.NewRule())
+	r.EventTimeField = "name"
+	r.SchemaConfig = map[string]interface{}{
+		"name": map[string]interface{}{"type": "string"},
+	}
+
+	assert.ErrorIs(t, NewRuleManager().AddRule(r), ErrInvalidEventTimeField)
+}
+
+func TestRule_CaseInsensitiveFields(t *testing.T) {
+
+	schemaRaw := `{
+	"id": { "type": "int" },
+	"userName": { "type": "string" },
+	"nested": {
+		"type": "map",
+		"fields": {
+			"nestedId": { "type": "string" }
+		}
+	}
+}`
+
+	payload := func() map[string]interface{} {
+		return map[string]interface{}{
+			"ID":       float64(1),
+			"USERNAME": "fred",
+			"Nested": map[string]interface{}{
+				"NestedID": "n1",
+			},
+		}
+	}
+
+	// Mismatched fields are dropped by default
+	r := CreateTestRule(t, schemaRaw)
+
+	results, err := r.Transform(nil, payload())
+	if assert.Nil(t, err) {
+		assert.NotContains(t, results[0], "userName")
+	}
+
+	r = CreateTestRule(t, schemaRaw)
+	r.CaseInsensitiveFields = true
+
+	results, err = r.Transform(nil, payload())
+	if assert.Nil(t, err) {
+		assert.Equal(t, int64(1), results[0]["id"])
+		assert.Equal(t, "fred", results[0]["userName"])
+		assert.NotContains(t, results[0], "USERNAME")
+		assert.Equal(t, map[string]interface{}{"nestedId": "n1"}, results[0]["nested"])
+	}
+
+	// Ambiguous collision
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id":       float64(1),
+		"userName": "fred",
+		"UserName": "stacy",
+	})
+
+	var validationErr *SchemaValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, "userName", validationErr.Field)
+	}
+}
+
+type fakeSchemaRegistry map[string]map[string]interface{}
+
+func (reg fakeSchemaRegistry) GetSchema(name string, version string) (map[string]interface{}, error) {
+	return reg[name+"@"+version], nil
+}
+
+func TestRule_SchemaRef(t *testing.T) {
+
+	registry := fakeSchemaRegistry{
+		"accounts@2": {
+			"id":      map[string]interface{}{"type": "int"},
+			"balance": map[string]interface{}{"type": "uint32"},
+		},
+	}
+
+	rm := NewRuleManager(WithSchemaRegistry(registry))
+
+	r := NewRule(product_sdk.NewRule())
+	r.Event = "accountCreated"
+	r.Product = "Accounts"
+	r.PrimaryKey = []string{"id"}
+	r.SchemaRef = &SchemaRef{Name: "accounts", Version: "2"}
+
+	// Inline schema is overridden by the registered one
+	r.SchemaConfig = map[string]interface{}{
+		"id": map[string]interface{}{"type": "string"},
+	}
+
+	if !assert.Nil(t, rm.AddRule(r)) {
+		return
+	}
+
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id":      float64(1),
+		"balance": float64(300),
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, int64(1), results[0]["id"])
+		assert.Equal(t, uint32(300), results[0]["balance"])
+	}
+
+	// Unknown version
+	r = NewRule(product_sdk.NewRule())
+	r.SchemaRef = &SchemaRef{Name: "accounts", Version: "3"}
+	assert.ErrorIs(t, rm.AddRule(r), ErrSchemaNotFound)
+
+	// No registry
+	r = NewRule(product_sdk.NewRule())
+	r.SchemaRef = &SchemaRef{Name: "accounts", Version: "2"}
+	assert.ErrorIs(t, NewRuleManager().AddRule(r), ErrSchemaRegistryNotAvailable)
+}
+
+func CreateTestRuleWithSchema(t *testing.T, schemaRaw string) *Rule {
+
+	var schemaConfig map[string]interface{}
+	err := json.Unmarshal([]byte(schemaRaw), &schemaConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRule(product_sdk.NewRule())
+	r.PrimaryKey = []string{"id"}
+	r.SchemaConfig = schemaConfig
+
+	return r
+}
+
+func TestCheckCompatibility(t *testing.T) {
+
+	old := CreateTestRuleWithSchema(t, `{
+	"id": { "type": "int" },
+	"name": { "type": "string" },
+	"level": { "type": "int8" },
+	"nested": {
+		"type": "map",
+		"fields": {
+			"nested_id": { "type": "string" }
+		}
+	}
+}`)
+
+	testCases := []struct {
+		name       string
+		schema     string
+		compatible bool
+		change     SchemaChange
+	}{
+		{
+			name: "added optional field",
+			schema: `{
+	"id": { "type": "int" },
+	"name": { "type": "string" },
+	"level": { "type": "int8" },
+	"email": { "type": "string" },
+	"nested": { "type": "map", "fields": { "nested_id": { "type": "string" } } }
+}`,
+			compatible: true,
+			change:     SchemaChange{Path: "email", Kind: SchemaChangeFieldAdded, To: "string"},
+		},
+		{
+			name: "removed field",
+			schema: `{
+	"id": { "type": "int" },
+	"level": { "type": "int8" },
+	"nested": { "type": "map", "fields": { "nested_id": { "type": "string" } } }
+}`,
+			change: SchemaChange{Path: "name", Kind: SchemaChangeFieldRemoved, From: "string", Breaking: true},
+		},
+		{
+			name: "int to string",
+			schema: `{
+	"id": { "type": "string" },
+	"name": { "type": "string" },
+	"level": { "type": "int8" },
+	"nested": { "type": "map", "fields": { "nested_id": { "type": "string" } } }
+}`,
+			change: SchemaChange{Path: "id", Kind: SchemaChangeTypeChanged, From: "int", To: "string", Breaking: true},
+		},
+		{
+			name: "widened integer",
+			schema: `{
+	"id": { "type": "int" },
+	"name": { "type": "string" },
+	"level": { "type": "int32" },
+	"nested": { "type": "map", "fields": { "nested_id": { "type": "string" } } }
+}`,
+			compatible: true,
+			change:     SchemaChange{Path: "level", Kind: SchemaChangeTypeChanged, From: "int8", To: "int32"},
+		},
+		{
+			name: "added required nested field",
+			schema: `{
+	"id": { "type": "int" },
+	"name": { "type": "string" },
+	"level": { "type": "int8" },
+	"nested": { "type": "map", "fields": {
+		"nested_id": { "type": "string" },
+		"owner": { "type": "string", "notNull": true }
+	} }
+}`,
+			change: SchemaChange{Path: "nested.owner", Kind: SchemaChangeRequiredAdded, To: "string", Breaking: true},
+		},
+	}
+
+	for _, tc := range testCases {
+
+		report, err := CheckCompatibility(old, CreateTestRuleWithSchema(t, tc.schema))
+		if !assert.Nil(t, err, tc.name) {
+			continue
+		}
+
+		assert.Equal(t, tc.compatible, report.Compatible(), tc.name)
+		assert.Equal(t, []SchemaChange{tc.change}, report.Changes, tc.name)
+	}
+
+	// Primary key
+	new := CreateTestRuleWithSchema(t, `{ "id": { "type": "int" }, "name": { "type": "string" }, "level": { "type": "int8" } }`)
+	new.PrimaryKey = []string{"name"}
+
+	report, err := CheckCompatibility(old, new)
+	if assert.Nil(t, err) {
+		assert.False(t, report.Compatible())
+		assert.Len(t, report.BreakingChanges(), 2)
+	}
+}
+
+func TestRule_PrimaryKeySeparator(t *testing.T) {
+
+	calculate := func(r *Rule, data map[string]interface{}) string {
+
+		record := record_type.NewRecord()
+		err := record_type.UnmarshalMapData(data, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := r.CalculateKey(record)
+		assert.Nil(t, err)
+
+		return string(key)
+	}
+
+	r := NewRule(product_sdk.NewRule())
+	r.PrimaryKey = []string{"region", "name"}
+	r.SchemaConfig = map[string]interface{}{
+		"region": map[string]interface{}{"type": "string"},
+		"name":   map[string]interface{}{"type": "string"},
+	}
+
+	// Legacy format
+	if !assert.Nil(t, NewRuleManager().AddRule(r)) {
+		return
+	}
+
+	assert.Equal(t, "tw_fred", calculate(r, map[string]interface{}{"region": "tw", "name": "fred"}))
+
+	// Custom separator
+	r.PrimaryKeySeparator = "|"
+	if !assert.Nil(t, NewRuleManager().AddRule(r)) {
+		return
+	}
+
+	assert.Equal(t, PrimaryKeyEscapingBackslash, r.PrimaryKeyEscaping)
+	assert.Equal(t, "tw|fred", calculate(r, map[string]interface{}{"region": "tw", "name": "fred"}))
+	assert.Equal(t, `a\|b|c`, calculate(r, map[string]interface{}{"region": "a|b", "name": "c"}))
+	assert.Equal(t, `a|b\|c`, calculate(r, map[string]interface{}{"region": "a", "name": "b|c"}))
+	assert.Equal(t, `a\\|\|c`, calculate(r, map[string]interface{}{"region": `a\`, "name": "|c"}))
+
+	r.PrimaryKeySeparator = "::"
+	r.PrimaryKeyEscaping = PrimaryKeyEscapingPercent
+	if !assert.Nil(t, NewRuleManager().AddRule(r)) {
+		return
+	}
+
+	assert.Equal(t, "a%3A%3Ab::100%25", calculate(r, map[string]interface{}{"region": "a::b", "name": "100%"}))
+	assert.Equal(t, "a%3A::b", calculate(r, map[string]interface{}{"region": "a:", "name": "b"}))
+	assert.Equal(t, "a::%3Ab", calculate(r, map[string]interface{}{"region": "a", "name": ":b"}))
+
+	// Invalid formats
+	r.PrimaryKeySeparator = `\`
+	r.PrimaryKeyEscaping = ""
+	assert.ErrorIs(t, NewRuleManager().AddRule(r), ErrInvalidPrimaryKeyFormat)
+
+	r.PrimaryKeySeparator = ""
+	r.PrimaryKeyEscaping = PrimaryKeyEscapingPercent
+	assert.ErrorIs(t, NewRuleManager().AddRule(r), ErrInvalidPrimaryKeyFormat)
+
+	r.PrimaryKeySeparator = "|"
+	r.PrimaryKeyEscaping = "base64"
+	assert.ErrorIs(t, NewRuleManager().AddRule(r), ErrInvalidPrimaryKeyFormat)
+}
+
+func TestRule_ConditionalMask(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"country": { "type": "string" },
+	"ssn": {
+		"type": "string",
+		"mask": { "keep": 4, "when": "source.country == 'US'" }
+	},
+	"contact": {
+		"type": "map",
+		"fields": {
+			"phone": { "type": "string", "mask": true }
+		}
+	}
+}`)
+
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id":      float64(1),
+		"country": "US",
+		"ssn":     "123-45-6789",
+		"contact": map[string]interface{}{
+			"phone": "5550100",
+		},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "*******6789", results[0]["ssn"])
+		assert.Equal(t, "*******", results[0]["contact"].(map[string]interface{})["phone"])
+	}
+
+	// Condition is false
+	results, err = r.Transform(nil, map[string]interface{}{
+		"id":      float64(2),
+		"country": "TW",
+		"ssn":     "A123456789",
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "A123456789", results[0]["ssn"])
+	}
+}
+
+func TestRule_MaskConditionIsolated(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"country": { "type": "string" },
+	"ssn": {
+		"type": "string",
+		"mask": { "keep": 4, "when": "(source.country = 'US') == 'US'" }
+	}
+}`)
+
+	// Condition changes its copy of record only
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id":      float64(1),
+		"country": "TW",
+		"ssn":     "A123456789",
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "TW", results[0]["country"])
+		assert.Equal(t, "******6789", results[0]["ssn"])
+	}
+
+	// Globals are never left for later evaluations
+	r = CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"ssn": {
+		"type": "string",
+		"mask": { "when": "(globalThis.masked = true)" }
+	}
+}`)
+
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id":  float64(1),
+		"ssn": "A123456789",
+	})
+	assert.ErrorIs(t, err, ErrInvalidMask)
+}
+
+func TestRule_MaskConditionTimeout(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"ssn": {
+		"type": "string",
+		"mask": { "when": "(function() { while (true) {} })()" }
+	}
+}`)
+
+	_, err := r.Transform(nil, map[string]interface{}{
+		"id":  float64(1),
+		"ssn": "A123456789",
+	})
+	assert.ErrorIs(t, err, ErrMaskConditionTimeout)
+}
+
+func TestRule_InvalidMask(t *testing.T) {
+
+	schemas := []string{
+		`{ "id": { "type": "int", "mask": true } }`,
+		`{ "ssn": { "type": "string", "mask": { "keep": -1 } } }`,
+		`{ "ssn": { "type": "string", "mask": { "char": "##" } } }`,
+		`{ "ssn": { "type": "string", "mask": { "when": "source.country ==" } } }`,
+	}
+
+	for _, schema := range schemas {
+		r := CreateTestRuleWithSchema(t, schema)
+		assert.ErrorIs(t, NewRuleManager().AddRule(r), ErrInvalidMask, schema)
+	}
+}
+
+func TestRule_BaseSchemas(t *testing.T) {
+
+	registry := fakeSchemaRegistry{
+		"audit@1": {
+			"created_at": map[string]interface{}{"type": "time"},
+			"updated_at": map[string]interface{}{"type": "time"},
+			"tenant_id":  map[string]interface{}{"type": "string", "default": "public"},
+			"meta": map[string]interface{}{
+				"type": "map",
+				"fields": map[string]interface{}{
+					"source": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+		"conflict@1": {
+			"tenant_id": map[string]interface{}{"type": "int"},
+		},
+	}
+
+	rm := NewRuleManager(WithSchemaRegistry(registry))
+
+	r := CreateTestRuleWithSchema(t, `{
+	"id": { "type": "int" },
+	"name": { "type": "string" },
+	"tenant_id": { "type": "string", "default": "acme" },
+	"meta": {
+		"type": "map",
+		"fields": {
+			"version": { "type": "int" }
+		}
+	}
+}`)
+	r.Event = "dataCreated"
+	r.BaseSchemas = []SchemaRef{{Name: "audit", Version: "1"}}
+
+	if !assert.Nil(t, rm.AddRule(r)) {
+		return
+	}
+
+	assert.ElementsMatch(t, []string{"id", "name", "created_at", "updated_at", "tenant_id", "meta"}, sortedFieldNames(r.Fields))
+	assert.ElementsMatch(t, []string{"source", "version"}, sortedFieldNames(r.Fields["meta"].Fields))
+
+	// Rule overrides base
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id": float64(1),
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "acme", results[0]["tenant_id"])
+	}
+
+	// Type of field is not allowed to be changed
+	r = CreateTestRuleWithSchema(t, `{ "id": { "type": "int" } }`)
+	r.BaseSchemas = []SchemaRef{{Name: "audit", Version: "1"}, {Name: "conflict", Version: "1"}}
+	assert.ErrorIs(t, rm.AddRule(r), ErrSchemaConflict)
+
+	r = CreateTestRuleWithSchema(t, `{ "id": { "type": "int" }, "created_at": { "type": "string" } }`)
+	r.BaseSchemas = []SchemaRef{{Name: "audit", Version: "1"}}
+	assert.ErrorIs(t, rm.AddRule(r), ErrSchemaConflict)
+
+	r = CreateTestRuleWithSchema(t, `{ "id": { "type": "int" } }`)
+	r.BaseSchemas = []SchemaRef{{Name: "missing", Version: "1"}}
+	assert.ErrorIs(t, rm.AddRule(r), ErrSchemaNotFound)
+}
+
+func TestRule_MapValueType(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"attributes": { "type": "map", "valueType": "string" },
+	"counters": { "type": "map", "valueType": { "type": "uint8" } }
+}`)
+
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id": float64(1),
+		"attributes": map[string]interface{}{
+			"color":   "red",
+			"size":    "L",
+			"weight":  float64(2.5),
+			"limited": true,
+		},
+		"counters": map[string]interface{}{
+			"views": float64(3),
+		},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]interface{}{
+			"color":   "red",
+			"size":    "L",
+			"weight":  "2.5",
+			"limited": "true",
+		}, results[0]["attributes"])
+		assert.Equal(t, map[string]interface{}{"views": uint8(3)}, results[0]["counters"])
+	}
+
+	// Value which is unable to be coerced
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id": float64(2),
+		"attributes": map[string]interface{}{
+			"color":  "red",
+			"nested": map[string]interface{}{"a": "b"},
+		},
+	})
+	var coercionErr *CoercionError
+	if assert.ErrorAs(t, err, &coercionErr) {
+		assert.Equal(t, "attributes.nested", coercionErr.Field)
+	}
+
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id": float64(3),
+		"counters": map[string]interface{}{
+			"views": float64(300),
+		},
+	})
+	if assert.ErrorAs(t, err, &coercionErr) {
+		assert.Equal(t, "counters.views", coercionErr.Field)
+	}
+
+	// Value type replaces fields
+	_, err = ParseFieldSchemas(map[string]interface{}{
+		"attributes": map[string]interface{}{
+			"type":      "map",
+			"valueType": "string",
+			"fields": map[string]interface{}{
+				"color": map[string]interface{}{"type": "string"},
+			},
+		},
+	})
+	assert.ErrorIs(t, err, ErrInvalidFieldDefinition)
+}
+
+func TestRule_TransformContext(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"levels": { "type": "array", "subtype": "int8" }
+}`)
+
+	// Pathological payload
+	levels := make([]interface{}, 1000000)
+	for i := range levels {
+		levels[i] = float64(i % 100)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := r.TransformContext(ctx, nil, map[string]interface{}{
+		"id":     float64(1),
+		"levels": levels,
+	}, CollectAll)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// Cancelled already
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	_, err = r.TransformContext(ctx, nil, map[string]interface{}{
+		"id": float64(1),
+	}, FailFast)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRule_CoerceStrings(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"score": { "type": "float" },
+	"enabled": { "type": "bool" },
+	"level": { "type": "uint8" },
+	"name": { "type": "string" }
+}`)
+
+	data := func() map[string]interface{} {
+		return map[string]interface{}{
+			"id":      "101",
+			"score":   "9.5",
+			"enabled": "true",
+			"level":   "3",
+			"name":    "fred",
+		}
+	}
+
+	// Strings are genuine type errors
+	_, err := r.Transform(nil, data())
+	var coercionErr *CoercionError
+	if assert.ErrorAs(t, err, &coercionErr) {
+		assert.Equal(t, "string", coercionErr.From)
+	}
+
+	_, err = r.TransformWithErrorCollection(nil, data(), CollectAll)
+	var fieldErrs *FieldErrors
+	if assert.ErrorAs(t, err, &fieldErrs) {
+		assert.Len(t, fieldErrs.Errors, 4)
+	}
+
+	// Parsed
+	r.CoerceStrings = true
+	results, err := r.Transform(nil, data())
+	if assert.Nil(t, err) {
+		assert.Equal(t, int64(101), results[0]["id"])
+		assert.Equal(t, float64(9.5), results[0]["score"])
+		assert.Equal(t, true, results[0]["enabled"])
+		assert.Equal(t, uint8(3), results[0]["level"])
+		assert.Equal(t, "fred", results[0]["name"])
+	}
+
+	// Not a number at all
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id": "abc",
+	})
+	if assert.ErrorAs(t, err, &coercionErr) {
+		assert.Equal(t, "id", coercionErr.Field)
+	}
+}
+
+func TestRule_Clone(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"profile": {
+		"type": "map",
+		"fields": {
+			"name": { "type": "string" }
+		}
+	}
+}`)
+	r.Priority = 3
+
+	c := r.Clone()
+	assert.Equal(t, r.ID, c.ID)
+	assert.Equal(t, 3, c.Priority)
+
+	// Changing clone
+	c.PrimaryKey[0] = "uid"
+	c.SchemaConfig["name"] = map[string]interface{}{"type": "string"}
+	profile := c.SchemaConfig["profile"].(map[string]interface{})
+	profile["fields"].(map[string]interface{})["age"] = map[string]interface{}{"type": "int"}
+
+	assert.Equal(t, []string{"id"}, r.PrimaryKey)
+	assert.NotContains(t, r.SchemaConfig, "name")
+	profile = r.SchemaConfig["profile"].(map[string]interface{})
+	assert.NotContains(t, profile["fields"], "age")
+
+	// Clone is prepared on its own
+	results, err := c.Transform(nil, map[string]interface{}{
+		"id": float64(101),
+		"profile": map[string]interface{}{
+			"name": "fred",
+		},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, int64(101), results[0]["id"])
+		assert.Equal(t, map[string]interface{}{"name": "fred"}, results[0]["profile"])
+	}
+
+	// Source of rule is copied as well
+	source := product_sdk.NewRule()
+	source.SchemaConfig = map[string]interface{}{
+		"id": map[string]interface{}{"type": "int"},
+	}
+
+	r = NewRule(source)
+	source.SchemaConfig["id"].(map[string]interface{})["type"] = "string"
+	assert.Equal(t, "int", r.SchemaConfig["id"].(map[string]interface{})["type"])
+}
+
+func TestRule_GeoPoint(t *testing.T) {
+
+	r := CreateTestRule(t, `{
+	"id": { "type": "int" },
+	"location": { "type": "geopoint" },
+	"stops": { "type": "array", "subtype": "geopoint" }
+}`)
+
+	// Object form
+	results, err := r.Transform(nil, map[string]interface{}{
+		"id":       float64(101),
+		"location": map[string]interface{}{"lat": float64(25.03), "lng": float64(121.56)},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]interface{}{"lat": float64(25.03), "lng": float64(121.56)}, results[0]["location"])
+	}
+
+	// Array form is [lng, lat]
+	results, err = r.Transform(nil, map[string]interface{}{
+		"id":       float64(101),
+		"location": []interface{}{float64(121.56), float64(25.03)},
+		"stops": []interface{}{
+			[]interface{}{float64(-73.98), float64(40.75)},
+		},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]interface{}{"lat": float64(25.03), "lng": float64(121.56)}, results[0]["location"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"lat": float64(40.75), "lng": float64(-73.98)},
+		}, results[0]["stops"])
+	}
+
+	// Latitude is out of range
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id":       float64(101),
+		"location": map[string]interface{}{"lat": float64(91), "lng": float64(121.56)},
+	})
+	var validationErr *SchemaValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, "location", validationErr.Field)
+	}
+
+	// Not a location at all
+	_, err = r.Transform(nil, map[string]interface{}{
+		"id":       float64(101),
+		"location": map[string]interface{}{"lat": "north"},
+	})
+	var coercionErr *CoercionError
+	if assert.ErrorAs(t, err, &coercionErr) {
+		assert.Equal(t, "location.lat", coercionErr.Field)
+	}
+}
+
+func TestRule_Validate(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		keys    []string
+		schema  string
+		invalid []string
+	}{
+		{
+			name:   "required key",
+			keys:   []string{"id"},
+			schema: `{ "id": { "type": "int", "required": true }, "name": { "type": "string", "nullable": true } }`,
+		},
+		{
+			name:   "implicitly required key",
+			keys:   []string{"id"},
+			schema: `{ "id": { "type": "int" } }`,
+		},
+		{
+			name:    "nullable key",
+			keys:    []string{"id"},
+			schema:  `{ "id": { "type": "int", "nullable": true } }`,
+			invalid: []string{`field "id" is nullable`},
+		},
+		{
+			name:   "key which is nullable by default",
+			keys:   []string{"id"},
+			schema: `{ "id": { "type": "int" } }`,
+		},
+		{
+			name:    "optional key",
+			keys:    []string{"id"},
+			schema:  `{ "id": { "type": "int", "required": false } }`,
+			invalid: []string{`field "id" is not required`},
+		},
+		{
+			name: "nullable parent of nested key",
+			keys: []string{"id", "profile.uid"},
+			schema: `{
+	"id": { "type": "int", "nullable": true },
+	"profile": { "type": "map", "nullable": true, "fields": { "uid": { "type": "string" } } }
+}`,
+			invalid: []string{`field "id" is nullable`, `field "profile" is nullable`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			r := CreateTestRuleWithSchema(t, tc.schema)
+			r.PrimaryKey = tc.keys
+
+			err := r.Validate()
+			if len(tc.invalid) == 0 {
+				assert.Nil(t, err)
+				assert.Nil(t, NewRuleManager().AddRule(r))
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrInvalidPrimaryKey)
+			for _, s := range tc.invalid {
+				assert.Contains(t, err.Error(), s)
+			}
+
+			assert.ErrorIs(t, NewRuleManager().AddRule(r), ErrInvalidPrimaryKey)
+		})
+	}
+}
+
+func TestRule_ValidateStrictPrimaryKey(t *testing.T) {
+
+	r := CreateTestRuleWithSchema(t, `{
+	"id": { "type": "int" },
+	"profile": { "type": "map", "fields": { "uid": { "type": "string", "notNull": true } } }
+}`)
+	r.PrimaryKey = []string{"id", "profile.uid"}
+	assert.Nil(t, r.Validate())
+
+	// Keys which are nullable by default are rejected once it's opted in
+	r.StrictPrimaryKey = true
+	err := r.Validate()
+	assert.ErrorIs(t, err, ErrInvalidPrimaryKey)
+	assert.ErrorContains(t, err, `field "id" is nullable by default`)
+	assert.ErrorContains(t, err, `field "profile" is nullable by default`)
+	assert.NotContains(t, err.Error(), `"profile.uid"`)
+
+	// Generated key is always filled
+	r = CreateTestRuleWithSchema(t, `{ "key": { "type": "string" } }`)
+	r.PrimaryKey = []string{"key"}
+	r.PrimaryKeyStrategy = PrimaryKeyStrategyUUID
+	r.StrictPrimaryKey = true
+	assert.Nil(t, r.Validate())
+}
+
+func TestLint(t *testing.T) {
+
+	r := CreateTestRuleWithSchema(t, `{
+	"id": { "type": "int" },
+	"createdAt": { "type": "string" },
+	"birth_date": { "type": "string" },
+	"tags": { "type": "array" },
+	"items": {
+		"type": "array",
+		"subtype": {
+			"type": "map",
+			"fields": {
+				"updated_at": { "type": "string" }
+			}
+		}
+	}
+}`)
+
+	assert.Equal(t, []LintWarning{
+		{Field: "birth_date", Severity: LintSeverityWarning, Message: "field is named like time but declared as string, consider \"time\""},
+		{Field: "createdAt", Severity: LintSeverityWarning, Message: "field is named like time but declared as string, consider \"time\""},
+		{Field: "id", Severity: LintSeverityInfo, Message: "integer primary key has no range constraint"},
+		{Field: "items.updated_at", Severity: LintSeverityWarning, Message: "field is named like time but declared as string, consider \"time\""},
+		{Field: "tags", Severity: LintSeverityWarning, Message: "array has no subtype, so elements are never checked"},
+	}, Lint(r))
+
+	// Clean schema
+	r = CreateTestRuleWithSchema(t, `{
+	"id": { "type": "uint32", "min": 1 },
+	"candidate": { "type": "string" },
+	"updateStatus": { "type": "string" },
+	"createdAt": { "type": "time" },
+	"tags": { "type": "array", "subtype": "string" }
+}`)
+
+	assert.Empty(t, Lint(r))
+}
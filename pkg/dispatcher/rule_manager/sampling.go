@@ -0,0 +1,76 @@
+package rule_manager
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket which allows bursts up to max per second.
+type rateLimiter struct {
+	max    float64
+	tokens float64
+	last   time.Time
+	mutex  sync.Mutex
+}
+
+func newRateLimiter(maxPerSecond int) *rateLimiter {
+	return &rateLimiter{
+		max:    float64(maxPerSecond),
+		tokens: float64(maxPerSecond),
+		last:   time.Now(),
+	}
+}
+
+func (rl *rateLimiter) Allow() bool {
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := time.Now()
+	rl.tokens = math.Min(rl.max, rl.tokens+now.Sub(rl.last).Seconds()*rl.max)
+	rl.last = now
+
+	if rl.tokens < 1 {
+		return false
+	}
+
+	rl.tokens--
+
+	return true
+}
+
+// AllowRate checks whether record is allowed by MaxPerSecond of rule.
+func (r *Rule) AllowRate() bool {
+
+	if r.limiter == nil {
+		return true
+	}
+
+	return r.limiter.Allow()
+}
+
+// Sample checks whether record with specific primary key is kept by SampleRate of rule. The same
+// key is always kept or dropped consistently.
+func (r *Rule) Sample(primaryKey []byte) bool {
+
+	if r.SampleRate <= 0 || r.SampleRate >= 1 || len(primaryKey) == 0 {
+		return true
+	}
+
+	h := fnv.New64a()
+	h.Write(primaryKey)
+
+	return float64(mix64(h.Sum64()))/float64(math.MaxUint64) < r.SampleRate
+}
+
+// mix64 spreads bits of hash since FNV is poorly distributed in high bits for short keys.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,88 @@
+package rule_manager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type schemaDescriptor struct {
+	descriptor  *structpb.Struct
+	fingerprint string
+	err         error
+}
+
+// SchemaDescriptor returns compact description of schema of rule, which maps names of fields to their types.
+// Maps with fields and arrays are described by objects with "type", "fields", "valueType" and "subtype".
+// Descriptor is generated once and shared, so it must not be modified.
+func (r *Rule) SchemaDescriptor() (*structpb.Struct, error) {
+	r.prepareSchemaDescriptor()
+	return r.schemaDescriptor.descriptor, r.schemaDescriptor.err
+}
+
+// SchemaFingerprint returns SHA-256 of schema descriptor in hex, which changes only if schema was changed.
+func (r *Rule) SchemaFingerprint() (string, error) {
+	r.prepareSchemaDescriptor()
+	return r.schemaDescriptor.fingerprint, r.schemaDescriptor.err
+}
+
+func (r *Rule) prepareSchemaDescriptor() {
+
+	r.descriptorOnce.Do(func() {
+
+		desc := describeFields(r.Fields)
+
+		// Keys of maps are sorted by encoder, so the same schema always has the same fingerprint
+		data, err := json.Marshal(desc)
+		if err != nil {
+			r.schemaDescriptor.err = err
+			return
+		}
+
+		sum := sha256.Sum256(data)
+		r.schemaDescriptor.fingerprint = hex.EncodeToString(sum[:])
+		r.schemaDescriptor.descriptor, r.schemaDescriptor.err = structpb.NewStruct(desc)
+	})
+}
+
+func describeFields(fields map[string]*FieldSchema) map[string]interface{} {
+
+	desc := make(map[string]interface{}, len(fields))
+	for name, fs := range fields {
+		desc[name] = fs.describe()
+	}
+
+	return desc
+}
+
+func (fs *FieldSchema) describe() interface{} {
+
+	switch fs.Type {
+	case "map":
+		desc := map[string]interface{}{
+			"type": fs.Type,
+		}
+
+		if fs.ValueType != nil {
+			desc["valueType"] = fs.ValueType.describe()
+		} else {
+			desc["fields"] = describeFields(fs.Fields)
+		}
+
+		return desc
+	case "array":
+		desc := map[string]interface{}{
+			"type": fs.Type,
+		}
+
+		if fs.Subtype != nil {
+			desc["subtype"] = fs.Subtype.describe()
+		}
+
+		return desc
+	}
+
+	return fs.Type
+}
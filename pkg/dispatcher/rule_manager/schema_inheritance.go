@@ -0,0 +1,110 @@
+package rule_manager
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrSchemaConflict = errors.New("schema conflict")
+
+// resolveBaseSchemas merges base schemas of rule into its SchemaConfig. Bases are merged in order, and fields of
+// rule override fields of bases. Fields of map are merged recursively, while changing type of field is an error.
+func (rm *RuleManager) resolveBaseSchemas(rule *Rule) error {
+
+	if len(rule.BaseSchemas) == 0 {
+		return nil
+	}
+
+	if rm.schemaRegistry == nil {
+		return fmt.Errorf("%w: %s", ErrSchemaRegistryNotAvailable, rule.BaseSchemas[0])
+	}
+
+	merged := make(map[string]interface{})
+	for _, ref := range rule.BaseSchemas {
+
+		config, err := rm.schemaRegistry.GetSchema(ref.Name, ref.Version)
+		if err != nil {
+			return fmt.Errorf("failed to resolve schema %s: %w", ref, err)
+		}
+
+		if config == nil {
+			return fmt.Errorf("%w: %s", ErrSchemaNotFound, ref)
+		}
+
+		merged, err = mergeSchemaConfigs(merged, config, "")
+		if err != nil {
+			return fmt.Errorf("failed to merge schema %s: %w", ref, err)
+		}
+	}
+
+	merged, err := mergeSchemaConfigs(merged, rule.SchemaConfig, "")
+	if err != nil {
+		return err
+	}
+
+	rule.SchemaConfig = merged
+
+	return nil
+}
+
+// mergeSchemaConfigs returns a new schema config with fields of overlay on top of base. Neither of them is modified.
+func mergeSchemaConfigs(base map[string]interface{}, overlay map[string]interface{}, prefix string) (map[string]interface{}, error) {
+
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for name, def := range base {
+		merged[name] = def
+	}
+
+	for name, def := range overlay {
+
+		baseDef, ok := merged[name].(map[string]interface{})
+		if !ok {
+			merged[name] = def
+			continue
+		}
+
+		def, err := mergeFieldConfigs(baseDef, def, prefix+name)
+		if err != nil {
+			return nil, err
+		}
+
+		merged[name] = def
+	}
+
+	return merged, nil
+}
+
+func mergeFieldConfigs(base map[string]interface{}, overlay interface{}, path string) (map[string]interface{}, error) {
+
+	def, ok := overlay.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFieldDefinition, path)
+	}
+
+	if base["type"] != def["type"] {
+		return nil, fmt.Errorf("%w: field \"%s\" is %v, but base schema defines %v", ErrSchemaConflict, path, def["type"], base["type"])
+	}
+
+	merged := make(map[string]interface{}, len(base)+len(def))
+	for k, v := range base {
+		merged[k] = v
+	}
+
+	for k, v := range def {
+		merged[k] = v
+	}
+
+	// Fields of map are merged rather than replaced
+	baseFields, baseOk := base["fields"].(map[string]interface{})
+	fields, ok := def["fields"].(map[string]interface{})
+	if baseOk && ok {
+		mergedFields, err := mergeSchemaConfigs(baseFields, fields, path+".")
+		if err != nil {
+			return nil, err
+		}
+
+		merged["fields"] = mergedFields
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,63 @@
+package rule_manager
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrSchemaRegistryNotAvailable = errors.New("schema registry is not available")
+	ErrSchemaNotFound             = errors.New("schema not found")
+)
+
+// SchemaRef refers to a shared schema in registry by name and version.
+type SchemaRef struct {
+	Name    string
+	Version string
+}
+
+func (ref SchemaRef) String() string {
+
+	if len(ref.Version) == 0 {
+		return ref.Name
+	}
+
+	return ref.Name + "@" + ref.Version
+}
+
+// SchemaRegistry provides schema configs which are shared by rules.
+type SchemaRegistry interface {
+	GetSchema(name string, version string) (map[string]interface{}, error)
+}
+
+func WithSchemaRegistry(registry SchemaRegistry) func(*RuleManager) {
+	return func(rm *RuleManager) {
+		rm.schemaRegistry = registry
+	}
+}
+
+// resolveSchemaRef replaces SchemaConfig of rule with schema from registry if rule refers to one.
+func (rm *RuleManager) resolveSchemaRef(rule *Rule) error {
+
+	if rule.SchemaRef == nil {
+		return nil
+	}
+
+	if rm.schemaRegistry == nil {
+		return fmt.Errorf("%w: %s", ErrSchemaRegistryNotAvailable, rule.SchemaRef)
+	}
+
+	config, err := rm.schemaRegistry.GetSchema(rule.SchemaRef.Name, rule.SchemaRef.Version)
+	if err != nil {
+		return fmt.Errorf("failed to resolve schema %s: %w", rule.SchemaRef, err)
+	}
+
+	if config == nil {
+		return fmt.Errorf("%w: %s", ErrSchemaNotFound, rule.SchemaRef)
+	}
+
+	// Rule never shares schema config with registry
+	rule.SchemaConfig = CloneSchemaConfig(config)
+
+	return nil
+}
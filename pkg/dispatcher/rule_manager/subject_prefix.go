@@ -0,0 +1,26 @@
+package rule_manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SubjectPrefixSettingKey is where stored product setting keeps subject prefix of product, as a key next to
+// fields of setting. Product settings of gravity-sdk have no field for it, and it never goes into schema.
+const SubjectPrefixSettingKey = "subjectPrefix"
+
+var ErrInvalidSubjectPrefix = errors.New("invalid subject prefix")
+
+// ValidateSubjectPrefix checks if prefix is made of legal tokens of NATS subject, such as "tenantA" or
+// "tenants.A". Wildcards and empty tokens are not allowed.
+func ValidateSubjectPrefix(prefix string) error {
+
+	for _, token := range strings.Split(prefix, ".") {
+		if len(token) == 0 || token == "*" || token == ">" || strings.ContainsAny(token, " \t\r\n*>") {
+			return fmt.Errorf("%w: \"%s\"", ErrInvalidSubjectPrefix, prefix)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package rule_manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidSubjectTemplate = errors.New("invalid subject template")
+	ErrSubjectFieldNotFound   = errors.New("subject field not found")
+	ErrInvalidSubjectToken    = errors.New("invalid subject token")
+)
+
+type subjectSegment struct {
+	value   string
+	isField bool
+}
+
+// SubjectTemplate renders subject with field values of record, such as "events.{country}.{eventName}".
+type SubjectTemplate struct {
+	segments []subjectSegment
+}
+
+func ParseSubjectTemplate(tmpl string) (*SubjectTemplate, error) {
+
+	st := &SubjectTemplate{
+		segments: make([]subjectSegment, 0),
+	}
+
+	rest := tmpl
+	for len(rest) > 0 {
+
+		start := strings.IndexByte(rest, '{')
+		if start == -1 {
+			st.segments = append(st.segments, subjectSegment{value: rest})
+			break
+		}
+
+		if start > 0 {
+			st.segments = append(st.segments, subjectSegment{value: rest[:start]})
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+		if end == -1 {
+			return nil, fmt.Errorf("%w: unclosed brace in \"%s\"", ErrInvalidSubjectTemplate, tmpl)
+		}
+
+		name := strings.TrimSpace(rest[start+1 : start+end])
+		if len(name) == 0 || strings.ContainsAny(name, "{") {
+			return nil, fmt.Errorf("%w: invalid field reference in \"%s\"", ErrInvalidSubjectTemplate, tmpl)
+		}
+
+		st.segments = append(st.segments, subjectSegment{value: name, isField: true})
+
+		rest = rest[start+end+1:]
+	}
+
+	// Literal parts must not break subject
+	for _, seg := range st.segments {
+		if !seg.isField && strings.ContainsAny(seg.value, " \t\r\n*>}") {
+			return nil, fmt.Errorf("%w: \"%s\"", ErrInvalidSubjectTemplate, tmpl)
+		}
+	}
+
+	return st, nil
+}
+
+// Fields returns paths of fields referenced by template.
+func (st *SubjectTemplate) Fields() []string {
+
+	fields := make([]string, 0)
+	for _, seg := range st.segments {
+		if seg.isField {
+			fields = append(fields, seg.value)
+		}
+	}
+
+	return fields
+}
+
+func (st *SubjectTemplate) Render(lookup func(path string) (interface{}, bool)) (string, error) {
+
+	var sb strings.Builder
+	for _, seg := range st.segments {
+
+		if !seg.isField {
+			sb.WriteString(seg.value)
+			continue
+		}
+
+		v, ok := lookup(seg.value)
+		if !ok || v == nil {
+			return "", fmt.Errorf("%w: %s", ErrSubjectFieldNotFound, seg.value)
+		}
+
+		token := fmt.Sprintf("%v", v)
+		if len(token) == 0 || strings.ContainsAny(token, " \t\r\n.*>") {
+			return "", fmt.Errorf("%w: field \"%s\" has value \"%s\"", ErrInvalidSubjectToken, seg.value, token)
+		}
+
+		sb.WriteString(token)
+	}
+
+	return sb.String(), nil
+}
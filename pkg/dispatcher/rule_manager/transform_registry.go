@@ -0,0 +1,189 @@
+package rule_manager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var ErrTransformNotFound = errors.New("transform not found")
+
+// TransformFunc turns value into another one. Transform of record is given the entire record, and it must
+// return a map.
+type TransformFunc func(value interface{}) (interface{}, error)
+
+// TransformRegistry provides transform functions by name, which are referred by "transform" of fields and
+// RecordTransform of rules.
+type TransformRegistry struct {
+	transforms map[string]TransformFunc
+	mutex      sync.RWMutex
+}
+
+func NewTransformRegistry() *TransformRegistry {
+	return &TransformRegistry{
+		transforms: make(map[string]TransformFunc),
+	}
+}
+
+// Register adds transform function with name, and replaces the one which was registered with the same name.
+// Rules which were added already keep using the old one.
+func (tr *TransformRegistry) Register(name string, fn TransformFunc) {
+
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	tr.transforms[name] = fn
+}
+
+func (tr *TransformRegistry) Get(name string) (TransformFunc, bool) {
+
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+
+	fn, ok := tr.transforms[name]
+
+	return fn, ok
+}
+
+func WithTransformRegistry(registry *TransformRegistry) func(*RuleManager) {
+	return func(rm *RuleManager) {
+		rm.transformRegistry = registry
+	}
+}
+
+type fieldTransform struct {
+	path string
+	name string
+	fn   TransformFunc
+}
+
+// resolveTransforms looks up transforms which are referred by rule, so unknown names are found before rule is used.
+func (rm *RuleManager) resolveTransforms(rule *Rule) error {
+
+	rule.fieldTransforms = nil
+	rule.recordTransform = nil
+
+	names, err := collectFieldTransforms(rule.Fields, "", nil)
+	if err != nil {
+		return err
+	}
+
+	resolve := func(name string) (TransformFunc, error) {
+
+		if rm.transformRegistry == nil {
+			return nil, fmt.Errorf("%w: %s", ErrTransformNotFound, name)
+		}
+
+		fn, ok := rm.transformRegistry.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrTransformNotFound, name)
+		}
+
+		return fn, nil
+	}
+
+	for _, ft := range names {
+		ft.fn, err = resolve(ft.name)
+		if err != nil {
+			return fmt.Errorf("field \"%s\": %w", ft.path, err)
+		}
+	}
+
+	if len(rule.RecordTransform) > 0 {
+		rule.recordTransform, err = resolve(rule.RecordTransform)
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].path < names[j].path
+	})
+
+	rule.fieldTransforms = names
+
+	return nil
+}
+
+func collectFieldTransforms(fields map[string]*FieldSchema, prefix string, transforms []*fieldTransform) ([]*fieldTransform, error) {
+
+	for name, fs := range fields {
+
+		path := prefix + name
+
+		if fs.Type == "map" {
+			var err error
+			transforms, err = collectFieldTransforms(fs.Fields, path+".", transforms)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		v, ok := fs.Props["transform"]
+		if !ok {
+			continue
+		}
+
+		s, ok := v.(string)
+		if !ok || len(s) == 0 {
+			return nil, fmt.Errorf("%w: field \"%s\": transform must be a name", ErrInvalidFieldDefinition, path)
+		}
+
+		transforms = append(transforms, &fieldTransform{
+			path: path,
+			name: s,
+		})
+	}
+
+	return transforms, nil
+}
+
+// applyTransforms runs transforms of fields and then transform of record, so the latter sees transformed fields.
+func (r *Rule) applyTransforms(results []map[string]interface{}) ([]map[string]interface{}, error) {
+
+	if len(r.fieldTransforms) == 0 && r.recordTransform == nil {
+		return results, nil
+	}
+
+	for i, result := range results {
+
+		for _, ft := range r.fieldTransforms {
+
+			parent, name := lookupParent(result, ft.path)
+			if parent == nil {
+				continue
+			}
+
+			v, ok := parent[name]
+			if !ok {
+				continue
+			}
+
+			v, err := ft.fn(v)
+			if err != nil {
+				return nil, fmt.Errorf("transform \"%s\" failed for field \"%s\": %w", ft.name, ft.path, err)
+			}
+
+			parent[name] = v
+		}
+
+		if r.recordTransform == nil {
+			continue
+		}
+
+		v, err := r.recordTransform(result)
+		if err != nil {
+			return nil, fmt.Errorf("transform \"%s\" failed: %w", r.RecordTransform, err)
+		}
+
+		record, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("transform \"%s\" failed: record is %T rather than map", r.RecordTransform, v)
+		}
+
+		results[i] = record
+	}
+
+	return results, nil
+}
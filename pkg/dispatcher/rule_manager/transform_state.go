@@ -0,0 +1,132 @@
+package rule_manager
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// interruptCheckInterval is number of visited values between checks of cancellation.
+const interruptCheckInterval = 1024
+
+// transformState is shared by steps of transforming a payload. It keeps errors of fields for CollectAll, and
+// aborts walking through payload once context is done. Nil state fails fast and is never interrupted.
+type transformState struct {
+	ctx   context.Context
+	mode  ErrorCollectionMode
+	errs  []error
+	steps int
+
+	// coerceStrings allows strings for numeric and boolean fields
+	coerceStrings bool
+
+	// strictStrings rejects numbers and booleans for string fields
+	strictStrings bool
+}
+
+// collect reports whether processing goes on after err, which is kept in that case. Cancellation always stops.
+func (ts *transformState) collect(err error) bool {
+
+	if ts == nil || ts.mode != CollectAll {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	ts.errs = append(ts.errs, err)
+
+	return true
+}
+
+// interrupted returns error of context if it's done. Context is checked periodically, so it's cheap
+// enough for every visited value.
+func (ts *transformState) interrupted() error {
+
+	if ts == nil || ts.ctx == nil {
+		return nil
+	}
+
+	ts.steps++
+	if ts.steps%interruptCheckInterval != 0 {
+		return nil
+	}
+
+	return ts.ctx.Err()
+}
+
+func (ts *transformState) err() error {
+
+	if ts == nil || len(ts.errs) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(ts.errs, func(i, j int) bool {
+		return ts.errs[i].Error() < ts.errs[j].Error()
+	})
+
+	return &FieldErrors{
+		Errors: ts.errs,
+	}
+}
+
+// checkString rejects string for numeric and boolean field unless strings are coerced, in which case
+// string must be able to be parsed.
+func (ts *transformState) checkString(fs *FieldSchema, path string, value interface{}) error {
+
+	s, ok := value.(string)
+	if ts == nil || !ok {
+		return nil
+	}
+
+	switch fs.BaseType() {
+	case "int", "uint", "float", "bool":
+	default:
+		return nil
+	}
+
+	if ts.coerceStrings && fs.checkElement(s) {
+		return nil
+	}
+
+	return &CoercionError{
+		Field: path,
+		From:  "string",
+		To:    fs.Type,
+		Value: s,
+	}
+}
+
+// checkValue verifies value of field before normalizing, which would turn mismatched values into zero values
+// or formatted strings silently.
+func (ts *transformState) checkValue(fs *FieldSchema, path string, value interface{}) error {
+
+	err := ts.checkString(fs, path, value)
+	if err != nil {
+		return err
+	}
+
+	if ts != nil && ts.strictStrings && fs.BaseType() == "string" {
+		switch value.(type) {
+		case bool, float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return &CoercionError{
+				Field: path,
+				From:  typeNameOf(value),
+				To:    fs.Type,
+				Value: value,
+			}
+		}
+	}
+
+	if !fs.checkElement(value) {
+		return &CoercionError{
+			Field: path,
+			From:  typeNameOf(value),
+			To:    fs.Type,
+			Value: value,
+		}
+	}
+
+	return nil
+}
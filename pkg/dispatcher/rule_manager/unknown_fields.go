@@ -0,0 +1,84 @@
+package rule_manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Policies for fields which are not defined in schema. UnknownFieldsIgnore is the default.
+const (
+	UnknownFieldsIgnore      = "ignore"
+	UnknownFieldsError       = "error"
+	UnknownFieldsPassthrough = "passthrough"
+)
+
+var ErrInvalidUnknownFieldsPolicy = errors.New("invalid unknown fields policy")
+
+func (r *Rule) prepareUnknownFields() error {
+
+	switch r.UnknownFields {
+	case "":
+		r.UnknownFields = UnknownFieldsIgnore
+	case UnknownFieldsIgnore, UnknownFieldsError, UnknownFieldsPassthrough:
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidUnknownFieldsPolicy, r.UnknownFields)
+	}
+
+	return nil
+}
+
+// findUnknownFields returns paths of fields in data which are not defined in schema.
+func findUnknownFields(fields map[string]*FieldSchema, prefix string, data map[string]interface{}) []string {
+
+	unknown := make([]string, 0)
+	for k, v := range data {
+
+		// Skip internal fields
+		if strings.HasPrefix(k, "$") {
+			continue
+		}
+
+		fs := LookupFieldSchema(fields, k)
+		if fs == nil {
+			unknown = append(unknown, prefix+k)
+			continue
+		}
+
+		if m, ok := v.(map[string]interface{}); ok && fs.Type == "map" && fs.ValueType == nil {
+			unknown = append(unknown, findUnknownFields(fs.Fields, prefix+k+".", m)...)
+		}
+	}
+
+	return unknown
+}
+
+// checkUnknownFields applies policy to payload. Unknown fields at the top level are returned for passthrough.
+func (r *Rule) checkUnknownFields(data map[string]interface{}) (map[string]interface{}, error) {
+
+	// Everything is unknown without schema
+	if len(r.Fields) == 0 || r.UnknownFields == UnknownFieldsIgnore {
+		return nil, nil
+	}
+
+	unknown := findUnknownFields(r.Fields, "", data)
+	if len(unknown) == 0 {
+		return nil, nil
+	}
+
+	if r.UnknownFields == UnknownFieldsError {
+		return nil, &SchemaValidationError{
+			Field:  unknown[0],
+			Reason: "field is not defined in schema",
+		}
+	}
+
+	passthrough := make(map[string]interface{})
+	for _, path := range unknown {
+		if v, ok := data[path]; ok {
+			passthrough[path] = v
+		}
+	}
+
+	return passthrough, nil
+}
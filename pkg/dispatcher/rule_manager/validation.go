@@ -0,0 +1,67 @@
+package rule_manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidPrimaryKey = errors.New("invalid primary key")
+
+// Validate checks rule for settings which are allowed by schema but never work. Fields of primary key, and maps
+// which contain them, must be neither nullable nor optional, as record without key is not able to be identified.
+// Fields are nullable by default, and they are rejected for it only if StrictPrimaryKey is set. Problems are
+// reported at once rather than the first one.
+func (r *Rule) Validate() error {
+
+	fields := r.Fields
+	if fields == nil {
+		var err error
+		fields, err = ParseFieldSchemas(r.SchemaConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Rule without schema accepts anything
+	if len(fields) == 0 {
+		return nil
+	}
+
+	errs := make([]error, 0)
+	for _, key := range r.PrimaryKey {
+
+		// Generated key fills records which come without it
+		generated := key == r.generatedKeyField()
+
+		// Parents are checked from the top, and keys which are not defined are reported when they are missing
+		// from records instead
+		tokens := strings.Split(key, ".")
+		for i := range tokens {
+
+			path := strings.Join(tokens[:i+1], ".")
+			fs := LookupFieldSchema(fields, path)
+			if fs == nil {
+				continue
+			}
+
+			if v, ok := fs.Props["nullable"].(bool); ok && v {
+				errs = append(errs, fmt.Errorf("%w: field \"%s\" is nullable", ErrInvalidPrimaryKey, path))
+			} else if r.StrictPrimaryKey && !generated && !fs.isNotNull() {
+				errs = append(errs, fmt.Errorf("%w: field \"%s\" is nullable by default", ErrInvalidPrimaryKey, path))
+			}
+
+			if v, ok := fs.Props["required"].(bool); ok && !v {
+				errs = append(errs, fmt.Errorf("%w: field \"%s\" is not required", ErrInvalidPrimaryKey, path))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isNotNull reports whether field was declared with "notNull", as fields accept null by default.
+func (fs *FieldSchema) isNotNull() bool {
+	notNull, _ := fs.Props["notNull"].(bool)
+	return notNull
+}
@@ -0,0 +1,84 @@
+package dispatcher
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+)
+
+// RuleStats is a snapshot of counters of rule.
+type RuleStats struct {
+	Processed uint64
+	Filtered  uint64
+	Errored   uint64
+	Paused    uint64
+}
+
+type ruleCounters struct {
+	product   string
+	event     string
+	processed atomic.Uint64
+	filtered  atomic.Uint64
+	errored   atomic.Uint64
+	paused    atomic.Uint64
+}
+
+type ruleStats struct {
+	counters sync.Map
+}
+
+func (rs *ruleStats) get(rule *rule_manager.Rule) *ruleCounters {
+
+	if c, ok := rs.counters.Load(rule.ID); ok {
+		return c.(*ruleCounters)
+	}
+
+	c, _ := rs.counters.LoadOrStore(rule.ID, &ruleCounters{
+		product: rule.Product,
+		event:   rule.Event,
+	})
+
+	return c.(*ruleCounters)
+}
+
+// count updates counters of rule by result of message.
+func (rs *ruleStats) count(msg *Message) {
+
+	if msg.Rule == nil {
+		return
+	}
+
+	c := rs.get(msg.Rule)
+
+	switch {
+	case msg.Error != nil:
+		c.errored.Add(1)
+	case msg.Dropped == DropReasonPaused:
+		c.paused.Add(1)
+	case msg.Ignore || msg.ProductEvent == nil:
+		c.filtered.Add(1)
+	default:
+		c.processed.Add(1)
+	}
+}
+
+// RuleStats returns counters of rules keyed by rule ID. Messages which were sampled, rate limited or
+// had no result are counted as filtered, and messages of disabled products are counted as paused.
+func (p *Processor) RuleStats() map[string]RuleStats {
+
+	stats := make(map[string]RuleStats)
+	p.ruleStats.counters.Range(func(key, value interface{}) bool {
+		c := value.(*ruleCounters)
+		stats[key.(string)] = RuleStats{
+			Processed: c.processed.Load(),
+			Filtered:  c.filtered.Load(),
+			Errored:   c.errored.Load(),
+			Paused:    c.paused.Load(),
+		}
+
+		return true
+	})
+
+	return stats
+}
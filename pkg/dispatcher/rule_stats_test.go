@@ -0,0 +1,57 @@
+package dispatcher
+
+import (
+	"testing"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_RuleStats(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	created := CreateTestRule()
+
+	// Primary key never exists
+	updated := CreateTestRule()
+	updated.Event = "dataUpdated"
+	updated.PrimaryKey = []string{"uid"}
+
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(created)
+	rm.AddRule(updated)
+
+	done := make(chan *Message, 64)
+	p := NewProcessor(
+		WithRuleManager(rm),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	events := []string{"dataCreated", "dataUpdated", "dataCreated", "dataUpdated", "dataUpdated", "unknown"}
+	for _, event := range events {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      event,
+			RawPayload: []byte(`{"id":101,"name":"fred"}`),
+		})
+
+		msg := NewMessage()
+		msg.Event = event
+		msg.Raw = raw
+
+		p.Push(msg)
+	}
+
+	for range events {
+		<-done
+	}
+
+	stats := p.RuleStats()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, RuleStats{Processed: 2}, stats[created.ID])
+	assert.Equal(t, RuleStats{Errored: 3}, stats[updated.ID])
+}
@@ -0,0 +1,81 @@
+package dispatcher
+
+import (
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Keys of schema in meta of emitted records.
+const (
+	SchemaMetaKey            = "schema"
+	SchemaFingerprintMetaKey = "schemaFingerprint"
+	SchemaRefMetaKey         = "schemaRef"
+)
+
+type SelfDescribingMode int
+
+const (
+	SelfDescribingOff SelfDescribingMode = iota
+
+	// SelfDescribingSchema attaches descriptor of schema along with its fingerprint.
+	SelfDescribingSchema
+
+	// SelfDescribingFingerprint attaches fingerprint of schema only, and consumers look up schema by reference
+	// in registry.
+	SelfDescribingFingerprint
+)
+
+// WithSelfDescribing attaches schema of rule to meta of emitted records, so consumers are able to decode
+// records without knowing schema in advance. Reference of schema in registry is attached as well if rule has one.
+func WithSelfDescribing(enabled bool) func(*Processor) {
+	return func(p *Processor) {
+		p.selfDescribing = SelfDescribingOff
+		if enabled {
+			p.selfDescribing = SelfDescribingSchema
+		}
+	}
+}
+
+// WithSelfDescribingMode is like WithSelfDescribing, but mode decides whether the entire schema or only its
+// fingerprint is attached.
+func WithSelfDescribingMode(mode SelfDescribingMode) func(*Processor) {
+	return func(p *Processor) {
+		p.selfDescribing = mode
+	}
+}
+
+func describeRecord(rule *rule_manager.Rule, mode SelfDescribingMode, r *record_type.Record) error {
+
+	fingerprint, err := rule.SchemaFingerprint()
+	if err != nil {
+		return err
+	}
+
+	if r.Meta == nil {
+		r.Meta = &structpb.Struct{}
+	}
+
+	if r.Meta.Fields == nil {
+		r.Meta.Fields = make(map[string]*structpb.Value)
+	}
+
+	r.Meta.Fields[SchemaFingerprintMetaKey] = structpb.NewStringValue(fingerprint)
+
+	if rule.SchemaRef != nil {
+		r.Meta.Fields[SchemaRefMetaKey] = structpb.NewStringValue(rule.SchemaRef.String())
+	}
+
+	if mode == SelfDescribingFingerprint {
+		return nil
+	}
+
+	desc, err := rule.SchemaDescriptor()
+	if err != nil {
+		return err
+	}
+
+	r.Meta.Fields[SchemaMetaKey] = structpb.NewStructValue(desc)
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package dispatcher
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// SequenceMetaKey is key of sequence in meta of emitted records.
+const SequenceMetaKey = "sequence"
+
+const DefaultSequenceMaxRetries = 16
+
+var ErrSequenceConflict = errors.New("sequence was updated concurrently")
+
+// SequenceSource provides dense sequence numbers for each product. Numbers must be persisted, so that
+// sequence continues after restarting.
+type SequenceSource interface {
+	Next(product string) (uint64, error)
+}
+
+// WithSequenceSource enables stamping emitted events with per-product sequence numbers. Numbers are assigned
+// in order of output, and only to events which are emitted, so consumers are able to detect loss by gaps.
+func WithSequenceSource(source SequenceSource) func(*Processor) {
+	return func(p *Processor) {
+		p.sequenceSource = source
+	}
+}
+
+func (p *Processor) assignSequence(msg *Message) error {
+
+	if msg.Ignore || msg.Error != nil || msg.ProductEvent == nil {
+		return nil
+	}
+
+	r, err := msg.getRecord()
+	if err != nil {
+		return err
+	}
+
+	seq, err := p.sequenceSource.Next(msg.ProductEvent.Table)
+	if err != nil {
+		return err
+	}
+
+	if r.Meta == nil {
+		r.Meta = &structpb.Struct{}
+	}
+
+	if r.Meta.Fields == nil {
+		r.Meta.Fields = make(map[string]*structpb.Value)
+	}
+
+	r.Meta.Fields[SequenceMetaKey] = structpb.NewNumberValue(float64(seq))
+	msg.Sequence = seq
+
+	return msg.writeRecord(r)
+}
+
+func (p *Processor) sequence(msg *Message) {
+
+	err := p.assignSequence(msg)
+	if err != nil {
+		msg.Logger().Error("Failed to assign sequence",
+			zap.String("event", msg.Event),
+			zap.Error(err),
+		)
+
+		msg.Error = err
+		msg.Ignore = true
+	}
+}
+
+// KVSequenceSource keeps the last sequence of each product in JetStream key-value store. Values are updated
+// with revision, so that sequence never goes backward even if sources are racing for the same product.
+type KVSequenceSource struct {
+	kv         nats.KeyValue
+	maxRetries int
+	mutex      sync.Mutex
+}
+
+func NewKVSequenceSource(kv nats.KeyValue) *KVSequenceSource {
+	return &KVSequenceSource{
+		kv:         kv,
+		maxRetries: DefaultSequenceMaxRetries,
+	}
+}
+
+func (s *KVSequenceSource) Next(product string) (uint64, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := 0; i < s.maxRetries; i++ {
+
+		entry, err := s.kv.Get(product)
+		if errors.Is(err, nats.ErrKeyNotFound) {
+
+			// First event of product
+			_, err = s.kv.Create(product, []byte("1"))
+			if err == nil {
+				return 1, nil
+			}
+
+			if errors.Is(err, nats.ErrKeyExists) {
+				continue
+			}
+
+			return 0, err
+		} else if err != nil {
+			return 0, err
+		}
+
+		last, err := strconv.ParseUint(string(entry.Value()), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		next := last + 1
+		_, err = s.kv.Update(product, []byte(strconv.FormatUint(next, 10)), entry.Revision())
+		if err == nil {
+			return next, nil
+		}
+
+		if !isRevisionConflict(err) {
+			return 0, err
+		}
+	}
+
+	return 0, ErrSequenceConflict
+}
+
+func isRevisionConflict(err error) bool {
+
+	var apiErr *nats.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode == nats.JSErrCodeStreamWrongLastSequence
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+package dispatcher
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func CreateTestSequenceStore(t *testing.T) nats.KeyValue {
+
+	js := CreateTestJetStream(t)
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{
+		Bucket: "SEQUENCES",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return kv
+}
+
+func TestProcessor_Sequence(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	kv := CreateTestSequenceStore(t)
+
+	emit := func(p *Processor, count int) []uint64 {
+
+		seqs := make([]uint64, 0, count)
+		for i := 0; i < count; i++ {
+
+			raw, _ := json.Marshal(MessageRawData{
+				Event:      "dataCreated",
+				RawPayload: []byte(`{"id":101,"name":"fred"}`),
+			})
+
+			msg := CreateTestMessage()
+			msg.Raw = raw
+
+			result, err := p.Process(msg)
+			if !assert.Nil(t, err) {
+				return nil
+			}
+
+			r, err := result.ProductEvent.GetContent()
+			if assert.Nil(t, err) {
+				assert.Equal(t, float64(result.Sequence), r.Meta.AsMap()[SequenceMetaKey])
+			}
+
+			seqs = append(seqs, result.Sequence)
+		}
+
+		return seqs
+	}
+
+	p := NewProcessor(WithSequenceSource(NewKVSequenceSource(kv)))
+	assert.Equal(t, []uint64{1, 2, 3}, emit(p, 3))
+	p.Close()
+
+	// Restarted
+	p = NewProcessor(WithSequenceSource(NewKVSequenceSource(kv)))
+	defer p.Close()
+
+	assert.Equal(t, []uint64{4, 5}, emit(p, 2))
+}
+
+func TestKVSequenceSource_Concurrent(t *testing.T) {
+
+	kv := CreateTestSequenceStore(t)
+
+	var wg sync.WaitGroup
+	seqs := make(chan uint64, 40)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Each source stands for a dispatcher instance
+			source := NewKVSequenceSource(kv)
+			for j := 0; j < 10; j++ {
+				seq, err := source.Next("TestDataProduct")
+				if err != nil {
+					// Gave up on heavy contention, which never takes a number
+					assert.ErrorIs(t, err, ErrSequenceConflict)
+					continue
+				}
+
+				seqs <- seq
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(seqs)
+
+	seen := make(map[uint64]bool)
+	for seq := range seqs {
+		assert.False(t, seen[seq])
+		seen[seq] = true
+	}
+
+	// Numbers are dense
+	assert.NotEmpty(t, seen)
+	for i := uint64(1); i <= uint64(len(seen)); i++ {
+		assert.True(t, seen[i])
+	}
+}
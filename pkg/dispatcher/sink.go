@@ -0,0 +1,87 @@
+package dispatcher
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+var ErrSinkFailed = errors.New("failed to write to sink")
+
+// Sink receives messages which were processed successfully, before they are passed to output handler.
+type Sink interface {
+	Write(msg *Message) error
+}
+
+// WithSink writes processed messages to sink, and sinks are written one by one in given order. Failure of sink
+// is reported to error handler, which is where messages are retried or sent to dead letter queue, and message is
+// marked as ignored without being written to the rest of sinks.
+func WithSink(sink Sink) func(*Processor) {
+	return func(p *Processor) {
+		p.sinks = append(p.sinks, sink)
+	}
+}
+
+func (p *Processor) writeSink(msg *Message) {
+
+	if msg.Ignore || msg.Error != nil || msg.OutputMsg == nil {
+		return
+	}
+
+	for _, sink := range p.sinks {
+		err := sink.Write(msg)
+		if err != nil {
+			msg.Logger().Error("Failed to write to sink",
+				zap.String("event", msg.Event),
+				zap.Error(err),
+			)
+			msg.Error = fmt.Errorf("%w: %w", ErrSinkFailed, err)
+			msg.Ignore = true
+			return
+		}
+	}
+}
+
+// SubjectResolver decides subject which message is published to.
+type SubjectResolver func(msg *Message) string
+
+// JetStreamSink publishes encoded product events to JetStream, and waits for acknowledgement of every message.
+type JetStreamSink struct {
+	js      nats.JetStreamContext
+	resolve SubjectResolver
+}
+
+// NewJetStreamSink creates sink publishing to js. Subject computed by processor is used if resolver is nil, which
+// is rendered by output subject template of rule, or subject of product stream if rule has no template.
+func NewJetStreamSink(js nats.JetStreamContext, resolver SubjectResolver) *JetStreamSink {
+	return &JetStreamSink{
+		js:      js,
+		resolve: resolver,
+	}
+}
+
+func (s *JetStreamSink) Write(msg *Message) error {
+
+	subject := msg.OutputMsg.Subject
+	if s.resolve != nil {
+		subject = s.resolve(msg)
+	}
+
+	m := nats.NewMsg(subject)
+	m.Data = msg.RawProductEvent
+	for k, v := range msg.OutputMsg.Header {
+		m.Header[k] = v
+	}
+
+	// Duplicates are dropped by stream with message ID
+	var opts []nats.PubOpt
+	if len(msg.ID) > 0 {
+		opts = append(opts, nats.MsgId(msg.ID))
+	}
+
+	_, err := s.js.PublishMsg(m, opts...)
+
+	return err
+}
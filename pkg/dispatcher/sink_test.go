@@ -0,0 +1,262 @@
+package dispatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	gravity_sdk_types_product_event "github.com/BrobridgeOrg/gravity-sdk/v2/types/product_event"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_JetStreamSink(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	js := CreateTestJetStream(t)
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "GVT_default_DP_TestDataProduct",
+		Subjects: []string{"$GVT.default.DP.TestDataProduct.*.EVENT.>"},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	sub, err := js.SubscribeSync("$GVT.default.DP.TestDataProduct.*.EVENT.>")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(CreateTestRule())
+
+	done := make(chan *Message, 1)
+	p := NewProcessor(
+		WithDomain("default"),
+		WithRuleManager(rm),
+		WithSink(NewJetStreamSink(js, nil)),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"name":"fred"}`),
+	})
+
+	msg := NewMessage()
+	msg.Event = "dataCreated"
+	msg.Raw = raw
+	p.Push(msg)
+
+	result := <-done
+	if !assert.Nil(t, result.Error) {
+		return
+	}
+
+	m, err := sub.NextMsg(5 * time.Second)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, result.OutputMsg.Subject, m.Subject)
+
+	var pe gravity_sdk_types_product_event.ProductEvent
+	err = gravity_sdk_types_product_event.Unmarshal(m.Data, &pe)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "dataCreated", pe.EventName)
+		assert.Equal(t, "TestDataProduct", pe.Table)
+	}
+}
+
+func TestProcessor_JetStreamSinkOutputSubject(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	js := CreateTestJetStream(t)
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "TENANT_EVENTS",
+		Subjects: []string{"tenantA.events.>"},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	sub, err := js.SubscribeSync("tenantA.events.>")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	r := CreateTestRule()
+	r.OutputSubjectTemplate = "events.{name}"
+
+	rm := rule_manager.NewRuleManager()
+	if !assert.Nil(t, rm.AddRule(r)) {
+		return
+	}
+
+	done := make(chan *Message, 1)
+	p := NewProcessor(
+		WithDomain("default"),
+		WithRuleManager(rm),
+		WithSubjectPrefix("tenantA"),
+		WithSink(NewJetStreamSink(js, nil)),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"name":"fred"}`),
+	})
+
+	msg := NewMessage()
+	msg.Event = "dataCreated"
+	msg.Raw = raw
+	p.Push(msg)
+
+	result := <-done
+	if !assert.Nil(t, result.Error) {
+		return
+	}
+
+	assert.Equal(t, "events.fred", result.OutputSubject)
+	assert.Equal(t, "tenantA.events.fred", result.OutputMsg.Subject)
+
+	// Published to rendered subject with prefix
+	m, err := sub.NextMsg(5 * time.Second)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "tenantA.events.fred", m.Subject)
+	}
+}
+
+func TestProcessor_SinkFailure(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	// No stream is listening on subject
+	js := CreateTestJetStream(t)
+
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(CreateTestRule())
+
+	errs := make(chan error, 1)
+	done := make(chan *Message, 1)
+	p := NewProcessor(
+		WithRuleManager(rm),
+		WithSink(NewJetStreamSink(js, func(msg *Message) string {
+			return "unknown." + msg.Event
+		})),
+		WithErrorHandler(func(msg *Message, err error) {
+			errs <- err
+		}),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"name":"fred"}`),
+	})
+
+	msg := NewMessage()
+	msg.Event = "dataCreated"
+	msg.Raw = raw
+	p.Push(msg)
+
+	assert.ErrorIs(t, <-errs, ErrSinkFailed)
+	assert.True(t, (<-done).Ignore)
+}
+
+// testSink keeps names of fields of records which were written
+type testSink struct {
+	fields chan []string
+}
+
+func (s *testSink) Write(msg *Message) error {
+
+	var pe gravity_sdk_types_product_event.ProductEvent
+	err := gravity_sdk_types_product_event.Unmarshal(msg.OutputMsg.Data, &pe)
+	if err != nil {
+		return err
+	}
+
+	r, err := pe.GetContent()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0)
+	for _, field := range r.Payload.Map.Fields {
+		names = append(names, field.Name)
+	}
+
+	s.fields <- names
+
+	return nil
+}
+
+func TestProcessor_Projection(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	rm := rule_manager.NewRuleManager()
+	rm.AddRule(CreateTestRule())
+
+	full := &testSink{fields: make(chan []string, 1)}
+	public := &testSink{fields: make(chan []string, 1)}
+	limited := &testSink{fields: make(chan []string, 1)}
+
+	done := make(chan *Message, 1)
+	p := NewProcessor(
+		WithRuleManager(rm),
+		WithSink(full),
+		WithProjection(public, Projection{
+			Deny: []string{"id", "gender"},
+		}),
+		WithProjection(limited, Projection{
+			Allow: []string{"name"},
+		}),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	raw, _ := json.Marshal(MessageRawData{
+		Event:      "dataCreated",
+		RawPayload: []byte(`{"id":101,"name":"fred","gender":"m","tags":["a"]}`),
+	})
+
+	msg := NewMessage()
+	msg.Event = "dataCreated"
+	msg.Raw = raw
+	p.Push(msg)
+
+	result := <-done
+	if !assert.Nil(t, result.Error) {
+		return
+	}
+
+	assert.Equal(t, []string{"id", "gender", "name", "tags"}, <-full.fields)
+
+	// Primary key is always kept
+	assert.Equal(t, []string{"id", "name", "tags"}, <-public.fields)
+	assert.Equal(t, []string{"id", "name"}, <-limited.fields)
+
+	// Message of output handler is never projected
+	r, err := result.ProductEvent.GetContent()
+	if assert.Nil(t, err) {
+		assert.Len(t, r.Payload.Map.Fields, 4)
+	}
+}
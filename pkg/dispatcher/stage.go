@@ -0,0 +1,48 @@
+package dispatcher
+
+import (
+	"go.uber.org/zap"
+)
+
+// Stage turns emitted message into the one for the next stage. Stage which returns nil passes the same
+// message to the next stage.
+type Stage func(*Message) (*Message, error)
+
+// WithStage appends stage to pipeline of processor. Stages run in order for messages which are pushed, after
+// sequence was assigned and before sinks and output handler, so output handler receives message of the last
+// stage. Error of stage skips the rest of stages, and message is passed to error handler.
+func WithStage(stage Stage) func(*Processor) {
+	return func(p *Processor) {
+		p.stages = append(p.stages, stage)
+	}
+}
+
+func (p *Processor) runStages(msg *Message) *Message {
+
+	if msg.Ignore || msg.Error != nil {
+		return msg
+	}
+
+	for i, stage := range p.stages {
+
+		next, err := stage(msg)
+		if err != nil {
+			msg.Logger().Error("Failed to run stage",
+				zap.String("event", msg.Event),
+				zap.Int("stage", i),
+				zap.Error(err),
+			)
+
+			msg.Error = err
+			msg.Ignore = true
+
+			return msg
+		}
+
+		if next != nil {
+			msg = next
+		}
+	}
+
+	return msg
+}
@@ -0,0 +1,109 @@
+package dispatcher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProcessor_Stages(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	names := make(chan string, 2)
+	done := make(chan *Message, 2)
+
+	rename := func(msg *Message) (*Message, error) {
+
+		r, err := msg.ProductEvent.GetContent()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, field := range r.Payload.Map.Fields {
+			if field.Name == "name" {
+				field.Name = "fullName"
+			}
+		}
+
+		msg.ProductEvent.SetContent(r)
+
+		return msg, nil
+	}
+
+	check := func(msg *Message) (*Message, error) {
+
+		r, err := msg.ProductEvent.GetContent()
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := GetFieldValue(r, "fullName")
+		if err != nil {
+			return nil, err
+		}
+
+		names <- v.(string)
+
+		return nil, nil
+	}
+
+	p := NewProcessor(
+		WithStage(rename),
+		WithStage(check),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	pushTestMessages(p, 1)
+
+	msg := <-done
+	assert.Nil(t, msg.Error)
+	assert.Equal(t, "fred", <-names)
+
+	r, err := msg.ProductEvent.GetContent()
+	if assert.Nil(t, err) {
+		_, err = GetFieldValue(r, "name")
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProcessor_StageFailure(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	errStage := errors.New("stage failed")
+
+	errs := make(chan error, 1)
+	done := make(chan *Message, 1)
+	calls := 0
+
+	p := NewProcessor(
+		WithStage(func(msg *Message) (*Message, error) {
+			return nil, errStage
+		}),
+		WithStage(func(msg *Message) (*Message, error) {
+			calls++
+			return msg, nil
+		}),
+		WithErrorHandler(func(msg *Message, err error) {
+			errs <- err
+		}),
+		WithOutputHandler(func(msg *Message) {
+			done <- msg
+		}),
+	)
+	defer p.Close()
+
+	pushTestMessages(p, 1)
+
+	msg := <-done
+	assert.True(t, msg.Ignore)
+	assert.ErrorIs(t, msg.Error, errStage)
+	assert.ErrorIs(t, <-errs, errStage)
+	assert.Equal(t, 0, calls)
+}
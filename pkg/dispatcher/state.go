@@ -0,0 +1,106 @@
+package dispatcher
+
+import (
+	gravity_sdk_types_product_event "github.com/BrobridgeOrg/gravity-sdk/v2/types/product_event"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+)
+
+// StateProvider reports whether record of primary key has been seen before, so that processor is able to
+// tell inserts from updates.
+type StateProvider interface {
+	Exists(product string, primaryKey []byte) (bool, error)
+}
+
+// RecordStateProvider is StateProvider which keeps previous records as well. Get returns nil if record
+// of primary key has not been seen before.
+type RecordStateProvider interface {
+	StateProvider
+	Get(product string, primaryKey []byte) (*record_type.Record, error)
+}
+
+// ResettableStateProvider is StateProvider which keeps state derived from processed events, such as records
+// compared by WithSuppressUnchanged. Reset is called by ResetState to forget it.
+type ResettableStateProvider interface {
+	StateProvider
+	Reset() error
+}
+
+// WithStateProvider enables classifying events as insert or update by previous state of primary key.
+// Without provider, method declared by rule is kept and sinks are expected to treat events as upserts.
+func WithStateProvider(sp StateProvider) func(*Processor) {
+	return func(p *Processor) {
+		p.stateProvider = sp
+	}
+}
+
+func (p *Processor) classify(pe *gravity_sdk_types_product_event.ProductEvent) error {
+
+	switch pe.Method {
+	case gravity_sdk_types_product_event.Method_DELETE,
+		gravity_sdk_types_product_event.Method_TRUNCATE:
+		return nil
+	}
+
+	// Partial update without primary key
+	if len(pe.PrimaryKey) == 0 {
+		return nil
+	}
+
+	exists, err := p.stateProvider.Exists(pe.Table, pe.PrimaryKey)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		pe.Method = gravity_sdk_types_product_event.Method_UPDATE
+	} else {
+		pe.Method = gravity_sdk_types_product_event.Method_INSERT
+	}
+
+	return nil
+}
+
+// WithSuppressUnchanged drops records which are identical to their previous state, such as records of
+// full refresh which didn't change. It takes effect only if state provider is RecordStateProvider.
+func WithSuppressUnchanged(enabled bool) func(*Processor) {
+	return func(p *Processor) {
+		p.suppressUnchanged = enabled
+	}
+}
+
+// unchanged reports whether record of event is identical to its previous state.
+func (p *Processor) unchanged(pe *gravity_sdk_types_product_event.ProductEvent) (bool, error) {
+
+	sp, ok := p.stateProvider.(RecordStateProvider)
+	if !ok {
+		return false, nil
+	}
+
+	switch pe.Method {
+	case gravity_sdk_types_product_event.Method_DELETE,
+		gravity_sdk_types_product_event.Method_TRUNCATE:
+		return false, nil
+	}
+
+	if len(pe.PrimaryKey) == 0 {
+		return false, nil
+	}
+
+	prev, err := sp.Get(pe.Table, pe.PrimaryKey)
+	if err != nil || prev == nil {
+		return false, err
+	}
+
+	r, err := pe.GetContent()
+	if err != nil {
+		return false, err
+	}
+
+	update, err := DiffRecords(prev, r)
+	if err != nil {
+		// Records without map payload are never suppressed
+		return false, nil
+	}
+
+	return len(update.Payload.Map.Fields) == 0, nil
+}
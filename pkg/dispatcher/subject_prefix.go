@@ -0,0 +1,53 @@
+package dispatcher
+
+import (
+	"fmt"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+)
+
+var ErrInvalidSubjectPrefix = rule_manager.ErrInvalidSubjectPrefix
+
+// ValidateSubjectPrefix checks if prefix is made of legal tokens of NATS subject, without wildcards.
+func ValidateSubjectPrefix(prefix string) error {
+	return rule_manager.ValidateSubjectPrefix(prefix)
+}
+
+// WithSubjectPrefix prepends prefix to subjects of all output messages, ahead of subject prefix of product.
+// Prefix is expected to be validated by ValidateSubjectPrefix.
+func WithSubjectPrefix(prefix string) func(*Processor) {
+	return func(p *Processor) {
+		p.subjectPrefix = prefix
+	}
+}
+
+// WithProductSubjectPrefix prepends prefix to subjects of events of product, ahead of subject template of rules.
+// Prefix is validated when settings are applied, and settings without it clear prefix which was set before.
+func WithProductSubjectPrefix(prefix string) func(*Product) {
+	return func(p *Product) {
+		p.SubjectPrefix = prefix
+	}
+}
+
+// storedSubjectPrefix returns subject prefix which stored product setting keeps next to its fields, or empty
+// string if there is none.
+func storedSubjectPrefix(data []byte) (string, error) {
+
+	var doc map[string]interface{}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return "", err
+	}
+
+	v, ok := doc[rule_manager.SubjectPrefixSettingKey]
+	if !ok || v == nil {
+		return "", nil
+	}
+
+	prefix, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSubjectPrefix, v)
+	}
+
+	return prefix, nil
+}
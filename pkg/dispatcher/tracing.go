@@ -0,0 +1,69 @@
+package dispatcher
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Tracer starts spans for processing pipeline. Adapters for tracing systems, such as OpenTelemetry,
+// are able to extract incoming trace context from header of NATS message.
+type Tracer interface {
+	Start(ctx context.Context, name string, header nats.Header) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+func WithTracer(tracer Tracer) func(*Processor) {
+	return func(p *Processor) {
+		p.tracer = tracer
+	}
+}
+
+func (p *Processor) trace(msg *Message) func() {
+
+	ctx := msg.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var header nats.Header
+	if msg.Msg != nil {
+		header = msg.Msg.Header
+	}
+
+	ctx, span := p.tracer.Start(ctx, "dispatcher.transform", header)
+
+	// Steps afterward are able to create child spans
+	msg.Context = ctx
+
+	return func() {
+
+		if msg.Rule != nil {
+			span.SetAttribute("product", msg.Rule.Product)
+		}
+
+		event := msg.Event
+		if len(event) == 0 && msg.Data != nil {
+			event = msg.Data.Event
+		}
+
+		span.SetAttribute("event", event)
+
+		switch {
+		case msg.Error != nil:
+			span.SetAttribute("result", "error")
+			span.RecordError(msg.Error)
+		case msg.Ignore:
+			span.SetAttribute("result", "ignored")
+		default:
+			span.SetAttribute("result", "ok")
+		}
+
+		span.End()
+	}
+}
@@ -0,0 +1,121 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type testSpan struct {
+	name       string
+	parent     context.Context
+	header     nats.Header
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *testSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *testSpan) End() {
+	s.ended = true
+}
+
+type testSpanKey struct{}
+
+type testTracer struct {
+	spans []*testSpan
+	mutex sync.Mutex
+}
+
+func (tt *testTracer) Start(ctx context.Context, name string, header nats.Header) (context.Context, Span) {
+
+	span := &testSpan{
+		name:       name,
+		parent:     ctx,
+		header:     header,
+		attributes: make(map[string]interface{}),
+	}
+
+	tt.mutex.Lock()
+	tt.spans = append(tt.spans, span)
+	tt.mutex.Unlock()
+
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func TestProcessor_Tracing(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	tracer := &testTracer{}
+
+	var wg sync.WaitGroup
+	results := make([]*Message, 0)
+
+	p := NewProcessor(
+		WithTracer(tracer),
+		WithOutputHandler(func(msg *Message) {
+			results = append(results, msg)
+			wg.Done()
+		}),
+	)
+
+	type traceKey struct{}
+	parent := context.WithValue(context.Background(), traceKey{}, "parent")
+
+	inputs := []string{
+		`{"id":101,"name":"fred"}`,
+		`{"name":"no primary key"}`,
+	}
+
+	for _, payload := range inputs {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(payload),
+		})
+
+		msg := CreateTestMessage()
+		msg.Context = parent
+		msg.Raw = raw
+
+		wg.Add(1)
+		p.Push(msg)
+	}
+
+	wg.Wait()
+
+	if !assert.Len(t, tracer.spans, 2) {
+		return
+	}
+
+	// Span context should be propagated to message for steps afterward
+	for _, msg := range results {
+		span, ok := msg.Context.Value(testSpanKey{}).(*testSpan)
+		if assert.True(t, ok) {
+			assert.Equal(t, "parent", span.parent.Value(traceKey{}))
+		}
+	}
+
+	outcomes := map[interface{}]*testSpan{}
+	for _, span := range tracer.spans {
+		assert.Equal(t, "dispatcher.transform", span.name)
+		assert.True(t, span.ended)
+		assert.Equal(t, "TestDataProduct", span.attributes["product"])
+		assert.Equal(t, "dataCreated", span.attributes["event"])
+		outcomes[span.attributes["result"]] = span
+	}
+
+	assert.Nil(t, outcomes["ok"].err)
+	assert.NotNil(t, outcomes["error"].err)
+}
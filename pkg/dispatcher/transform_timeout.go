@@ -0,0 +1,42 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrTransformTimeout = errors.New("transform timed out")
+
+// WithTransformTimeout limits time of transforming each message, so that pathological payloads are aborted
+// and passed to error handler with ErrTransformTimeout. It's unlimited by default.
+func WithTransformTimeout(timeout time.Duration) func(*Processor) {
+	return func(p *Processor) {
+		p.transformTimeout = timeout
+	}
+}
+
+// transformContext derives context for transforming from context of message.
+func (p *Processor) transformContext(msg *Message) (context.Context, context.CancelFunc) {
+
+	ctx := msg.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if p.transformTimeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, p.transformTimeout)
+}
+
+func (p *Processor) transformError(ctx context.Context, err error) error {
+
+	if p.transformTimeout > 0 && errors.Is(err, context.DeadlineExceeded) && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: exceeded %s", ErrTransformTimeout, p.transformTimeout)
+	}
+
+	return err
+}
@@ -0,0 +1,141 @@
+package dispatcher
+
+import (
+	"math"
+	"time"
+
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+)
+
+// TypedField provides typed accessors for field of record, so callers don't have to assert value which is
+// returned by record_type.GetValueData. Every accessor reports false if field is nil, null or of another type.
+type TypedField struct {
+	*record_type.Field
+}
+
+// Typed wraps field with typed accessors, such as Typed(field).AsInt64().
+func Typed(field *record_type.Field) TypedField {
+	return TypedField{Field: field}
+}
+
+func (f TypedField) value() *record_type.Value {
+
+	if f.Field == nil {
+		return nil
+	}
+
+	return f.Field.Value
+}
+
+// IsNull tells whether field has no value.
+func (f TypedField) IsNull() bool {
+	v := f.value()
+	return v == nil || v.Type == record_type.DataType_NULL
+}
+
+// AsInt64 returns value of signed integer, or unsigned one which fits in int64.
+func (f TypedField) AsInt64() (int64, bool) {
+
+	v := f.value()
+	if v == nil {
+		return 0, false
+	}
+
+	switch v.Type {
+	case record_type.DataType_INT64:
+		return record_type.GetValueData(v).(int64), true
+	case record_type.DataType_UINT64:
+		n := record_type.GetValueData(v).(uint64)
+		if n > math.MaxInt64 {
+			return 0, false
+		}
+
+		return int64(n), true
+	}
+
+	return 0, false
+}
+
+// AsUint64 returns value of unsigned integer, or signed one which is not negative.
+func (f TypedField) AsUint64() (uint64, bool) {
+
+	v := f.value()
+	if v == nil {
+		return 0, false
+	}
+
+	switch v.Type {
+	case record_type.DataType_UINT64:
+		return record_type.GetValueData(v).(uint64), true
+	case record_type.DataType_INT64:
+		n := record_type.GetValueData(v).(int64)
+		if n < 0 {
+			return 0, false
+		}
+
+		return uint64(n), true
+	}
+
+	return 0, false
+}
+
+// AsFloat64 returns value of float, and integers are converted as well.
+func (f TypedField) AsFloat64() (float64, bool) {
+
+	v := f.value()
+	if v == nil {
+		return 0, false
+	}
+
+	switch v.Type {
+	case record_type.DataType_FLOAT64:
+		return record_type.GetValueData(v).(float64), true
+	case record_type.DataType_INT64:
+		return float64(record_type.GetValueData(v).(int64)), true
+	case record_type.DataType_UINT64:
+		return float64(record_type.GetValueData(v).(uint64)), true
+	}
+
+	return 0, false
+}
+
+func (f TypedField) AsString() (string, bool) {
+
+	v := f.value()
+	if v == nil || v.Type != record_type.DataType_STRING {
+		return "", false
+	}
+
+	return string(v.Value), true
+}
+
+// AsBool returns value of boolean, which is decoded as integer by record_type.GetValueData.
+func (f TypedField) AsBool() (bool, bool) {
+
+	v := f.value()
+	if v == nil || v.Type != record_type.DataType_BOOLEAN {
+		return false, false
+	}
+
+	return record_type.GetValueData(v).(int8) != 0, true
+}
+
+func (f TypedField) AsTime() (time.Time, bool) {
+
+	v := f.value()
+	if v == nil || v.Type != record_type.DataType_TIME || v.Timestamp == nil {
+		return time.Time{}, false
+	}
+
+	return v.Timestamp.AsTime(), true
+}
+
+func (f TypedField) AsBytes() ([]byte, bool) {
+
+	v := f.value()
+	if v == nil || v.Type != record_type.DataType_BINARY {
+		return nil, false
+	}
+
+	return v.Value, true
+}
@@ -0,0 +1,123 @@
+package dispatcher
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTypedField(t *testing.T, value interface{}) TypedField {
+
+	v, err := record_type.GetValueFromInterface(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Typed(&record_type.Field{Name: "test", Value: v})
+}
+
+func TestTypedField(t *testing.T) {
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	i := createTypedField(t, int64(-3))
+	u := createTypedField(t, uint64(math.MaxUint64))
+	f := createTypedField(t, 1.5)
+	s := createTypedField(t, "fred")
+	b := createTypedField(t, true)
+	tm := createTypedField(t, now)
+	null := createTypedField(t, nil)
+
+	// Bytes are taken as array of integers by record_type.GetValueFromInterface
+	bin := Typed(&record_type.Field{
+		Name: "test",
+		Value: &record_type.Value{
+			Type:  record_type.DataType_BINARY,
+			Value: []byte("raw"),
+		},
+	})
+
+	// Matching types
+	n, ok := i.AsInt64()
+	assert.True(t, ok)
+	assert.Equal(t, int64(-3), n)
+
+	un, ok := u.AsUint64()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(math.MaxUint64), un)
+
+	fl, ok := f.AsFloat64()
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, fl)
+
+	str, ok := s.AsString()
+	assert.True(t, ok)
+	assert.Equal(t, "fred", str)
+
+	bl, ok := b.AsBool()
+	assert.True(t, ok)
+	assert.True(t, bl)
+
+	ts, ok := tm.AsTime()
+	assert.True(t, ok)
+	assert.True(t, now.Equal(ts))
+
+	data, ok := bin.AsBytes()
+	assert.True(t, ok)
+	assert.Equal(t, []byte("raw"), data)
+
+	assert.True(t, null.IsNull())
+	assert.False(t, s.IsNull())
+	assert.True(t, Typed(nil).IsNull())
+
+	// Integers which fit are converted
+	n, ok = createTypedField(t, uint64(7)).AsInt64()
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), n)
+
+	fl, ok = i.AsFloat64()
+	assert.True(t, ok)
+	assert.Equal(t, float64(-3), fl)
+
+	// Integers which don't fit
+	_, ok = u.AsInt64()
+	assert.False(t, ok)
+
+	_, ok = i.AsUint64()
+	assert.False(t, ok)
+
+	// Mismatched types
+	for _, field := range []TypedField{s, b, tm, bin, null, Typed(nil)} {
+		_, ok = field.AsInt64()
+		assert.False(t, ok)
+
+		_, ok = field.AsUint64()
+		assert.False(t, ok)
+
+		_, ok = field.AsFloat64()
+		assert.False(t, ok)
+	}
+
+	for _, field := range []TypedField{i, f, b, tm, bin, null} {
+		_, ok = field.AsString()
+		assert.False(t, ok)
+	}
+
+	for _, field := range []TypedField{i, s, tm, bin, null} {
+		_, ok = field.AsBool()
+		assert.False(t, ok)
+	}
+
+	for _, field := range []TypedField{i, s, b, bin, null} {
+		_, ok = field.AsTime()
+		assert.False(t, ok)
+	}
+
+	for _, field := range []TypedField{i, s, b, tm, null} {
+		_, ok = field.AsBytes()
+		assert.False(t, ok)
+	}
+}
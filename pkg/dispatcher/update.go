@@ -0,0 +1,185 @@
+package dispatcher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	record_type "github.com/BrobridgeOrg/gravity-sdk/v2/types/record"
+	"google.golang.org/protobuf/proto"
+)
+
+// FieldPathError describes failure of updating field by path.
+type FieldPathError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldPathError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FieldPathError) Unwrap() error {
+	return e.Err
+}
+
+func (e *FieldPathError) Is(target error) bool {
+	return target == ErrInvalidFieldPath
+}
+
+// UpdateError collects failures of every path in update, so that all of invalid paths are reported at once.
+type UpdateError struct {
+	Errors []*FieldPathError
+}
+
+func (e *UpdateError) Error() string {
+
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("%v: %s", ErrInvalidFieldPath, strings.Join(msgs, "; "))
+}
+
+func (e *UpdateError) Unwrap() []error {
+
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// Paths returns paths which were failed to be updated.
+func (e *UpdateError) Paths() []string {
+
+	paths := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		paths[i] = err.Path
+	}
+
+	return paths
+}
+
+func newUpdateError(errs []*FieldPathError) error {
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &UpdateError{
+		Errors: errs,
+	}
+}
+
+// ApplyUpdate applies update which was emitted by processor to record, including dotted paths, removed fields
+// and array operations. Update is applied to a copy of record which takes place of payload only if every path
+// was applied, so record is left unchanged if any of paths fails. Failures are reported together by UpdateError.
+func ApplyUpdate(r *record_type.Record, update *record_type.Record) error {
+
+	staged := &record_type.Record{
+		Meta:    r.Meta,
+		Payload: proto.Clone(r.Payload).(*record_type.Value),
+	}
+
+	errs := make([]*FieldPathError, 0)
+	for _, f := range update.Payload.Map.Fields {
+
+		switch f.Name {
+		case RemovedFieldsField:
+			errs = append(errs, removeFields(staged.Payload, f.Value)...)
+			continue
+		case rule_manager.ArrayAppendField, rule_manager.ArrayRemoveElementField:
+			continue
+		}
+
+		err := applyField(staged.Payload, f.Name, f.Value)
+		if err != nil {
+			errs = append(errs, &FieldPathError{Path: f.Name, Err: err})
+		}
+	}
+
+	errs = append(errs, applyArrayOperations(staged, update)...)
+
+	err := newUpdateError(errs)
+	if err != nil {
+		return err
+	}
+
+	r.Payload = staged.Payload
+
+	return nil
+}
+
+func applyField(root *record_type.Value, path string, value *record_type.Value) error {
+
+	tokens := record_type.ParsePath(path)
+	if len(tokens) == 0 {
+		return record_type.ErrNotFoundKey
+	}
+
+	parent, err := lookupValue(root, tokens[:len(tokens)-1], true)
+	if err != nil {
+		return err
+	}
+
+	// Maps are merged rather than replaced
+	if parent.Type == record_type.DataType_MAP && value.Type == record_type.DataType_MAP {
+		field := record_type.GetField(parent.Map.Fields, tokens[len(tokens)-1].Value)
+		if field != nil && field.Value.Type == record_type.DataType_MAP {
+			record_type.ApplyChanges(field.Value, value)
+			return nil
+		}
+	}
+
+	return putValue(parent, tokens[len(tokens)-1], value)
+}
+
+func removeFields(root *record_type.Value, removed *record_type.Value) []*FieldPathError {
+
+	if removed.Type != record_type.DataType_ARRAY || removed.Array == nil {
+		return nil
+	}
+
+	errs := make([]*FieldPathError, 0)
+	for _, ele := range removed.Array.Elements {
+
+		path, ok := record_type.GetValueData(ele).(string)
+		if !ok {
+			continue
+		}
+
+		tokens := record_type.ParsePath(path)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		parent, err := lookupValue(root, tokens[:len(tokens)-1], false)
+		if err == record_type.ErrNotFoundKey {
+			// Nothing to remove
+			continue
+		} else if err != nil {
+			errs = append(errs, &FieldPathError{Path: path, Err: err})
+			continue
+		}
+
+		if parent.Type != record_type.DataType_MAP {
+			errs = append(errs, &FieldPathError{Path: path, Err: fmt.Errorf("expected map, but got %s", parent.Type)})
+			continue
+		}
+
+		name := tokens[len(tokens)-1].Value
+		fields := parent.Map.Fields[:0]
+		for _, f := range parent.Map.Fields {
+			if f.Name != name {
+				fields = append(fields, f)
+			}
+		}
+
+		parent.Map.Fields = fields
+	}
+
+	return errs
+}
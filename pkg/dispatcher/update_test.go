@@ -0,0 +1,126 @@
+package dispatcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyUpdate(t *testing.T) {
+
+	r := CreateTestRecord(t, map[string]interface{}{
+		"id":     int64(101),
+		"name":   "fred",
+		"gender": "m",
+		"items": []interface{}{
+			map[string]interface{}{"sku": "a", "qty": int64(1)},
+			map[string]interface{}{"sku": "b", "qty": int64(2)},
+		},
+		"nested": map[string]interface{}{
+			"nested_id": "n1",
+			"legacy":    "x",
+		},
+	})
+
+	update := CreateTestRecord(t, map[string]interface{}{
+		"items.0.qty":      int64(5),
+		"items.5.qty":      int64(7),
+		"items.1.qty":      int64(3),
+		"name.first":       "stacy",
+		"nested.nested_id": "n2",
+		"$removedFields":   []interface{}{"gender", "nested.legacy"},
+	})
+
+	err := ApplyUpdate(r, update)
+
+	var updateErr *UpdateError
+	if assert.ErrorAs(t, err, &updateErr) {
+		assert.ElementsMatch(t, []string{"items.5.qty", "name.first"}, updateErr.Paths())
+	}
+
+	assert.ErrorIs(t, err, ErrInvalidFieldPath)
+
+	// Record is left unchanged
+	assert.Equal(t, map[string]interface{}{
+		"id":     int64(101),
+		"name":   "fred",
+		"gender": "m",
+		"items": []interface{}{
+			map[string]interface{}{"sku": "a", "qty": int64(1)},
+			map[string]interface{}{"sku": "b", "qty": int64(2)},
+		},
+		"nested": map[string]interface{}{
+			"nested_id": "n1",
+			"legacy":    "x",
+		},
+	}, r.AsMap())
+
+	// Valid paths take effect together
+	update = CreateTestRecord(t, map[string]interface{}{
+		"items.0.qty":      int64(5),
+		"items.1.qty":      int64(3),
+		"nested.nested_id": "n2",
+		"$removedFields":   []interface{}{"gender", "nested.legacy"},
+	})
+
+	if assert.Nil(t, ApplyUpdate(r, update)) {
+		assert.Equal(t, map[string]interface{}{
+			"id":   int64(101),
+			"name": "fred",
+			"items": []interface{}{
+				map[string]interface{}{"sku": "a", "qty": int64(5)},
+				map[string]interface{}{"sku": "b", "qty": int64(3)},
+			},
+			"nested": map[string]interface{}{
+				"nested_id": "n2",
+			},
+		}, r.AsMap())
+	}
+}
+
+func TestApplyUpdate_Atomic(t *testing.T) {
+
+	r := CreateTestRecord(t, map[string]interface{}{
+		"id":     int64(101),
+		"name":   "fred",
+		"level":  int64(1),
+		"nested": map[string]interface{}{"nested_id": "n1"},
+	})
+
+	// Name is not a map
+	update := CreateTestRecord(t, map[string]interface{}{
+		"level":            int64(2),
+		"name.first":       "stacy",
+		"nested.nested_id": "n2",
+	})
+
+	err := ApplyUpdate(r, update)
+
+	var updateErr *UpdateError
+	if assert.ErrorAs(t, err, &updateErr) {
+		assert.Equal(t, []string{"name.first"}, updateErr.Paths())
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"id":     int64(101),
+		"name":   "fred",
+		"level":  int64(1),
+		"nested": map[string]interface{}{"nested_id": "n1"},
+	}, r.AsMap())
+}
+
+func TestApplyUpdate_MergesMaps(t *testing.T) {
+
+	r := CreateTestRecord(t, map[string]interface{}{
+		"id":     int64(101),
+		"nested": map[string]interface{}{"nested_id": "n1", "level": int64(1)},
+	})
+
+	update := CreateTestRecord(t, map[string]interface{}{
+		"nested": map[string]interface{}{"level": int64(2)},
+	})
+
+	if assert.Nil(t, ApplyUpdate(r, update)) {
+		assert.Equal(t, map[string]interface{}{"nested_id": "n1", "level": int64(2)}, r.AsMap()["nested"])
+	}
+}
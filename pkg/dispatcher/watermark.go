@@ -0,0 +1,131 @@
+package dispatcher
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// watermarkTracker keeps event times of in-flight messages. Watermark is the earliest one of them,
+// or the latest event time seen if nothing is in flight, and it never goes backward.
+type watermarkTracker struct {
+	inflight  map[*Message]time.Time
+	latest    time.Time
+	watermark time.Time
+	lateness  time.Duration
+	flagLate  bool
+	mutex     sync.Mutex
+}
+
+func newWatermarkTracker() *watermarkTracker {
+	return &watermarkTracker{
+		inflight: make(map[*Message]time.Time),
+	}
+}
+
+// WithAllowedLateness enables flagging messages as late if event time is older than watermark minus lateness.
+func WithAllowedLateness(lateness time.Duration) func(*Processor) {
+	return func(p *Processor) {
+		p.watermark.flagLate = true
+		p.watermark.lateness = lateness
+	}
+}
+
+// Watermark returns the earliest event time of messages in flight. It returns zero time if no
+// message with event time has been processed.
+func (p *Processor) Watermark() time.Time {
+
+	p.watermark.mutex.Lock()
+	defer p.watermark.mutex.Unlock()
+
+	return p.watermark.watermark
+}
+
+// track starts tracking message and reports whether it is late.
+func (wt *watermarkTracker) track(msg *Message, t time.Time) bool {
+
+	wt.mutex.Lock()
+	defer wt.mutex.Unlock()
+
+	late := wt.flagLate && !wt.watermark.IsZero() && t.Before(wt.watermark.Add(-wt.lateness))
+
+	wt.inflight[msg] = t
+	if t.After(wt.latest) {
+		wt.latest = t
+	}
+
+	wt.advance()
+
+	return late
+}
+
+func (wt *watermarkTracker) done(msg *Message) {
+
+	wt.mutex.Lock()
+	defer wt.mutex.Unlock()
+
+	if _, ok := wt.inflight[msg]; !ok {
+		return
+	}
+
+	delete(wt.inflight, msg)
+
+	wt.advance()
+}
+
+func (wt *watermarkTracker) advance() {
+
+	candidate := wt.latest
+	for _, t := range wt.inflight {
+		if t.Before(candidate) {
+			candidate = t
+		}
+	}
+
+	if candidate.After(wt.watermark) {
+		wt.watermark = candidate
+	}
+}
+
+// ResetState clears in-memory state derived from processed events, so that it is not carried over after
+// draining for maintenance. Watermark and sequences of reorder buffer start over, messages held by reorder
+// buffer are emitted right away, and state provider is reset if it's ResettableStateProvider. Messages still
+// in flight keep being tracked.
+func (p *Processor) ResetState() {
+
+	p.watermark.reset()
+
+	p.releaseMutex.Lock()
+	if p.reorder.enabled() {
+		if held := p.reorder.reset(); len(held) > 0 {
+			// Held messages are released as a task of their own
+			p.queueDepth.depth.Add(1)
+			p.release(held)
+		}
+	}
+	p.releaseMutex.Unlock()
+
+	if sp, ok := p.stateProvider.(ResettableStateProvider); ok {
+		if err := sp.Reset(); err != nil {
+			logger.Warn("Failed to reset state provider", zap.Error(err))
+		}
+	}
+}
+
+func (wt *watermarkTracker) reset() {
+
+	wt.mutex.Lock()
+	defer wt.mutex.Unlock()
+
+	wt.latest = time.Time{}
+	wt.watermark = time.Time{}
+
+	for _, t := range wt.inflight {
+		if t.After(wt.latest) {
+			wt.latest = t
+		}
+	}
+
+	wt.advance()
+}
@@ -0,0 +1,134 @@
+package dispatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestWatermarkTracker(t *testing.T) {
+
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	wt := newWatermarkTracker()
+	assert.True(t, wt.watermark.IsZero())
+
+	m1, m2, m3 := NewMessage(), NewMessage(), NewMessage()
+
+	// The earliest in-flight message holds watermark
+	wt.track(m1, base)
+	wt.track(m2, base.Add(2*time.Minute))
+	assert.Equal(t, base, wt.watermark)
+
+	wt.done(m1)
+	assert.Equal(t, base.Add(2*time.Minute), wt.watermark)
+
+	// Older event never moves watermark backward
+	wt.track(m3, base.Add(time.Minute))
+	assert.Equal(t, base.Add(2*time.Minute), wt.watermark)
+
+	wt.done(m3)
+	wt.done(m2)
+	assert.Equal(t, base.Add(2*time.Minute), wt.watermark)
+}
+
+func TestProcessor_Watermark(t *testing.T) {
+
+	logger = zap.NewNop()
+
+	p := NewProcessor(WithAllowedLateness(time.Minute))
+	defer p.Close()
+
+	r := CreateTestRule()
+	r.SchemaConfig["updatedAt"] = map[string]interface{}{
+		"type": "time",
+	}
+	r.EventTimeField = "updatedAt"
+
+	rm := rule_manager.NewRuleManager()
+	if !assert.Nil(t, rm.AddRule(r)) {
+		return
+	}
+
+	process := func(updatedAt string) *Message {
+		raw, _ := json.Marshal(MessageRawData{
+			Event:      "dataCreated",
+			RawPayload: []byte(`{"id":101,"updatedAt":"` + updatedAt + `"}`),
+		})
+
+		msg := NewMessage()
+		msg.Rule = r
+		msg.Raw = raw
+
+		result, err := p.Process(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	}
+
+	assert.True(t, p.Watermark().IsZero())
+
+	inputs := []struct {
+		updatedAt string
+		watermark string
+		late      bool
+	}{
+		{"2024-05-01T10:00:00Z", "2024-05-01T10:00:00Z", false},
+		{"2024-05-01T10:05:00Z", "2024-05-01T10:05:00Z", false},
+		// Within allowed lateness
+		{"2024-05-01T10:04:30Z", "2024-05-01T10:05:00Z", false},
+		{"2024-05-01T10:01:00Z", "2024-05-01T10:05:00Z", true},
+		{"2024-05-01T10:06:00Z", "2024-05-01T10:06:00Z", false},
+	}
+
+	prev := p.Watermark()
+	for _, input := range inputs {
+
+		msg := process(input.updatedAt)
+
+		expected, _ := time.Parse(time.RFC3339, input.updatedAt)
+		assert.True(t, expected.Equal(msg.EventTime), input.updatedAt)
+		assert.Equal(t, input.late, msg.Late, input.updatedAt)
+
+		watermark, _ := time.Parse(time.RFC3339, input.watermark)
+		assert.True(t, watermark.Equal(p.Watermark()), input.updatedAt)
+
+		// Watermark advances monotonically
+		assert.False(t, p.Watermark().Before(prev))
+		prev = p.Watermark()
+	}
+
+	// Event which was late is accepted after state was reset
+	p.ResetState()
+	assert.True(t, p.Watermark().IsZero())
+
+	msg := process("2024-05-01T10:01:00Z")
+	assert.False(t, msg.Late)
+}
+
+func TestWatermarkTracker_ResetKeepsInflight(t *testing.T) {
+
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	wt := newWatermarkTracker()
+
+	m1, m2 := NewMessage(), NewMessage()
+	wt.track(m1, base.Add(5*time.Minute))
+	wt.done(m1)
+
+	wt.track(m2, base.Add(time.Minute))
+	assert.Equal(t, base.Add(5*time.Minute), wt.watermark)
+
+	// Watermark starts over from messages in flight
+	wt.reset()
+	assert.Equal(t, base.Add(time.Minute), wt.watermark)
+
+	wt.done(m2)
+	assert.Equal(t, base.Add(time.Minute), wt.watermark)
+	assert.Empty(t, wt.inflight)
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// countingConfigStore counts reads which go to config store
+type countingConfigStore struct {
+	productConfigStore
+	gets atomic.Int64
+}
+
+func (cs *countingConfigStore) Get(key string) (nats.KeyValueEntry, error) {
+	cs.gets.Add(1)
+	return cs.productConfigStore.Get(key)
+}
+
+func benchmarkGetProduct(b *testing.B, pm *ProductManager) {
+
+	setting := CreateTestProductSetting("TestProduct")
+	_, err := pm.CreateProduct(setting)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	cs := &countingConfigStore{
+		productConfigStore: pm.configStore,
+	}
+	pm.configStore = cs
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := pm.GetProduct(setting.Name)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(cs.gets.Load())/float64(b.N), "kv-calls/op")
+}
+
+func BenchmarkProductManager_GetProduct(b *testing.B) {
+
+	pm := CreateTestProductManager(b)
+
+	benchmarkGetProduct(b, pm)
+}
+
+func BenchmarkProductManager_GetProductWithCache(b *testing.B) {
+
+	s := StartTestServer(b)
+	pm := NewProductManager(CreateTestClient(b, s), testDomain, WithProductCache(time.Minute, 16))
+
+	benchmarkGetProduct(b, pm)
+}
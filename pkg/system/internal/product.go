@@ -1,10 +1,14 @@
 package internal
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/BrobridgeOrg/gravity-sdk/v2/config_store"
@@ -16,6 +20,7 @@ import (
 const (
 	productEventStream  = "GVT_%s_DP_%s"
 	productEventSubject = "$GVT.%s.DP.%s.%s.EVENT.>"
+	productConfigStream = "KV_GVT_%s_PRODUCT"
 )
 
 var (
@@ -24,27 +29,62 @@ var (
 	ErrProductNotFound       = errors.New("product not found")
 	ErrProductExistsAlready  = errors.New("product exists already")
 	ErrInvalidProductName    = errors.New("invalid product name")
+	ErrAmbiguousStream       = errors.New("stream is shared by multiple products")
 )
 
+type DeleteProductOptions struct {
+	DeleteStream bool
+}
+
 type ProductManager struct {
 	client      *core.Client
 	domain      string
-	configStore *config_store.ConfigStore
+	configStore productConfigStore
+	schemaStore nats.KeyValue
+	cache       *productCache
+	now         func() time.Time
+
+	readRetries      int
+	readRetryBackoff time.Duration
 }
 
-func NewProductManager(client *core.Client, domain string) *ProductManager {
+func NewProductManager(client *core.Client, domain string, opts ...func(*ProductManager)) *ProductManager {
 
 	pm := &ProductManager{
-		client: client,
-		domain: domain,
+		client:           client,
+		domain:           domain,
+		now:              time.Now,
+		readRetries:      DefaultReadRetries,
+		readRetryBackoff: DefaultReadRetryBackoff,
 	}
 
-	pm.configStore = config_store.NewConfigStore(client,
+	// Apply options
+	for _, o := range opts {
+		o(pm)
+	}
+
+	csOpts := []func(*config_store.ConfigStore){
 		config_store.WithDomain(domain),
 		config_store.WithCatalog("PRODUCT"),
-	)
+	}
+
+	// Watching changes to invalidate cached settings
+	if pm.cache != nil {
+		csOpts = append(csOpts, config_store.WithEventHandler(pm.cache.handleConfigEntry))
+	}
+
+	configStore := config_store.NewConfigStore(client, csOpts...)
+
+	err := configStore.Init()
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	pm.configStore = configStore
 
-	err := pm.configStore.Init()
+	// History of product schemas
+	pm.schemaStore, err = openProductSchemaStore(pm)
 	if err != nil {
 		fmt.Println(err)
 		return nil
@@ -53,10 +93,70 @@ func NewProductManager(client *core.Client, domain string) *ProductManager {
 	return pm
 }
 
+// WithProductCache enables read-through cache for GetProduct. Entries live for ttl at most and
+// are invalidated as soon as changes are observed in config store.
+func WithProductCache(ttl time.Duration, maxEntries int) func(*ProductManager) {
+	return func(pm *ProductManager) {
+		pm.cache = newProductCache(ttl, maxEntries)
+	}
+}
+
+// WithClock sets source of timestamps of product settings, which is time.Now by default.
+func WithClock(now func() time.Time) func(*ProductManager) {
+	return func(pm *ProductManager) {
+		pm.now = now
+	}
+}
+
+func (pm *ProductManager) invalidateCache(name string) {
+	if pm.cache != nil {
+		pm.cache.invalidate(name)
+	}
+}
+
+// HealthCheck verifies that connection is alive and config store is reachable within the deadline of ctx.
+func (pm *ProductManager) HealthCheck(ctx context.Context) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	conn := pm.client.GetConnection()
+	if conn == nil {
+		return errors.New("connection is not established")
+	}
+
+	if !conn.IsConnected() {
+		return fmt.Errorf("connection is not ready (status: %s)", conn.Status())
+	}
+
+	js, err := pm.client.GetJetStream()
+	if err != nil {
+		return err
+	}
+
+	// Lightweight status call to the stream behind config store
+	_, err = js.StreamInfo(fmt.Sprintf(productConfigStream, pm.domain), nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("config store is unreachable: %w", err)
+	}
+
+	return nil
+}
+
 func (pm *ProductManager) CreateProduct(productSetting *product.ProductSetting) (*product.ProductSetting, error) {
 
+	err := ValidateProductSetting(productSetting)
+	if err != nil {
+		return nil, err
+	}
+
 	// Attempt to get product information
-	_, err := pm.configStore.Get(productSetting.Name)
+	_, err = pm.getEntry(context.Background(), productSetting.Name)
+	if errors.Is(err, ErrConnectionUnavailable) {
+		return nil, err
+	}
+
 	if err != nats.ErrKeyNotFound {
 		return nil, ErrProductExistsAlready
 	}
@@ -65,10 +165,14 @@ func (pm *ProductManager) CreateProduct(productSetting *product.ProductSetting)
 		return nil, ErrInvalidProductName
 	}
 
-	productSetting.CreatedAt = time.Now()
-	productSetting.UpdatedAt = time.Now()
+	now := pm.now()
+	productSetting.CreatedAt = now
+	productSetting.UpdatedAt = now
 
-	data, _ := json.Marshal(productSetting)
+	data, err := encodeProductSetting(productSetting, "")
+	if err != nil {
+		return nil, err
+	}
 
 	// Write to KV store
 	_, err = pm.configStore.Put(productSetting.Name, data)
@@ -82,36 +186,138 @@ func (pm *ProductManager) CreateProduct(productSetting *product.ProductSetting)
 		return nil, err
 	}
 
+	// Setting is rolled back, so that history never misses schema of existing product
+	err = pm.recordSchemaVersion(productSetting.Name, productSetting)
+	if err != nil {
+		pm.configStore.Delete(productSetting.Name)
+		pm.invalidateCache(productSetting.Name)
+		return nil, err
+	}
+
 	return productSetting, nil
 }
 
+// EnsureProduct creates product if it doesn't exist, or updates it if setting was changed. Current setting
+// is returned either way, so that provisioning is able to be run repeatedly.
+func (pm *ProductManager) EnsureProduct(productSetting *product.ProductSetting) (*product.ProductSetting, error) {
+
+	err := ValidateProductSetting(productSetting)
+	if err != nil {
+		return nil, err
+	}
+
+	kv, err := pm.getEntry(context.Background(), productSetting.Name)
+	if err != nil {
+		switch err {
+		case nats.ErrInvalidKey:
+			return nil, ErrInvalidProductName
+		case nats.ErrKeyNotFound:
+			return pm.CreateProduct(productSetting)
+		}
+
+		return nil, err
+	}
+
+	current, err := pm.decodeProductSetting(kv.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	if isSameProductSetting(current, productSetting) {
+		return current, nil
+	}
+
+	productSetting.CreatedAt = current.CreatedAt
+
+	return pm.UpdateProduct(productSetting.Name, productSetting)
+}
+
+// isSameProductSetting compares settings without timestamps.
+func isSameProductSetting(a *product.ProductSetting, b *product.ProductSetting) bool {
+
+	x, y := *a, *b
+	x.CreatedAt, x.UpdatedAt = time.Time{}, time.Time{}
+	y.CreatedAt, y.UpdatedAt = time.Time{}, time.Time{}
+
+	dataX, _ := json.Marshal(&x)
+	dataY, _ := json.Marshal(&y)
+
+	return bytes.Equal(dataX, dataY)
+}
+
 func (pm *ProductManager) DeleteProduct(name string) error {
+	return pm.DeleteProductWithOptions(name, DeleteProductOptions{})
+}
+
+// DeleteProductWithOptions removes product setting and, if DeleteStream is set, the backing stream.
+// The stream is deleted before the setting so a failure leaves the product in place for retrying.
+func (pm *ProductManager) DeleteProductWithOptions(name string, opts DeleteProductOptions) error {
+	return pm.deleteProduct(context.Background(), name, opts)
+}
+
+func (pm *ProductManager) deleteProduct(ctx context.Context, name string, opts DeleteProductOptions) error {
 
 	// Check whether specific product exist or not
-	_, err := pm.GetProduct(name)
+	setting, err := pm.lookupProduct(ctx, name)
 	if err != nil {
 		return err
 	}
 
+	if opts.DeleteStream && len(setting.Stream) > 0 {
+
+		js, err := pm.client.GetJetStream()
+		if err != nil {
+			return err
+		}
+
+		// Stream might be gone already
+		err = js.DeleteStream(setting.Stream, streamOpts(ctx)...)
+		if err != nil && err != nats.ErrStreamNotFound {
+			return fmt.Errorf("failed to delete stream \"%s\" of product \"%s\": %w", setting.Stream, name, err)
+		}
+	}
+
 	err = pm.configStore.Delete(name)
 	if err != nil {
 		return err
 	}
 
+	pm.invalidateCache(name)
+
+	// Clear history for product which might be created with the same name later
+	err = pm.schemaStore.Purge(name)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func (pm *ProductManager) UpdateProduct(name string, productSetting *product.ProductSetting) (*product.ProductSetting, error) {
 
-	// Check whether specific product exist or not
-	_, err := pm.GetProduct(name)
+	// Check whether specific product exist or not, and keep the current setting for rolling back
+	current, err := pm.getProductEntry(context.Background(), name)
 	if err != nil {
 		return nil, err
 	}
 
-	productSetting.UpdatedAt = time.Now()
+	err = ValidateProductSetting(productSetting)
+	if err != nil {
+		return nil, err
+	}
 
-	data, _ := json.Marshal(productSetting)
+	productSetting.UpdatedAt = pm.now()
+
+	// Subject prefix is kept, as it's not part of setting
+	prefix, err := storedSubjectPrefix(current.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := encodeProductSetting(productSetting, prefix)
+	if err != nil {
+		return nil, err
+	}
 
 	// Write to KV store
 	_, err = pm.configStore.Put(name, data)
@@ -125,13 +331,27 @@ func (pm *ProductManager) UpdateProduct(name string, productSetting *product.Pro
 		return nil, err
 	}
 
+	pm.invalidateCache(name)
+
+	// Setting is rolled back, so that history never misses schema of existing product
+	err = pm.recordSchemaVersion(name, productSetting)
+	if err != nil {
+		pm.configStore.Put(name, current.Value())
+		pm.invalidateCache(name)
+		return nil, err
+	}
+
 	return productSetting, nil
 }
 
 func (pm *ProductManager) PurgeProduct(name string) error {
+	return pm.purgeProduct(context.Background(), name)
+}
+
+func (pm *ProductManager) purgeProduct(ctx context.Context, name string) error {
 
 	// Attempt to get product information
-	setting, err := pm.GetProduct(name)
+	setting, err := pm.lookupProduct(ctx, name)
 	if err != nil {
 		return err
 	}
@@ -142,7 +362,7 @@ func (pm *ProductManager) PurgeProduct(name string) error {
 	}
 
 	// Purge stream
-	err = js.PurgeStream(setting.Stream)
+	err = js.PurgeStream(setting.Stream, streamOpts(ctx)...)
 	if err != nil {
 		return err
 	}
@@ -151,9 +371,46 @@ func (pm *ProductManager) PurgeProduct(name string) error {
 }
 
 func (pm *ProductManager) GetProduct(name string) (*product.ProductSetting, error) {
+	return pm.lookupProduct(context.Background(), name)
+}
+
+// lookupProduct gets product setting from cache if enabled, or config store otherwise.
+func (pm *ProductManager) lookupProduct(ctx context.Context, name string) (*product.ProductSetting, error) {
+
+	if pm.cache == nil {
+		return pm.getProduct(ctx, name)
+	}
+
+	setting, generation := pm.cache.get(name)
+	if setting != nil {
+		return setting, nil
+	}
+
+	setting, err := pm.getProduct(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	pm.cache.set(name, setting, generation)
+
+	return setting, nil
+}
+
+func (pm *ProductManager) getProduct(ctx context.Context, name string) (*product.ProductSetting, error) {
+
+	kv, err := pm.getProductEntry(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parsing value
+	return pm.decodeProductSetting(kv.Value())
+}
+
+func (pm *ProductManager) getProductEntry(ctx context.Context, name string) (nats.KeyValueEntry, error) {
 
 	// Attempt to get product information
-	kv, err := pm.configStore.Get(name)
+	kv, err := pm.getEntry(ctx, name)
 	if err != nil {
 		switch err {
 		case nats.ErrInvalidKey:
@@ -165,17 +422,47 @@ func (pm *ProductManager) GetProduct(name string) (*product.ProductSetting, erro
 		return nil, err
 	}
 
-	// Parsing value
-	var productSetting product.ProductSetting
-	err = json.Unmarshal(kv.Value(), &productSetting)
+	return kv, nil
+}
+
+// GetProductByStream finds product which is backed by specific stream.
+func (pm *ProductManager) GetProductByStream(stream string) (*product.ProductSetting, error) {
+	return pm.productByStream(context.Background(), stream)
+}
+
+func (pm *ProductManager) productByStream(ctx context.Context, stream string) (*product.ProductSetting, error) {
+
+	products, err := pm.listProducts(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return &productSetting, nil
+	var found *product.ProductSetting
+	for _, p := range products {
+
+		if p.Stream != stream {
+			continue
+		}
+
+		if found != nil {
+			return nil, fmt.Errorf("%w: \"%s\" (%s, %s)", ErrAmbiguousStream, stream, found.Name, p.Name)
+		}
+
+		found = p
+	}
+
+	if found == nil {
+		return nil, ErrProductNotFound
+	}
+
+	return found, nil
 }
 
 func (pm *ProductManager) GetProductState(setting *product.ProductSetting) (*product.ProductState, error) {
+	return pm.productState(context.Background(), setting)
+}
+
+func (pm *ProductManager) productState(ctx context.Context, setting *product.ProductSetting) (*product.ProductState, error) {
 
 	js, err := pm.client.GetJetStream()
 	if err != nil {
@@ -183,8 +470,12 @@ func (pm *ProductManager) GetProductState(setting *product.ProductSetting) (*pro
 	}
 
 	// Getting states from stream
-	s, err := js.StreamInfo(setting.Stream)
+	s, err := js.StreamInfo(setting.Stream, streamOpts(ctx)...)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
 		return nil, ErrEventStoreNotFound
 	}
 
@@ -199,14 +490,23 @@ func (pm *ProductManager) GetProductState(setting *product.ProductSetting) (*pro
 }
 
 func (pm *ProductManager) ListProducts() ([]*product.ProductSetting, error) {
+	return pm.listProducts(context.Background())
+}
+
+func (pm *ProductManager) listProducts(ctx context.Context) ([]*product.ProductSetting, error) {
 
 	// Getting all entries
-	keys, _ := pm.configStore.Keys()
+	keys, err := pm.getKeys(ctx)
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return []*product.ProductSetting{}, nil
+	} else if err != nil {
+		return nil, err
+	}
 
 	entries := make([]nats.KeyValueEntry, len(keys))
 	for i, key := range keys {
 
-		entry, err := pm.configStore.Get(key)
+		entry, err := pm.getEntry(ctx, key)
 		if err != nil {
 			fmt.Printf("Can not get product \"%s\" information\n", key)
 			continue
@@ -218,18 +518,96 @@ func (pm *ProductManager) ListProducts() ([]*product.ProductSetting, error) {
 	products := make([]*product.ProductSetting, len(entries))
 	for i, entry := range entries {
 
-		var p product.ProductSetting
-		err := json.Unmarshal(entry.Value(), &p)
+		p, err := pm.decodeProductSetting(entry.Value())
 		if err != nil {
 			fmt.Printf("Product \"%s\" Invalid setting format\n", entry.Key())
+			p = &product.ProductSetting{}
 		}
 
-		products[i] = &p
+		products[i] = p
 	}
 
 	return products, nil
 }
 
+// RangeProducts calls fn for each product without loading all of settings at once, and stops if fn returns false.
+// Products which are failed to be fetched or decoded are passed to fn with error instead of setting.
+func (pm *ProductManager) RangeProducts(fn func(setting *product.ProductSetting, err error) bool) error {
+
+	keys, err := pm.getKeys(context.Background())
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+
+		entry, err := pm.getEntry(context.Background(), key)
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			// Deleted after listing
+			continue
+		} else if err != nil {
+			if !fn(nil, fmt.Errorf("failed to get product \"%s\": %w", key, err)) {
+				return nil
+			}
+
+			continue
+		}
+
+		p, err := pm.decodeProductSetting(entry.Value())
+		if err != nil {
+			if !fn(nil, fmt.Errorf("product \"%s\" has invalid setting format: %w", key, err)) {
+				return nil
+			}
+
+			continue
+		}
+
+		if !fn(p, nil) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// CountProducts returns number of products with keys of config store only, so no setting is fetched.
+func (pm *ProductManager) CountProducts() (int, error) {
+
+	keys, err := pm.getKeys(context.Background())
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// ProductNames returns sorted names of products which start with prefix, or all of them with empty prefix.
+// Names come from keys of config store, so no setting is fetched.
+func (pm *ProductManager) ProductNames(prefix string) ([]string, error) {
+
+	keys, err := pm.getKeys(context.Background())
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, key)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
 /*
 func (pm *ProductManager) PrepareSubscription(productName string, durable string, startSeq uint64) error {
 
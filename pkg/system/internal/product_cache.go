@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/BrobridgeOrg/gravity-sdk/v2/config_store"
+	"github.com/BrobridgeOrg/gravity-sdk/v2/product"
+)
+
+const (
+	DefaultProductCacheTTL        = 30 * time.Second
+	DefaultProductCacheMaxEntries = 1024
+)
+
+type productCacheEntry struct {
+	setting   *product.ProductSetting
+	expiresAt time.Time
+}
+
+type productCache struct {
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*productCacheEntry
+	generation uint64
+	hits       uint64
+	misses     uint64
+	mutex      sync.RWMutex
+}
+
+func newProductCache(ttl time.Duration, maxEntries int) *productCache {
+
+	if ttl <= 0 {
+		ttl = DefaultProductCacheTTL
+	}
+
+	if maxEntries <= 0 {
+		maxEntries = DefaultProductCacheMaxEntries
+	}
+
+	return &productCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*productCacheEntry),
+	}
+}
+
+// get returns a deep copy of cached setting and the generation to be used when storing a fresh one.
+func (pc *productCache) get(name string) (*product.ProductSetting, uint64) {
+
+	pc.mutex.RLock()
+	defer pc.mutex.RUnlock()
+
+	entry, ok := pc.entries[name]
+	if !ok || time.Now().After(entry.expiresAt) {
+		atomic.AddUint64(&pc.misses, 1)
+		return nil, pc.generation
+	}
+
+	atomic.AddUint64(&pc.hits, 1)
+
+	return cloneProductSetting(entry.setting), pc.generation
+}
+
+// set stores setting unless cache was invalidated since generation was taken.
+func (pc *productCache) set(name string, setting *product.ProductSetting, generation uint64) {
+
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if generation != pc.generation {
+		// Entry might be stale already
+		return
+	}
+
+	if _, ok := pc.entries[name]; !ok && len(pc.entries) >= pc.maxEntries {
+		pc.evict()
+	}
+
+	pc.entries[name] = &productCacheEntry{
+		setting:   cloneProductSetting(setting),
+		expiresAt: time.Now().Add(pc.ttl),
+	}
+}
+
+func (pc *productCache) evict() {
+
+	now := time.Now()
+
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range pc.entries {
+
+		// Drop expired entries
+		if now.After(entry.expiresAt) {
+			delete(pc.entries, key)
+			continue
+		}
+
+		if len(oldestKey) == 0 || entry.expiresAt.Before(oldest) {
+			oldestKey = key
+			oldest = entry.expiresAt
+		}
+	}
+
+	if len(pc.entries) < pc.maxEntries {
+		return
+	}
+
+	delete(pc.entries, oldestKey)
+}
+
+func (pc *productCache) invalidate(name string) {
+
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	pc.generation++
+	delete(pc.entries, name)
+}
+
+func (pc *productCache) handleConfigEntry(entry *config_store.ConfigEntry) {
+	pc.invalidate(entry.Key)
+}
+
+// cloneProductSetting copies setting along with its rules and schema, so callers never share them with cache.
+func cloneProductSetting(setting *product.ProductSetting) *product.ProductSetting {
+
+	s := *setting
+
+	if setting.Rules != nil {
+		s.Rules = make(map[string]*product.Rule, len(setting.Rules))
+		for id, r := range setting.Rules {
+			if r == nil {
+				s.Rules[id] = nil
+				continue
+			}
+
+			s.Rules[id] = rule_manager.CloneProductRule(r)
+		}
+	}
+
+	s.Schema = rule_manager.CloneSchemaConfig(setting.Schema)
+
+	if setting.Snapshot != nil {
+		snapshot := *setting.Snapshot
+		s.Snapshot = &snapshot
+	}
+
+	return &s
+}
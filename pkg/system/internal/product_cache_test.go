@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductManager_CacheInvalidatedByExternalUpdate(t *testing.T) {
+
+	s := StartTestServer(t)
+
+	// Long TTL so only watcher is able to invalidate entries
+	cached := NewProductManager(CreateTestClient(t, s), testDomain, WithProductCache(time.Hour, 16))
+	external := NewProductManager(CreateTestClient(t, s), testDomain)
+
+	setting := CreateTestProductSetting("TestProduct")
+	setting.Description = "v1"
+	_, err := external.CreateProduct(setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	p, err := cached.GetProduct(setting.Name)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "v1", p.Description)
+
+	// Served by cache once watcher has caught up with initial values
+	assert.Eventually(t, func() bool {
+		_, err := cached.GetProduct(setting.Name)
+		return err == nil && atomic.LoadUint64(&cached.cache.hits) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Update from another manager
+	setting.Description = "v2"
+	_, err = external.UpdateProduct(setting.Name, setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Eventually(t, func() bool {
+		p, err := cached.GetProduct(setting.Name)
+		return err == nil && p.Description == "v2"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestProductCache_Bounded(t *testing.T) {
+
+	c := newProductCache(time.Hour, 2)
+
+	for _, name := range []string{"a", "b", "c"} {
+		_, generation := c.get(name)
+		c.set(name, CreateTestProductSetting(name), generation)
+	}
+
+	assert.Equal(t, 2, len(c.entries))
+
+	p, _ := c.get("c")
+	assert.NotNil(t, p)
+}
+
+func TestProductCache_Expired(t *testing.T) {
+
+	c := newProductCache(time.Millisecond, 2)
+
+	_, generation := c.get("a")
+	c.set("a", CreateTestProductSetting("a"), generation)
+
+	time.Sleep(5 * time.Millisecond)
+
+	p, _ := c.get("a")
+	assert.Nil(t, p)
+}
+
+func TestProductCache_Copied(t *testing.T) {
+
+	c := newProductCache(time.Hour, 2)
+
+	setting := CreateTestProductSettingWithRule("a")
+	setting.Rules["created"].SchemaConfig = map[string]interface{}{
+		"id": map[string]interface{}{"type": "int"},
+	}
+
+	_, generation := c.get("a")
+	c.set("a", setting, generation)
+
+	// Changing setting which was stored
+	setting.Schema["id"].(map[string]interface{})["type"] = "string"
+	for _, r := range setting.Rules {
+		r.PrimaryKey[0] = "name"
+	}
+
+	p, _ := c.get("a")
+	if !assert.NotNil(t, p) {
+		return
+	}
+
+	assert.Equal(t, "int", p.Schema["id"].(map[string]interface{})["type"])
+	for _, r := range p.Rules {
+		assert.Equal(t, "id", r.PrimaryKey[0])
+
+		// Changing setting which was returned
+		r.PrimaryKey[0] = "name"
+		r.SchemaConfig["extra"] = map[string]interface{}{"type": "string"}
+	}
+
+	delete(p.Schema, "id")
+
+	p, _ = c.get("a")
+	assert.Contains(t, p.Schema, "id")
+	for _, r := range p.Rules {
+		assert.Equal(t, "id", r.PrimaryKey[0])
+		assert.NotContains(t, r.SchemaConfig, "extra")
+	}
+}
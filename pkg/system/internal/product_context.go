@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"context"
+
+	"github.com/BrobridgeOrg/gravity-sdk/v2/product"
+	"github.com/nats-io/nats.go"
+)
+
+// withContext runs read fn until it returns or ctx is done. Config store doesn't accept context, so request of
+// fn which is in flight keeps running in background after ctx is done, until it's replied or timed out by the
+// connection, and its result is discarded. fn is given ctx to make no more requests nor retries after that.
+func withContext[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) (T, error) {
+
+	var zero T
+
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn(ctx)
+		done <- result{value: v, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// withContextWrite runs fn unless ctx is done already. Writes to config store don't accept context, so deadline
+// of ctx is ignored once fn is started, and fn runs to the end and its result is returned.
+func withContextWrite[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+
+	if err := ctx.Err(); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return fn()
+}
+
+// streamOpts passes ctx to requests of stream operations, unless ctx is never done, which keeps default timeout.
+func streamOpts(ctx context.Context) []nats.JSOpt {
+
+	if ctx.Done() == nil {
+		return nil
+	}
+
+	return []nats.JSOpt{nats.Context(ctx)}
+}
+
+// CreateProductContext creates product unless ctx is done already. Deadline of ctx doesn't apply to writes.
+func (pm *ProductManager) CreateProductContext(ctx context.Context, productSetting *product.ProductSetting) (*product.ProductSetting, error) {
+	return withContextWrite(ctx, func() (*product.ProductSetting, error) {
+		return pm.CreateProduct(productSetting)
+	})
+}
+
+// EnsureProductContext ensures product unless ctx is done already. Deadline of ctx doesn't apply to writes.
+func (pm *ProductManager) EnsureProductContext(ctx context.Context, productSetting *product.ProductSetting) (*product.ProductSetting, error) {
+	return withContextWrite(ctx, func() (*product.ProductSetting, error) {
+		return pm.EnsureProduct(productSetting)
+	})
+}
+
+// UpdateProductContext updates product unless ctx is done already. Deadline of ctx doesn't apply to writes.
+func (pm *ProductManager) UpdateProductContext(ctx context.Context, name string, productSetting *product.ProductSetting) (*product.ProductSetting, error) {
+	return withContextWrite(ctx, func() (*product.ProductSetting, error) {
+		return pm.UpdateProduct(name, productSetting)
+	})
+}
+
+// DeleteProductContext deletes product unless ctx is done already. Deadline of ctx doesn't apply to writes.
+func (pm *ProductManager) DeleteProductContext(ctx context.Context, name string) error {
+	return pm.DeleteProductWithOptionsContext(ctx, name, DeleteProductOptions{})
+}
+
+// DeleteProductWithOptionsContext deletes product unless ctx is done already. ctx bounds reading setting and
+// deleting stream, but not deleting setting from config store, which is never abandoned once started.
+func (pm *ProductManager) DeleteProductWithOptionsContext(ctx context.Context, name string, opts DeleteProductOptions) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return pm.deleteProduct(ctx, name, opts)
+}
+
+// PurgeProductContext purges stream of product within deadline of ctx. Purge which timed out may still be applied.
+func (pm *ProductManager) PurgeProductContext(ctx context.Context, name string) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return pm.purgeProduct(ctx, name)
+}
+
+func (pm *ProductManager) GetProductContext(ctx context.Context, name string) (*product.ProductSetting, error) {
+	return withContext(ctx, func(ctx context.Context) (*product.ProductSetting, error) {
+		return pm.lookupProduct(ctx, name)
+	})
+}
+
+func (pm *ProductManager) GetProductByStreamContext(ctx context.Context, stream string) (*product.ProductSetting, error) {
+	return withContext(ctx, func(ctx context.Context) (*product.ProductSetting, error) {
+		return pm.productByStream(ctx, stream)
+	})
+}
+
+func (pm *ProductManager) GetProductStateContext(ctx context.Context, setting *product.ProductSetting) (*product.ProductState, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return pm.productState(ctx, setting)
+}
+
+func (pm *ProductManager) ListProductsContext(ctx context.Context) ([]*product.ProductSetting, error) {
+	return withContext(ctx, func(ctx context.Context) ([]*product.ProductSetting, error) {
+		return pm.listProducts(ctx)
+	})
+}
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-sdk/v2/product"
+)
+
+// ProductMeta describes entry of product in config store.
+type ProductMeta struct {
+	Key      string
+	Revision uint64
+
+	// Created is when product was created. Config store keeps only the latest revision of entry, so it
+	// comes from setting.
+	Created time.Time
+
+	// Modified is when the current revision was written to config store.
+	Modified time.Time
+}
+
+// GetProductWithMeta returns product setting along with revision of its entry, which is read from config store
+// rather than cache, so revision is always the latest.
+func (pm *ProductManager) GetProductWithMeta(name string) (*product.ProductSetting, ProductMeta, error) {
+
+	kv, err := pm.getProductEntry(context.Background(), name)
+	if err != nil {
+		return nil, ProductMeta{}, err
+	}
+
+	setting, err := pm.decodeProductSetting(kv.Value())
+	if err != nil {
+		return nil, ProductMeta{}, err
+	}
+
+	meta := ProductMeta{
+		Key:      kv.Key(),
+		Revision: kv.Revision(),
+		Created:  setting.CreatedAt,
+		Modified: kv.Created(),
+	}
+
+	return setting, meta, nil
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/BrobridgeOrg/gravity-sdk/v2/product"
+)
+
+// CurrentSettingVersion is version of stored product settings. Settings without version are version 1.
+const CurrentSettingVersion = 2
+
+// settingVersionKey is where version is stored, as a key next to fields of product setting.
+const settingVersionKey = "settingVersion"
+
+var ErrUnsupportedSettingVersion = errors.New("unsupported version of product setting")
+
+// settingMigration upgrades stored product setting by one version in place.
+type settingMigration func(doc map[string]interface{}, domain string) error
+
+// settingMigrations are keyed by version which they upgrade from.
+var settingMigrations = map[int]settingMigration{
+	1: migrateSettingV1,
+}
+
+// migrateSettingV1 fills stream which was not stored by version 1, with the stream named after product.
+func migrateSettingV1(doc map[string]interface{}, domain string) error {
+
+	if stream, _ := doc["stream"].(string); len(stream) > 0 {
+		return nil
+	}
+
+	name, ok := doc["name"].(string)
+	if !ok {
+		return ErrInvalidProductName
+	}
+
+	doc["stream"] = fmt.Sprintf(productEventStream, domain, name)
+
+	return nil
+}
+
+// encodeProductSetting stores setting along with version and subject prefix, which has no field in setting.
+func encodeProductSetting(setting *product.ProductSetting, subjectPrefix string) ([]byte, error) {
+
+	data, err := json.Marshal(setting)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	doc[settingVersionKey] = CurrentSettingVersion
+
+	if len(subjectPrefix) > 0 {
+		doc[rule_manager.SubjectPrefixSettingKey] = subjectPrefix
+	}
+
+	return json.Marshal(doc)
+}
+
+// decodeProductSetting parses stored product setting, and upgrades it to current version by migrations.
+func (pm *ProductManager) decodeProductSetting(data []byte) (*product.ProductSetting, error) {
+
+	var doc map[string]interface{}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	version := 1
+	if v, ok := doc[settingVersionKey]; ok {
+		n, ok := v.(float64)
+		if !ok || n != float64(int(n)) || n < 1 {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedSettingVersion, v)
+		}
+
+		version = int(n)
+	}
+
+	if version > CurrentSettingVersion {
+		return nil, fmt.Errorf("%w: %d is newer than %d", ErrUnsupportedSettingVersion, version, CurrentSettingVersion)
+	}
+
+	for ; version < CurrentSettingVersion; version++ {
+
+		migrate, ok := settingMigrations[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: no migration from %d", ErrUnsupportedSettingVersion, version)
+		}
+
+		err := migrate(doc, pm.domain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate product setting from version %d: %w", version, err)
+		}
+	}
+
+	delete(doc, settingVersionKey)
+
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var setting product.ProductSetting
+	err = json.Unmarshal(data, &setting)
+	if err != nil {
+		return nil, err
+	}
+
+	return &setting, nil
+}
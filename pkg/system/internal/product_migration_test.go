@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductManager_SettingMigration(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	// Version 1 has neither version nor stream
+	_, err := pm.configStore.Put("LegacyProduct", []byte(`{"name":"LegacyProduct","enabled":true}`))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	setting, err := pm.GetProduct("LegacyProduct")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "LegacyProduct", setting.Name)
+		assert.True(t, setting.Enabled)
+		assert.Equal(t, fmt.Sprintf(productEventStream, testDomain, "LegacyProduct"), setting.Stream)
+	}
+
+	// Stored with current version
+	_, err = pm.CreateProduct(CreateTestProductSetting("TestProduct"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	entry, err := pm.configStore.Get("TestProduct")
+	if assert.Nil(t, err) {
+		var doc map[string]interface{}
+		json.Unmarshal(entry.Value(), &doc)
+		assert.Equal(t, float64(CurrentSettingVersion), doc[settingVersionKey])
+	}
+
+	setting, err = pm.GetProduct("TestProduct")
+	if assert.Nil(t, err) {
+		assert.Equal(t, CreateTestProductSetting("TestProduct").Stream, setting.Stream)
+	}
+
+	// Future version is never misread
+	_, err = pm.configStore.Put("FutureProduct", []byte(`{"name":"FutureProduct","settingVersion":99}`))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = pm.GetProduct("FutureProduct")
+	assert.ErrorIs(t, err, ErrUnsupportedSettingVersion)
+}
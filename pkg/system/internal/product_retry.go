@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	DefaultReadRetries      = 3
+	DefaultReadRetryBackoff = 100 * time.Millisecond
+)
+
+var ErrConnectionUnavailable = errors.New("connection is unavailable")
+
+// productConfigStore is what product manager needs from config store.
+type productConfigStore interface {
+	Put(key string, value []byte) (uint64, error)
+	Get(key string) (nats.KeyValueEntry, error)
+	Delete(key string) error
+	Keys() ([]string, error)
+}
+
+// WithReadRetry sets how many times reads from config store are retried on connection errors, and backoff
+// before the first retry which is doubled for each of the rest. Writes are never retried, as they may have
+// been applied already.
+func WithReadRetry(retries int, backoff time.Duration) func(*ProductManager) {
+	return func(pm *ProductManager) {
+		pm.readRetries = retries
+		pm.readRetryBackoff = backoff
+	}
+}
+
+// isConnectionError reports whether err is caused by connection which is likely to be back soon.
+func isConnectionError(err error) bool {
+	return errors.Is(err, nats.ErrNoResponders) ||
+		errors.Is(err, nats.ErrConnectionClosed) ||
+		errors.Is(err, nats.ErrConnectionReconnecting) ||
+		errors.Is(err, nats.ErrDisconnected) ||
+		errors.Is(err, nats.ErrTimeout)
+}
+
+// retryRead calls fn until it succeeds, fails with error other than connection errors, or retries run out.
+// No more retries are made once ctx is done.
+func retryRead[T any](ctx context.Context, pm *ProductManager, fn func() (T, error)) (T, error) {
+
+	backoff := pm.readRetryBackoff
+	for i := 0; ; i++ {
+
+		v, err := fn()
+		if err == nil || !isConnectionError(err) {
+			return v, err
+		}
+
+		if i >= pm.readRetries {
+			return v, fmt.Errorf("%w: %w", ErrConnectionUnavailable, err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return v, fmt.Errorf("%w: %w", ErrConnectionUnavailable, err)
+		}
+
+		backoff *= 2
+	}
+}
+
+func (pm *ProductManager) getEntry(ctx context.Context, key string) (nats.KeyValueEntry, error) {
+	return retryRead(ctx, pm, func() (nats.KeyValueEntry, error) {
+		return pm.configStore.Get(key)
+	})
+}
+
+func (pm *ProductManager) getKeys(ctx context.Context) ([]string, error) {
+	return retryRead(ctx, pm, pm.configStore.Keys)
+}
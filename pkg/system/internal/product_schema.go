@@ -0,0 +1,188 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-sdk/v2/config_store"
+	"github.com/BrobridgeOrg/gravity-sdk/v2/product"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	productSchemaMaxRetries = 5
+
+	// Bucket of config store with catalog of product schemas
+	productSchemaBucket = "GVT_%s_PRODUCT_SCHEMA"
+)
+
+var ErrSchemaVersionNotFound = errors.New("schema version not found")
+
+// SchemaVersion is a snapshot of product schema. Versions start from 1 and increase
+// whenever schema of product was changed.
+type SchemaVersion struct {
+	Version   int                    `json:"version"`
+	Schema    map[string]interface{} `json:"schema"`
+	CreatedAt time.Time              `json:"createdAt"`
+}
+
+// openProductSchemaStore prepares bucket with config store, and opens it as key-value store which is able to
+// purge keys and create keys over tombstones.
+func openProductSchemaStore(pm *ProductManager) (nats.KeyValue, error) {
+
+	cs := config_store.NewConfigStore(pm.client,
+		config_store.WithDomain(pm.domain),
+		config_store.WithCatalog("PRODUCT_SCHEMA"),
+	)
+
+	err := cs.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := pm.client.GetJetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	return js.KeyValue(fmt.Sprintf(productSchemaBucket, pm.domain))
+}
+
+func (pm *ProductManager) getSchemaHistory(name string) ([]SchemaVersion, uint64, error) {
+
+	entry, err := pm.schemaStore.Get(name)
+	if err != nil {
+		if err == nats.ErrKeyNotFound {
+			return []SchemaVersion{}, 0, nil
+		}
+
+		return nil, 0, err
+	}
+
+	var history []SchemaVersion
+	err = json.Unmarshal(entry.Value(), &history)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return history, entry.Revision(), nil
+}
+
+// recordSchemaVersion appends schema of setting to history if it differs from the latest version.
+func (pm *ProductManager) recordSchemaVersion(name string, setting *product.ProductSetting) error {
+
+	var err error
+	for i := 0; i < productSchemaMaxRetries; i++ {
+
+		var history []SchemaVersion
+		var revision uint64
+		history, revision, err = pm.getSchemaHistory(name)
+		if err != nil {
+			return err
+		}
+
+		// Nothing changed
+		if len(history) > 0 {
+			same, err := isSameSchema(history[len(history)-1].Schema, setting.Schema)
+			if err != nil {
+				return err
+			}
+
+			if same {
+				return nil
+			}
+		}
+
+		history = append(history, SchemaVersion{
+			Version:   len(history) + 1,
+			Schema:    setting.Schema,
+			CreatedAt: setting.UpdatedAt,
+		})
+
+		data, err := json.Marshal(history)
+		if err != nil {
+			return err
+		}
+
+		// Compare-and-set to avoid losing versions written by others. History of deleted product might have left
+		// tombstone, which is taken over by Create.
+		if revision == 0 {
+			_, err = pm.schemaStore.Create(name, data)
+		} else {
+			_, err = pm.schemaStore.Update(name, data, revision)
+		}
+
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// isSameSchema compares schemas regardless of where they came from.
+func isSameSchema(a map[string]interface{}, b map[string]interface{}) (bool, error) {
+
+	x, err := normalizeSchema(a)
+	if err != nil {
+		return false, err
+	}
+
+	y, err := normalizeSchema(b)
+	if err != nil {
+		return false, err
+	}
+
+	return reflect.DeepEqual(x, y), nil
+}
+
+func normalizeSchema(schema map[string]interface{}) (interface{}, error) {
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	err = json.Unmarshal(data, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// GetProductSchemaHistory returns all versions of product schema in order.
+func (pm *ProductManager) GetProductSchemaHistory(name string) ([]SchemaVersion, error) {
+
+	// Check whether specific product exist or not
+	_, err := pm.GetProduct(name)
+	if err != nil {
+		return nil, err
+	}
+
+	history, _, err := pm.getSchemaHistory(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// GetProductSchemaVersion returns specific version of product schema.
+func (pm *ProductManager) GetProductSchemaVersion(name string, version int) (*SchemaVersion, error) {
+
+	history, err := pm.GetProductSchemaHistory(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if version < 1 || version > len(history) {
+		return nil, ErrSchemaVersionNotFound
+	}
+
+	return &history[version-1], nil
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductManager_GetProductSchemaHistory(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	setting := CreateTestProductSetting("TestProduct")
+	setting.Schema = map[string]interface{}{
+		"id": map[string]interface{}{"type": "int"},
+	}
+
+	_, err := pm.CreateProduct(setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// Schema was not changed
+	setting.Description = "updated"
+	_, err = pm.UpdateProduct(setting.Name, setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	schemas := []map[string]interface{}{
+		{
+			"id":   map[string]interface{}{"type": "int"},
+			"name": map[string]interface{}{"type": "string"},
+		},
+		{
+			"id":   map[string]interface{}{"type": "int"},
+			"name": map[string]interface{}{"type": "string"},
+			"age":  map[string]interface{}{"type": "uint"},
+		},
+	}
+
+	for _, schema := range schemas {
+		setting.Schema = schema
+		_, err = pm.UpdateProduct(setting.Name, setting)
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	history, err := pm.GetProductSchemaHistory(setting.Name)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if !assert.Len(t, history, 3) {
+		return
+	}
+
+	for i, v := range history {
+		assert.Equal(t, i+1, v.Version)
+		assert.False(t, v.CreatedAt.IsZero())
+
+		if i > 0 {
+			assert.False(t, v.CreatedAt.Before(history[i-1].CreatedAt))
+		}
+	}
+
+	assert.Contains(t, history[2].Schema, "age")
+	assert.NotContains(t, history[1].Schema, "age")
+
+	v, err := pm.GetProductSchemaVersion(setting.Name, 1)
+	if assert.Nil(t, err) {
+		assert.Len(t, v.Schema, 1)
+	}
+
+	_, err = pm.GetProductSchemaVersion(setting.Name, 4)
+	assert.Equal(t, ErrSchemaVersionNotFound, err)
+
+	// History is dropped with product
+	err = pm.DeleteProduct(setting.Name)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = pm.GetProductSchemaHistory(setting.Name)
+	assert.Equal(t, ErrProductNotFound, err)
+}
+
+func TestProductManager_RecreateProductSchemaHistory(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	setting := CreateTestProductSetting("TestProduct")
+	setting.Schema = map[string]interface{}{
+		"id": map[string]interface{}{"type": "int"},
+	}
+
+	_, err := pm.CreateProduct(setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	setting.Schema["name"] = map[string]interface{}{"type": "string"}
+	_, err = pm.UpdateProduct(setting.Name, setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	err = pm.DeleteProduct(setting.Name)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// Product with the same name starts with fresh history
+	setting = CreateTestProductSetting("TestProduct")
+	setting.Schema = map[string]interface{}{
+		"id": map[string]interface{}{"type": "int"},
+	}
+
+	_, err = pm.CreateProduct(setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	setting.Schema["age"] = map[string]interface{}{"type": "uint"}
+	_, err = pm.UpdateProduct(setting.Name, setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	history, err := pm.GetProductSchemaHistory(setting.Name)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if assert.Len(t, history, 2) {
+		assert.NotContains(t, history[0].Schema, "age")
+		assert.Contains(t, history[1].Schema, "age")
+		assert.NotContains(t, history[1].Schema, "name")
+	}
+
+	// Tombstone which was left by deleting history is taken over as well
+	err = pm.schemaStore.Delete(setting.Name)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	setting.Schema["name"] = map[string]interface{}{"type": "string"}
+	_, err = pm.UpdateProduct(setting.Name, setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	history, err = pm.GetProductSchemaHistory(setting.Name)
+	if assert.Nil(t, err) {
+		assert.Len(t, history, 1)
+	}
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+)
+
+// SetProductSubjectPrefix sets subject prefix of product, which dispatchers prepend to subjects of its events.
+// Product settings have no field for it, so it's stored next to fields of setting and kept by updates. Empty
+// prefix removes it.
+func (pm *ProductManager) SetProductSubjectPrefix(name string, prefix string) error {
+
+	if len(prefix) > 0 {
+		err := rule_manager.ValidateSubjectPrefix(prefix)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidProductSetting, err)
+		}
+	}
+
+	kv, err := pm.getProductEntry(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	setting, err := pm.decodeProductSetting(kv.Value())
+	if err != nil {
+		return err
+	}
+
+	setting.UpdatedAt = pm.now()
+
+	data, err := encodeProductSetting(setting, prefix)
+	if err != nil {
+		return err
+	}
+
+	_, err = pm.configStore.Put(name, data)
+	if err != nil {
+		return err
+	}
+
+	pm.invalidateCache(name)
+
+	return nil
+}
+
+// GetProductSubjectPrefix returns subject prefix of product, or empty string if it has none.
+func (pm *ProductManager) GetProductSubjectPrefix(name string) (string, error) {
+
+	kv, err := pm.getProductEntry(context.Background(), name)
+	if err != nil {
+		return "", err
+	}
+
+	return storedSubjectPrefix(kv.Value())
+}
+
+func storedSubjectPrefix(data []byte) (string, error) {
+
+	var doc map[string]interface{}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return "", err
+	}
+
+	v, ok := doc[rule_manager.SubjectPrefixSettingKey]
+	if !ok || v == nil {
+		return "", nil
+	}
+
+	prefix, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %v", rule_manager.ErrInvalidSubjectPrefix, v)
+	}
+
+	return prefix, nil
+}
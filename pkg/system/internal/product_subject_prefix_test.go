@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductManager_SubjectPrefix(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	setting := CreateTestProductSettingWithRule("TestProduct")
+	_, err := pm.CreateProduct(setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Nil(t, pm.SetProductSubjectPrefix(setting.Name, "tenantA"))
+
+	prefix, err := pm.GetProductSubjectPrefix(setting.Name)
+	assert.Nil(t, err)
+	assert.Equal(t, "tenantA", prefix)
+
+	// Prefix is kept by updates, and it never goes into schema
+	setting.Description = "updated"
+	_, err = pm.UpdateProduct(setting.Name, setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	prefix, _ = pm.GetProductSubjectPrefix(setting.Name)
+	assert.Equal(t, "tenantA", prefix)
+
+	stored, err := pm.GetProduct(setting.Name)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "updated", stored.Description)
+		assert.Equal(t, setting.Schema, stored.Schema)
+	}
+
+	// Invalid prefix
+	for _, prefix := range []string{"tenant..A", "tenant.*", "tenant>"} {
+		err := pm.SetProductSubjectPrefix(setting.Name, prefix)
+		assert.ErrorIs(t, err, ErrInvalidProductSetting)
+		assert.ErrorIs(t, err, rule_manager.ErrInvalidSubjectPrefix)
+	}
+
+	// Removing prefix
+	assert.Nil(t, pm.SetProductSubjectPrefix(setting.Name, ""))
+	prefix, _ = pm.GetProductSubjectPrefix(setting.Name)
+	assert.Empty(t, prefix)
+
+	assert.Equal(t, ErrProductNotFound, pm.SetProductSubjectPrefix("Unknown", "tenantA"))
+}
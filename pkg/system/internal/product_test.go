@@ -0,0 +1,681 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-sdk/v2/core"
+	"github.com/BrobridgeOrg/gravity-sdk/v2/product"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDomain = "default"
+
+func StartTestServer(t testing.TB) *server.Server {
+
+	opts := &server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	}
+
+	s, err := server.NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start()
+
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("test server is not ready")
+	}
+
+	t.Cleanup(s.Shutdown)
+
+	return s
+}
+
+func CreateTestClient(t testing.TB, s *server.Server) *core.Client {
+
+	client := core.NewClient()
+	err := client.Connect(s.ClientURL(), core.NewOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(client.Disconnect)
+
+	return client
+}
+
+func CreateTestProductManager(t testing.TB) *ProductManager {
+
+	s := StartTestServer(t)
+	client := CreateTestClient(t, s)
+
+	pm := NewProductManager(client, testDomain)
+	if pm == nil {
+		t.Fatal("Failed to create product manager")
+	}
+
+	return pm
+}
+
+func CreateTestProductSetting(name string) *product.ProductSetting {
+	return &product.ProductSetting{
+		Name:    name,
+		Enabled: true,
+		Stream:  fmt.Sprintf(productEventStream, testDomain, name),
+	}
+}
+
+func AddTestProductStream(t testing.TB, pm *ProductManager, setting *product.ProductSetting) {
+
+	js, err := pm.client.GetJetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name: setting.Stream,
+		Subjects: []string{
+			fmt.Sprintf(productEventSubject, testDomain, setting.Name, "*"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProductManager_DeleteProduct(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	setting := CreateTestProductSetting("TestProduct")
+	AddTestProductStream(t, pm, setting)
+
+	_, err := pm.CreateProduct(setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	err = pm.DeleteProduct(setting.Name)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = pm.GetProduct(setting.Name)
+	assert.Equal(t, ErrProductNotFound, err)
+
+	// Stream should be kept
+	js, _ := pm.client.GetJetStream()
+	_, err = js.StreamInfo(setting.Stream)
+	assert.Nil(t, err)
+}
+
+func TestProductManager_DeleteProductWithStream(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	setting := CreateTestProductSetting("TestProduct")
+	AddTestProductStream(t, pm, setting)
+
+	_, err := pm.CreateProduct(setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	err = pm.DeleteProductWithOptions(setting.Name, DeleteProductOptions{DeleteStream: true})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = pm.GetProduct(setting.Name)
+	assert.Equal(t, ErrProductNotFound, err)
+
+	js, _ := pm.client.GetJetStream()
+	_, err = js.StreamInfo(setting.Stream)
+	assert.Equal(t, nats.ErrStreamNotFound, err)
+}
+
+func TestProductManager_DeleteProductWithMissingStream(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	// No stream was created for this product
+	setting := CreateTestProductSetting("TestProduct")
+
+	_, err := pm.CreateProduct(setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	err = pm.DeleteProductWithOptions(setting.Name, DeleteProductOptions{DeleteStream: true})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = pm.GetProduct(setting.Name)
+	assert.Equal(t, ErrProductNotFound, err)
+}
+
+func TestProductManager_HealthCheck(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.Nil(t, pm.HealthCheck(ctx))
+
+	// Closed connection
+	pm.client.Disconnect()
+
+	assert.NotNil(t, pm.HealthCheck(ctx))
+}
+
+func TestProductManager_HealthCheckWithExpiredContext(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, pm.HealthCheck(ctx))
+}
+
+func TestProductManager_GetProductByStream(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	for _, name := range []string{"ProductA", "ProductB"} {
+		_, err := pm.CreateProduct(CreateTestProductSetting(name))
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	setting, err := pm.GetProductByStream(fmt.Sprintf(productEventStream, testDomain, "ProductB"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "ProductB", setting.Name)
+	}
+
+	_, err = pm.GetProductByStream(fmt.Sprintf(productEventStream, testDomain, "ProductC"))
+	assert.Equal(t, ErrProductNotFound, err)
+}
+
+func TestProductManager_GetProductByStreamWithAmbiguousStream(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	shared := CreateTestProductSetting("ProductA")
+	for _, name := range []string{"ProductA", "ProductB"} {
+		setting := CreateTestProductSetting(name)
+		setting.Stream = shared.Stream
+
+		_, err := pm.CreateProduct(setting)
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	_, err := pm.GetProductByStream(shared.Stream)
+	assert.ErrorIs(t, err, ErrAmbiguousStream)
+}
+
+func TestProductManager_EnsureProduct(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	setting := func(desc string) *product.ProductSetting {
+		s := CreateTestProductSetting("TestProduct")
+		s.Description = desc
+		return s
+	}
+
+	created, err := pm.EnsureProduct(setting("original"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// Nothing changed
+	again, err := pm.EnsureProduct(setting("original"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, "original", again.Description)
+	assert.True(t, created.CreatedAt.Equal(again.CreatedAt))
+	assert.True(t, created.UpdatedAt.Equal(again.UpdatedAt))
+
+	// Contents differ
+	updated, err := pm.EnsureProduct(setting("updated"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.True(t, created.CreatedAt.Equal(updated.CreatedAt))
+
+	stored, err := pm.GetProduct("TestProduct")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "updated", stored.Description)
+	}
+
+	products, err := pm.ListProducts()
+	if assert.Nil(t, err) {
+		assert.Len(t, products, 1)
+	}
+}
+
+func TestProductManager_EnsureProductWithInvalidName(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	_, err := pm.EnsureProduct(CreateTestProductSetting("Test Product"))
+	assert.Equal(t, ErrInvalidProductName, err)
+}
+
+func TestProductManager_ContextCancelled(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	_, err := pm.CreateProduct(CreateTestProductSetting("TestProduct"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = pm.GetProductContext(ctx, "TestProduct")
+	assert.Equal(t, context.Canceled, err)
+
+	// Nothing is changed
+	assert.Equal(t, context.Canceled, pm.DeleteProductContext(ctx, "TestProduct"))
+
+	setting, err := pm.GetProductContext(context.Background(), "TestProduct")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "TestProduct", setting.Name)
+	}
+}
+
+// blockingConfigStore holds writes until it's released
+type blockingConfigStore struct {
+	productConfigStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (cs *blockingConfigStore) Put(key string, value []byte) (uint64, error) {
+	close(cs.started)
+	<-cs.release
+	return cs.productConfigStore.Put(key, value)
+}
+
+func TestProductManager_ContextCancelledDuringWrite(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	cs := &blockingConfigStore{
+		productConfigStore: pm.configStore,
+		started:            make(chan struct{}),
+		release:            make(chan struct{}),
+	}
+	pm.configStore = cs
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		<-cs.started
+		cancel()
+		close(cs.release)
+	}()
+
+	// Write which was started is applied, and it's reported as it is
+	setting, err := pm.CreateProductContext(ctx, CreateTestProductSetting("TestProduct"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "TestProduct", setting.Name)
+	}
+
+	setting, err = pm.GetProduct("TestProduct")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "TestProduct", setting.Name)
+	}
+}
+
+func TestProductManager_ContextDeadline(t *testing.T) {
+
+	s := StartTestServer(t)
+	client := CreateTestClient(t, s)
+	pm := NewProductManager(client, testDomain)
+
+	// Server hangs up, so requests are pending until reconnected
+	s.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := pm.ListProductsContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestProductManager_RangeProducts(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	for _, name := range []string{"ProductA", "ProductB", "ProductC"} {
+		_, err := pm.CreateProduct(CreateTestProductSetting(name))
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	// Stop after the second product
+	names := make([]string, 0)
+	err := pm.RangeProducts(func(setting *product.ProductSetting, err error) bool {
+		if assert.Nil(t, err) {
+			names = append(names, setting.Name)
+		}
+
+		return len(names) < 2
+	})
+	if assert.Nil(t, err) {
+		assert.Len(t, names, 2)
+	}
+
+	// Broken setting is reported rather than skipped
+	_, err = pm.configStore.Put("Broken", []byte("{"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	count := 0
+	failures := 0
+	err = pm.RangeProducts(func(setting *product.ProductSetting, err error) bool {
+		if err != nil {
+			assert.Nil(t, setting)
+			assert.Contains(t, err.Error(), "Broken")
+			failures++
+			return true
+		}
+
+		count++
+		return true
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, 3, count)
+		assert.Equal(t, 1, failures)
+	}
+}
+
+func TestProductManager_CountProducts(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	count, err := pm.CountProducts()
+	if assert.Nil(t, err) {
+		assert.Equal(t, 0, count)
+	}
+
+	for _, name := range []string{"ProductA", "ProductB", "ProductC"} {
+		setting := CreateTestProductSetting(name)
+		AddTestProductStream(t, pm, setting)
+
+		_, err := pm.CreateProduct(setting)
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	count, err = pm.CountProducts()
+	if assert.Nil(t, err) {
+		assert.Equal(t, 3, count)
+	}
+
+	err = pm.DeleteProduct("ProductB")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	count, err = pm.CountProducts()
+	if assert.Nil(t, err) {
+		assert.Equal(t, 2, count)
+	}
+}
+
+func TestProductManager_ProductNames(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	names, err := pm.ProductNames("")
+	if assert.Nil(t, err) {
+		assert.Empty(t, names)
+	}
+
+	for _, name := range []string{"OrderLines", "Customers", "Orders"} {
+		_, err := pm.CreateProduct(CreateTestProductSetting(name))
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	names, err = pm.ProductNames("")
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"Customers", "OrderLines", "Orders"}, names)
+	}
+
+	names, err = pm.ProductNames("Order")
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"OrderLines", "Orders"}, names)
+	}
+
+	names, err = pm.ProductNames("Missing")
+	if assert.Nil(t, err) {
+		assert.Empty(t, names)
+	}
+}
+
+func TestProductManager_Clock(t *testing.T) {
+
+	s := StartTestServer(t)
+	client := CreateTestClient(t, s)
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	pm := NewProductManager(client, testDomain, WithClock(func() time.Time {
+		return now
+	}))
+
+	_, err := pm.CreateProduct(CreateTestProductSetting("TestProduct"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	stored, err := pm.GetProduct("TestProduct")
+	if assert.Nil(t, err) {
+		assert.Equal(t, now, stored.CreatedAt)
+		assert.Equal(t, now, stored.UpdatedAt)
+	}
+
+	// Only time of update is changed
+	created := now
+	now = now.Add(time.Hour)
+
+	_, err = pm.UpdateProduct("TestProduct", CreateTestProductSetting("TestProduct"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	stored, err = pm.GetProduct("TestProduct")
+	if assert.Nil(t, err) {
+		assert.Equal(t, now, stored.UpdatedAt)
+		assert.NotEqual(t, created, stored.UpdatedAt)
+	}
+}
+
+// flakyConfigStore fails reads with no responders until failures run out
+type flakyConfigStore struct {
+	productConfigStore
+	failures int
+	gets     int
+	puts     int
+}
+
+func (cs *flakyConfigStore) Get(key string) (nats.KeyValueEntry, error) {
+
+	cs.gets++
+	if cs.failures > 0 {
+		cs.failures--
+		return nil, nats.ErrNoResponders
+	}
+
+	return cs.productConfigStore.Get(key)
+}
+
+func (cs *flakyConfigStore) Put(key string, value []byte) (uint64, error) {
+	cs.puts++
+	return 0, nats.ErrNoResponders
+}
+
+func TestProductManager_ReadRetry(t *testing.T) {
+
+	s := StartTestServer(t)
+	client := CreateTestClient(t, s)
+
+	pm := NewProductManager(client, testDomain, WithReadRetry(2, time.Millisecond))
+
+	_, err := pm.CreateProduct(CreateTestProductSetting("TestProduct"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	cs := &flakyConfigStore{
+		productConfigStore: pm.configStore,
+		failures:           2,
+	}
+	pm.configStore = cs
+
+	// Transient failures
+	setting, err := pm.GetProduct("TestProduct")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "TestProduct", setting.Name)
+	}
+
+	assert.Equal(t, 3, cs.gets)
+
+	// Connection never comes back
+	cs.failures = 10
+	cs.gets = 0
+
+	_, err = pm.GetProduct("TestProduct")
+	assert.ErrorIs(t, err, ErrConnectionUnavailable)
+	assert.ErrorIs(t, err, nats.ErrNoResponders)
+	assert.Equal(t, 3, cs.gets)
+
+	// Writes are never retried
+	cs.failures = 0
+
+	_, err = pm.UpdateProduct("TestProduct", CreateTestProductSetting("TestProduct"))
+	assert.ErrorIs(t, err, nats.ErrNoResponders)
+	assert.Equal(t, 1, cs.puts)
+}
+
+// unavailableConfigStore fails all reads with no responders
+type unavailableConfigStore struct {
+	productConfigStore
+	gets atomic.Int32
+}
+
+func (cs *unavailableConfigStore) Get(key string) (nats.KeyValueEntry, error) {
+	cs.gets.Add(1)
+	return nil, nats.ErrNoResponders
+}
+
+func (cs *unavailableConfigStore) Keys() ([]string, error) {
+	return nil, nats.ErrNoResponders
+}
+
+func TestProductManager_ListProductsUnavailable(t *testing.T) {
+
+	s := StartTestServer(t)
+	client := CreateTestClient(t, s)
+
+	pm := NewProductManager(client, testDomain, WithReadRetry(1, time.Millisecond))
+
+	// No product at all
+	products, err := pm.ListProducts()
+	if assert.Nil(t, err) {
+		assert.Empty(t, products)
+	}
+
+	pm.configStore = &unavailableConfigStore{
+		productConfigStore: pm.configStore,
+	}
+
+	_, err = pm.ListProducts()
+	assert.ErrorIs(t, err, ErrConnectionUnavailable)
+}
+
+func TestProductManager_ContextStopsReadRetry(t *testing.T) {
+
+	s := StartTestServer(t)
+	client := CreateTestClient(t, s)
+
+	pm := NewProductManager(client, testDomain, WithReadRetry(10, 50*time.Millisecond))
+
+	cs := &unavailableConfigStore{
+		productConfigStore: pm.configStore,
+	}
+	pm.configStore = cs
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := pm.GetProductContext(ctx, "TestProduct")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// Read which was abandoned makes no more retries
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, int32(1), cs.gets.Load())
+}
+
+func TestProductManager_GetProductWithMeta(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	_, err := pm.CreateProduct(CreateTestProductSetting("TestProduct"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = pm.UpdateProduct("TestProduct", CreateTestProductSetting("TestProduct"))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	entry, err := pm.configStore.Get("TestProduct")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	setting, meta, err := pm.GetProductWithMeta("TestProduct")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "TestProduct", setting.Name)
+		assert.Equal(t, "TestProduct", meta.Key)
+		assert.Equal(t, entry.Revision(), meta.Revision)
+		assert.Equal(t, entry.Created(), meta.Modified)
+		assert.Equal(t, setting.CreatedAt, meta.Created)
+	}
+
+	_, _, err = pm.GetProductWithMeta("Missing")
+	assert.ErrorIs(t, err, ErrProductNotFound)
+}
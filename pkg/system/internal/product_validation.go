@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/BrobridgeOrg/gravity-sdk/v2/product"
+)
+
+var ErrInvalidProductSetting = errors.New("invalid product setting")
+
+// ValidateProductSetting checks required fields, schemas and primary keys of rules. All problems
+// found are reported at once rather than the first one.
+func ValidateProductSetting(s *product.ProductSetting) error {
+
+	if s == nil {
+		return fmt.Errorf("%w: setting is empty", ErrInvalidProductSetting)
+	}
+
+	errs := make([]error, 0)
+
+	if len(s.Name) == 0 {
+		errs = append(errs, errors.New("name is required"))
+	}
+
+	if len(s.Stream) == 0 {
+		errs = append(errs, errors.New("stream is required"))
+	}
+
+	var fields map[string]*rule_manager.FieldSchema
+	if s.Schema != nil {
+		fs, _, err := rule_manager.ParseSchemaConfig(s.Schema)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid schema: %w", err))
+		} else {
+			fields = fs
+		}
+	}
+
+	// Sort rules to report problems in stable order
+	ids := make([]string, 0, len(s.Rules))
+	for id := range s.Rules {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+
+		r := s.Rules[id]
+		if r == nil {
+			errs = append(errs, fmt.Errorf("rule \"%s\" is empty", id))
+			continue
+		}
+
+		if len(r.PrimaryKey) == 0 {
+			errs = append(errs, fmt.Errorf("rule \"%s\" has no primary key", id))
+		}
+
+		// Primary keys are calculated with fields of product schema
+		if fields != nil {
+			for _, pk := range r.PrimaryKey {
+				if rule_manager.LookupFieldSchema(fields, pk) == nil {
+					errs = append(errs, fmt.Errorf("primary key \"%s\" of rule \"%s\" is not defined in schema", pk, id))
+				}
+			}
+		}
+
+		if r.SchemaConfig != nil {
+			_, _, err := rule_manager.ParseSchemaConfig(r.SchemaConfig)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid schema of rule \"%s\": %w", id, err))
+				continue
+			}
+		}
+
+		// Primary keys are checked with schema of rule, or product schema if rule has none
+		rule := rule_manager.NewRule(r)
+		if rule.SchemaConfig == nil && fields != nil {
+			rule.Fields = fields
+		}
+
+		err := rule.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule \"%s\": %w", id, err))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %w", ErrInvalidProductSetting, errors.Join(errs...))
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/BrobridgeOrg/gravity-dispatcher/pkg/dispatcher/rule_manager"
+	"github.com/BrobridgeOrg/gravity-sdk/v2/product"
+	"github.com/stretchr/testify/assert"
+)
+
+func CreateTestProductSettingWithRule(name string) *product.ProductSetting {
+
+	setting := CreateTestProductSetting(name)
+	setting.Schema = map[string]interface{}{
+		"id":   map[string]interface{}{"type": "int"},
+		"name": map[string]interface{}{"type": "string"},
+	}
+	setting.Rules = map[string]*product.Rule{
+		"created": {
+			ID:         "created",
+			Event:      "dataCreated",
+			Product:    name,
+			Method:     "create",
+			PrimaryKey: []string{"id"},
+		},
+	}
+
+	return setting
+}
+
+func TestValidateProductSetting(t *testing.T) {
+	assert.Nil(t, ValidateProductSetting(CreateTestProductSettingWithRule("TestProduct")))
+}
+
+func TestValidateProductSetting_MissingStream(t *testing.T) {
+
+	setting := CreateTestProductSettingWithRule("TestProduct")
+	setting.Stream = ""
+
+	err := ValidateProductSetting(setting)
+	assert.ErrorIs(t, err, ErrInvalidProductSetting)
+	assert.ErrorContains(t, err, "stream is required")
+}
+
+func TestValidateProductSetting_InvalidSchema(t *testing.T) {
+
+	setting := CreateTestProductSettingWithRule("TestProduct")
+	setting.Schema["id"] = "int"
+	setting.Stream = ""
+
+	// All problems should be reported
+	err := ValidateProductSetting(setting)
+	assert.ErrorIs(t, err, ErrInvalidProductSetting)
+	assert.ErrorContains(t, err, "invalid schema")
+	assert.ErrorContains(t, err, "stream is required")
+}
+
+func TestValidateProductSetting_InvalidPrimaryKey(t *testing.T) {
+
+	setting := CreateTestProductSettingWithRule("TestProduct")
+	setting.Rules["created"].PrimaryKey = []string{"uid"}
+	setting.Rules["updated"] = &product.Rule{
+		ID:    "updated",
+		Event: "dataUpdated",
+	}
+
+	err := ValidateProductSetting(setting)
+	assert.ErrorIs(t, err, ErrInvalidProductSetting)
+	assert.ErrorContains(t, err, "primary key \"uid\" of rule \"created\" is not defined in schema")
+	assert.ErrorContains(t, err, "rule \"updated\" has no primary key")
+}
+
+func TestValidateProductSetting_NullablePrimaryKey(t *testing.T) {
+
+	setting := CreateTestProductSettingWithRule("TestProduct")
+	setting.Schema["id"] = map[string]interface{}{"type": "int", "nullable": true}
+
+	err := ValidateProductSetting(setting)
+	assert.ErrorIs(t, err, rule_manager.ErrInvalidPrimaryKey)
+	assert.ErrorContains(t, err, `field "id" is nullable`)
+}
+
+func TestProductManager_CreateProductWithInvalidSetting(t *testing.T) {
+
+	pm := CreateTestProductManager(t)
+
+	setting := CreateTestProductSettingWithRule("TestProduct")
+	setting.Stream = ""
+
+	_, err := pm.CreateProduct(setting)
+	assert.ErrorIs(t, err, ErrInvalidProductSetting)
+
+	_, err = pm.GetProduct(setting.Name)
+	assert.Equal(t, ErrProductNotFound, err)
+
+	// Bad update should not be persisted either
+	setting.Stream = CreateTestProductSetting(setting.Name).Stream
+	_, err = pm.CreateProduct(setting)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	update := CreateTestProductSettingWithRule(setting.Name)
+	update.Schema["id"] = "int"
+
+	_, err = pm.UpdateProduct(setting.Name, update)
+	assert.ErrorIs(t, err, ErrInvalidProductSetting)
+
+	stored, err := pm.GetProduct(setting.Name)
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]interface{}{"type": "int"}, stored.Schema["id"])
+	}
+}
@@ -180,7 +180,7 @@ func (prpc *ProductRPC) create(ctx *RPCContext) {
 	if err != nil {
 		ctx.Res.Error = err
 
-		if err == internal.ErrProductExistsAlready {
+		if err == internal.ErrProductExistsAlready || errors.Is(err, internal.ErrInvalidProductSetting) {
 			resp.Error = &core.Error{
 				Code:    44400,
 				Message: err.Error(),
@@ -220,6 +220,11 @@ func (prpc *ProductRPC) update(ctx *RPCContext) {
 				Code:    44404,
 				Message: err.Error(),
 			}
+		} else if errors.Is(err, internal.ErrInvalidProductSetting) {
+			resp.Error = &core.Error{
+				Code:    44400,
+				Message: err.Error(),
+			}
 		} else {
 			resp.Error = InternalServerErr()
 		}